@@ -8,12 +8,16 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
+
+	"github.com/coze-dev/coze-go/internal/qrcode"
 )
 
 // DeviceAuthReq represents the device authorization request
@@ -181,6 +185,9 @@ type oauthOption struct {
 	baseURL    string
 	wwwURL     string
 	httpClient HTTPClient
+	logger     Logger
+	logLevel   LogLevel
+	redactor   *Redactor
 }
 
 type OAuthClientOption func(*oauthOption)
@@ -205,6 +212,27 @@ func WithAuthHttpClient(client HTTPClient) OAuthClientOption {
 	}
 }
 
+// WithAuthLogger sets the logger of the OAuth client
+func WithAuthLogger(logger Logger) OAuthClientOption {
+	return func(opt *oauthOption) {
+		opt.logger = logger
+	}
+}
+
+// WithAuthLogLevel sets the logging level of the OAuth client
+func WithAuthLogLevel(level LogLevel) OAuthClientOption {
+	return func(opt *oauthOption) {
+		opt.logLevel = level
+	}
+}
+
+// WithAuthRedactor sets the redaction of the log lines and error strings of the OAuth client
+func WithAuthRedactor(redactor *Redactor) OAuthClientOption {
+	return func(opt *oauthOption) {
+		opt.redactor = redactor
+	}
+}
+
 // newOAuthClient creates a new OAuth core
 func newOAuthClient(clientID, clientSecret string, opts ...OAuthClientOption) (*OAuthClient, error) {
 	initSettings := &oauthOption{
@@ -245,8 +273,11 @@ func newOAuthClient(clientID, clientSecret string, opts ...OAuthClientOption) (*
 		wwwURL:       initSettings.wwwURL,
 		hostName:     hostName,
 		core: newCore(&clientOption{
-			baseURL: initSettings.baseURL,
-			client:  httpClient,
+			baseURL:  initSettings.baseURL,
+			client:   httpClient,
+			logger:   initSettings.logger,
+			logLevel: initSettings.logLevel,
+			redactor: initSettings.redactor,
 		}),
 	}, nil
 }
@@ -531,7 +562,7 @@ func (c *DeviceOAuthClient) GetAccessToken(ctx context.Context, dReq *GetDeviceO
 		return c.doGetAccessToken(ctx, req)
 	}
 
-	logger.Infof(ctx, "polling get access token\n")
+	c.core.log.Infof(ctx, "polling get access token\n")
 	interval := 5
 	for {
 		var resp *OAuthToken
@@ -545,14 +576,14 @@ func (c *DeviceOAuthClient) GetAccessToken(ctx context.Context, dReq *GetDeviceO
 		}
 		switch authErr.Code {
 		case AuthorizationPending:
-			logger.Infof(ctx, "pending, sleep:%ds\n", interval)
+			c.core.log.Infof(ctx, "pending, sleep:%ds\n", interval)
 		case SlowDown:
 			if interval < 30 {
 				interval += 5
 			}
-			logger.Infof(ctx, "slow down, sleep:%ds\n", interval)
+			c.core.log.Infof(ctx, "slow down, sleep:%ds\n", interval)
 		default:
-			logger.Warnf(ctx, "get access token error:%s, return\n", err.Error())
+			c.core.log.Warnf(ctx, "get access token error:%s, return\n", err.Error())
 			return nil, err
 		}
 		time.Sleep(time.Duration(interval) * time.Second)
@@ -578,6 +609,114 @@ func (c *DeviceOAuthClient) RefreshToken(ctx context.Context, refreshToken strin
 	return c.refreshAccessToken(ctx, refreshToken)
 }
 
+type DeviceAuthorizeReq struct {
+	WorkspaceID *string
+	// Prompt displays the verification URL and user code to the user, e.g. as text or as a QR code
+	// of VerificationURL. It defaults to printing them to stderr.
+	Prompt func(ctx context.Context, code *GetDeviceAuthResp) error
+	// QRCode receives a QR code of VerificationURL after the prompt, drawn for a terminal such as
+	// os.Stderr, so that the user can authorize from a phone.
+	QRCode io.Writer
+}
+
+// deviceIntervalUnit is the unit of the polling interval, shortened in tests.
+var deviceIntervalUnit = time.Second
+
+// Authorize runs the whole device flow: it requests a device code, prompts the user, and polls
+// until the user has authorized the device. The polling interval follows RFC 8628, it is increased
+// by 5 seconds on slow_down and doubled on server or network errors.
+//
+// When the user denies the authorization, the device code expires or ctx is done, the returned
+// error is a DeviceAuthorizeError.
+func (c *DeviceOAuthClient) Authorize(ctx context.Context, req *DeviceAuthorizeReq) (*OAuthToken, error) {
+	if req == nil {
+		req = &DeviceAuthorizeReq{}
+	}
+	code, err := c.GetDeviceCode(ctx, &GetDeviceOAuthCodeReq{WorkspaceID: req.WorkspaceID})
+	if err != nil {
+		return nil, err
+	}
+	prompt := req.Prompt
+	if prompt == nil {
+		prompt = printDeviceCode
+	}
+	if err := prompt(ctx, code); err != nil {
+		return nil, err
+	}
+	if req.QRCode != nil {
+		verificationURL := code.VerificationURL
+		if verificationURL == "" {
+			verificationURL = code.VerificationURI
+		}
+		// the prompt already displayed the URL, so the authorization goes on without the QR code
+		if err := qrcode.Write(req.QRCode, verificationURL); err != nil {
+			c.core.log.Warnf(ctx, "write device code QR code error: %v", err)
+		}
+	}
+
+	parent := ctx
+	if code.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(code.ExpiresIn)*deviceIntervalUnit)
+		defer cancel()
+	}
+	interval := 5
+	if code.Interval > 0 {
+		interval = code.Interval
+	}
+	tokenReq := &getAccessTokenReq{
+		ClientID:   c.clientID,
+		GrantType:  string(GrantTypeDeviceCode),
+		DeviceCode: code.DeviceCode,
+	}
+	for {
+		if err := sleepContext(ctx, time.Duration(interval)*deviceIntervalUnit); err != nil {
+			return nil, newDeviceAuthorizeError(parent, err)
+		}
+		token, err := c.doGetAccessToken(ctx, tokenReq)
+		if err == nil {
+			return token, nil
+		}
+		if ctx.Err() != nil {
+			return nil, newDeviceAuthorizeError(parent, ctx.Err())
+		}
+
+		authErr, ok := AsAuthError(err)
+		switch {
+		case ok && authErr.Code == AuthorizationPending:
+		case ok && authErr.Code == SlowDown:
+			interval += 5
+		case ok && authErr.Code == AccessDenied:
+			return nil, &DeviceAuthorizeError{Reason: DeviceAuthorizeDenied, parent: err}
+		case ok && authErr.Code == ExpiredToken:
+			return nil, &DeviceAuthorizeError{Reason: DeviceAuthorizeExpired, parent: err}
+		case !IsRetryable(err):
+			return nil, err
+		default:
+			// rate limited, server or network error, back off exponentially
+			c.core.log.Warnf(ctx, "poll device access token error: %v", err)
+			if interval < 60 {
+				interval *= 2
+			}
+		}
+	}
+}
+
+func printDeviceCode(ctx context.Context, code *GetDeviceAuthResp) error {
+	_, err := fmt.Fprintf(os.Stderr, "Open %s in your browser and enter the code %s to authorize.\n",
+		code.VerificationURI, code.UserCode)
+	return err
+}
+
+// newDeviceAuthorizeError tells an expired device code from a canceled authorization, once the
+// polling context is done.
+func newDeviceAuthorizeError(parent context.Context, err error) *DeviceAuthorizeError {
+	if parent.Err() == nil {
+		return &DeviceAuthorizeError{Reason: DeviceAuthorizeExpired, parent: err}
+	}
+	return &DeviceAuthorizeError{Reason: DeviceAuthorizeCanceled, parent: err}
+}
+
 // JWTOAuthClient represents the JWT OAuth core
 type JWTOAuthClient struct {
 	*OAuthClient
@@ -790,8 +929,48 @@ type OAuthConfig struct {
 	CozeWWWBase  string `json:"coze_www_base,omitempty"`
 }
 
-// LoadOAuthAppFromConfig creates an OAuth client based on the provided JSON configuration bytes
+// OAuthApp is the OAuth client of an OAuthConfig, exactly one of its clients is set according to
+// the client_type of the config.
+type OAuthApp struct {
+	PKCE   *PKCEOAuthClient
+	JWT    *JWTOAuthClient
+	Device *DeviceOAuthClient
+	Web    *WebOAuthClient
+}
+
+// TokenRefresher returns the client of a Web, PKCE or Device app, or nil for a JWT app.
+func (a *OAuthApp) TokenRefresher() TokenRefresher {
+	switch {
+	case a.PKCE != nil:
+		return a.PKCE
+	case a.Device != nil:
+		return a.Device
+	case a.Web != nil:
+		return a.Web
+	}
+	return nil
+}
+
+// client returns the client which is set.
+func (a *OAuthApp) client() interface{} {
+	if a.JWT != nil {
+		return a.JWT
+	}
+	return a.TokenRefresher()
+}
+
+// LoadOAuthAppFromConfig creates an OAuth client based on the provided JSON configuration bytes,
+// LoadOAuthApp returns it typed.
 func LoadOAuthAppFromConfig(config *OAuthConfig) (interface{}, error) {
+	app, err := LoadOAuthApp(config)
+	if err != nil {
+		return nil, err
+	}
+	return app.client(), nil
+}
+
+// LoadOAuthApp creates the OAuth client of the config.
+func LoadOAuthApp(config *OAuthConfig) (*OAuthApp, error) {
 	if config.ClientID == "" {
 		return nil, errors.New("client_id is required")
 	}
@@ -808,9 +987,11 @@ func LoadOAuthAppFromConfig(config *OAuthConfig) (interface{}, error) {
 		opts = append(opts, WithAuthWWWURL(config.CozeWWWBase))
 	}
 
+	app := &OAuthApp{}
+	var err error
 	switch config.ClientType {
 	case "pkce":
-		return NewPKCEOAuthClient(config.ClientID, opts...)
+		app.PKCE, err = NewPKCEOAuthClient(config.ClientID, opts...)
 	case "jwt":
 		if config.PrivateKey == "" {
 			return nil, errors.New("private_key is required for JWT client")
@@ -818,19 +999,23 @@ func LoadOAuthAppFromConfig(config *OAuthConfig) (interface{}, error) {
 		if config.PublicKeyID == "" {
 			return nil, errors.New("public_key_id is required for JWT client")
 		}
-		return NewJWTOAuthClient(NewJWTOAuthClientParam{
+		app.JWT, err = NewJWTOAuthClient(NewJWTOAuthClientParam{
 			ClientID:      config.ClientID,
 			PublicKey:     config.PublicKeyID,
 			PrivateKeyPEM: config.PrivateKey,
 		}, opts...)
 	case "device":
-		return NewDeviceOAuthClient(config.ClientID, opts...)
+		app.Device, err = NewDeviceOAuthClient(config.ClientID, opts...)
 	case "web":
 		if config.ClientSecret == "" {
 			return nil, errors.New("client_secret is required for Web client")
 		}
-		return NewWebOAuthClient(config.ClientID, config.ClientSecret, opts...)
+		app.Web, err = NewWebOAuthClient(config.ClientID, config.ClientSecret, opts...)
 	default:
 		return nil, fmt.Errorf("invalid OAuth client_type: %s", config.ClientType)
 	}
+	if err != nil {
+		return nil, err
+	}
+	return app, nil
 }
@@ -0,0 +1,239 @@
+package coze
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// OAuthSession is a pending authorization, kept between the login redirect and the callback.
+type OAuthSession struct {
+	RedirectURI string
+	// CodeVerifier is the PKCE code verifier, it never leaves the server.
+	CodeVerifier string
+	WorkspaceID  *string
+	CreatedAt    time.Time
+}
+
+// OAuthStateStore keeps the pending authorizations by state. Implementations must be safe for
+// concurrent use, and should be shared by all replicas when the callback may reach another one.
+type OAuthStateStore interface {
+	Save(ctx context.Context, state string, session *OAuthSession) error
+	// Take returns and removes the session of state, or nil if there is none.
+	Take(ctx context.Context, state string) (*OAuthSession, error)
+}
+
+// NewMemoryOAuthStateStore creates an OAuthStateStore keeping the sessions in memory for ttl.
+func NewMemoryOAuthStateStore(ttl time.Duration) OAuthStateStore {
+	return &memoryOAuthStateStore{ttl: ttl, sessions: map[string]*OAuthSession{}}
+}
+
+type memoryOAuthStateStore struct {
+	ttl      time.Duration
+	mu       sync.Mutex
+	sessions map[string]*OAuthSession
+}
+
+func (s *memoryOAuthStateStore) Save(ctx context.Context, state string, session *OAuthSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, stored := range s.sessions {
+		if time.Since(stored.CreatedAt) > s.ttl {
+			delete(s.sessions, key)
+		}
+	}
+	s.sessions[state] = session
+	return nil
+}
+
+func (s *memoryOAuthStateStore) Take(ctx context.Context, state string) (*OAuthSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[state]
+	if !ok {
+		return nil, nil
+	}
+	delete(s.sessions, state)
+	if time.Since(session.CreatedAt) > s.ttl {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// ErrOAuthStateMismatch is returned when the state of the callback is unknown, expired, or was
+// not issued to the same browser.
+var ErrOAuthStateMismatch = errors.New("oauth state mismatch")
+
+const oauthStateCookie = "coze_oauth_state"
+
+type OAuthHandlerConfig struct {
+	// RedirectURI is the URL of the callback handler, registered in the OAuth app.
+	RedirectURI string
+	// WorkspaceID returns the workspace to authorize for the login request, or nil for an
+	// authorization which is not workspace scoped.
+	WorkspaceID func(r *http.Request) *string
+	// StateStore keeps the pending authorizations, it defaults to an in-memory store for 10 minutes.
+	StateStore OAuthStateStore
+	// OnToken receives the token of a completed authorization, and writes the response. It is
+	// required.
+	OnToken func(w http.ResponseWriter, r *http.Request, token *OAuthToken)
+	// OnError writes the response of a failed authorization, it defaults to a plain text error.
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// OAuthHandler serves the login redirect and the callback of the Web or PKCE OAuth flow.
+//
+//	handler := coze.NewWebOAuthHandler(client, &coze.OAuthHandlerConfig{...})
+//	http.Handle("/login", handler.Login())
+//	http.Handle("/callback", handler.Callback())
+type OAuthHandler struct {
+	config   OAuthHandlerConfig
+	authURL  func(ctx context.Context, state string, session *OAuthSession) (string, error)
+	exchange func(ctx context.Context, code string, session *OAuthSession) (*OAuthToken, error)
+}
+
+// NewWebOAuthHandler creates the handlers of the Web OAuth flow.
+func NewWebOAuthHandler(client *WebOAuthClient, config *OAuthHandlerConfig) (*OAuthHandler, error) {
+	return newOAuthHandler(config,
+		func(ctx context.Context, state string, session *OAuthSession) (string, error) {
+			return client.GetOAuthURL(ctx, &GetWebOAuthURLReq{
+				RedirectURI: session.RedirectURI,
+				State:       state,
+				WorkspaceID: session.WorkspaceID,
+			}), nil
+		},
+		func(ctx context.Context, code string, session *OAuthSession) (*OAuthToken, error) {
+			return client.GetAccessToken(ctx, &GetWebOAuthAccessTokenReq{
+				Code:        code,
+				RedirectURI: session.RedirectURI,
+			})
+		})
+}
+
+// NewPKCEOAuthHandler creates the handlers of the PKCE OAuth flow. The code verifier is kept in
+// the state store.
+func NewPKCEOAuthHandler(client *PKCEOAuthClient, config *OAuthHandlerConfig) (*OAuthHandler, error) {
+	return newOAuthHandler(config,
+		func(ctx context.Context, state string, session *OAuthSession) (string, error) {
+			resp, err := client.GetOAuthURL(ctx, &GetPKCEOAuthURLReq{
+				RedirectURI: session.RedirectURI,
+				State:       state,
+				WorkspaceID: session.WorkspaceID,
+			})
+			if err != nil {
+				return "", err
+			}
+			session.CodeVerifier = resp.CodeVerifier
+			return resp.AuthorizationURL, nil
+		},
+		func(ctx context.Context, code string, session *OAuthSession) (*OAuthToken, error) {
+			return client.GetAccessToken(ctx, &GetPKCEAccessTokenReq{
+				Code:         code,
+				RedirectURI:  session.RedirectURI,
+				CodeVerifier: session.CodeVerifier,
+			})
+		})
+}
+
+func newOAuthHandler(config *OAuthHandlerConfig,
+	authURL func(ctx context.Context, state string, session *OAuthSession) (string, error),
+	exchange func(ctx context.Context, code string, session *OAuthSession) (*OAuthToken, error),
+) (*OAuthHandler, error) {
+	if config.OnToken == nil {
+		return nil, errors.New("oauth handler: OnToken is required")
+	}
+	h := &OAuthHandler{config: *config, authURL: authURL, exchange: exchange}
+	if h.config.StateStore == nil {
+		h.config.StateStore = NewMemoryOAuthStateStore(10 * time.Minute)
+	}
+	if h.config.OnError == nil {
+		h.config.OnError = writeOAuthError
+	}
+	return h, nil
+}
+
+// Login redirects the user to the Coze authorization page. The state is also set in a cookie,
+// so that the callback only completes in the browser which started the authorization.
+func (h *OAuthHandler) Login() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state, err := generateRandomString(32)
+		if err != nil {
+			h.config.OnError(w, r, err)
+			return
+		}
+		session := &OAuthSession{RedirectURI: h.config.RedirectURI, CreatedAt: time.Now()}
+		if h.config.WorkspaceID != nil {
+			session.WorkspaceID = h.config.WorkspaceID(r)
+		}
+		authorizationURL, err := h.authURL(r.Context(), state, session)
+		if err != nil {
+			h.config.OnError(w, r, err)
+			return
+		}
+		if err := h.config.StateStore.Save(r.Context(), state, session); err != nil {
+			h.config.OnError(w, r, err)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     oauthStateCookie,
+			Value:    state,
+			Path:     "/",
+			MaxAge:   600,
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, authorizationURL, http.StatusFound)
+	})
+}
+
+// Callback verifies the state, exchanges the code for a token and hands it to OnToken.
+func (h *OAuthHandler) Callback() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		state := query.Get("state")
+		http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Value: "", Path: "/", MaxAge: -1})
+
+		cookie, err := r.Cookie(oauthStateCookie)
+		if err != nil || state == "" || cookie.Value != state {
+			h.config.OnError(w, r, ErrOAuthStateMismatch)
+			return
+		}
+		session, err := h.config.StateStore.Take(r.Context(), state)
+		if err != nil {
+			h.config.OnError(w, r, err)
+			return
+		}
+		if session == nil {
+			h.config.OnError(w, r, ErrOAuthStateMismatch)
+			return
+		}
+		if code := query.Get("error"); code != "" {
+			h.config.OnError(w, r, &AuthError{
+				HttpCode:     http.StatusBadRequest,
+				Code:         AuthErrorCode(code),
+				ErrorMessage: query.Get("error_description"),
+			})
+			return
+		}
+
+		token, err := h.exchange(r.Context(), query.Get("code"), session)
+		if err != nil {
+			h.config.OnError(w, r, err)
+			return
+		}
+		h.config.OnToken(w, r, token)
+	})
+}
+
+func writeOAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusBadGateway
+	if errors.Is(err, ErrOAuthStateMismatch) {
+		status = http.StatusBadRequest
+	} else if authErr, ok := AsAuthError(err); ok && authErr.Code == AccessDenied {
+		status = http.StatusForbidden
+	}
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,168 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOAuthTokenTransport(t *testing.T, check func(req *getAccessTokenReq)) *mockTransport {
+	return &mockTransport{
+		roundTripFunc: func(req *http.Request) (*http.Response, error) {
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			tokenReq := &getAccessTokenReq{}
+			require.NoError(t, json.Unmarshal(body, tokenReq))
+			check(tokenReq)
+			return mockResponse(http.StatusOK, &OAuthToken{AccessToken: "test_access_token", RefreshToken: "test_refresh_token"})
+		},
+	}
+}
+
+// runOAuthLogin runs the login handler and returns the authorization URL and the state cookie.
+func runOAuthLogin(t *testing.T, handler *OAuthHandler) (*url.URL, *http.Cookie) {
+	rec := httptest.NewRecorder()
+	handler.Login().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+	location, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	return location, cookies[0]
+}
+
+func runOAuthCallback(handler *OAuthHandler, query string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/callback?"+query, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	handler.Callback().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWebOAuthHandler(t *testing.T) {
+	client, err := NewWebOAuthClient("test_client_id", "test_client_secret",
+		WithAuthBaseURL(ComBaseURL),
+		WithAuthHttpClient(&http.Client{Transport: newOAuthTokenTransport(t, func(req *getAccessTokenReq) {
+			assert.Equal(t, "test_code", req.Code)
+			assert.Equal(t, "https://example.com/callback", req.RedirectURI)
+		})}))
+	require.NoError(t, err)
+
+	var token *OAuthToken
+	handler, err := NewWebOAuthHandler(client, &OAuthHandlerConfig{
+		RedirectURI: "https://example.com/callback",
+		WorkspaceID: func(r *http.Request) *string { return ptr("test_workspace_id") },
+		OnToken: func(w http.ResponseWriter, r *http.Request, t *OAuthToken) {
+			token = t
+			w.WriteHeader(http.StatusNoContent)
+		},
+	})
+	require.NoError(t, err)
+
+	t.Run("login and callback", func(t *testing.T) {
+		location, cookie := runOAuthLogin(t, handler)
+		assert.Contains(t, location.Path, "/workspace_id/test_workspace_id/authorize")
+		state := location.Query().Get("state")
+		assert.Equal(t, state, cookie.Value)
+		assert.True(t, cookie.HttpOnly)
+
+		rec := runOAuthCallback(handler, "code=test_code&state="+state, cookie)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		require.NotNil(t, token)
+		assert.Equal(t, "test_access_token", token.AccessToken)
+
+		// the state can only be used once
+		rec = runOAuthCallback(handler, "code=test_code&state="+state, cookie)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("state not issued to the browser", func(t *testing.T) {
+		location, _ := runOAuthLogin(t, handler)
+		state := location.Query().Get("state")
+
+		rec := runOAuthCallback(handler, "code=test_code&state="+state, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		rec = runOAuthCallback(handler, "code=test_code&state=forged", &http.Cookie{Name: oauthStateCookie, Value: "forged"})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("authorization denied", func(t *testing.T) {
+		location, cookie := runOAuthLogin(t, handler)
+		state := location.Query().Get("state")
+
+		rec := runOAuthCallback(handler, "error=access_denied&state="+state, cookie)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
+
+func TestPKCEOAuthHandler(t *testing.T) {
+	var verifier string
+	client, err := NewPKCEOAuthClient("test_client_id",
+		WithAuthBaseURL(ComBaseURL),
+		WithAuthHttpClient(&http.Client{Transport: newOAuthTokenTransport(t, func(req *getAccessTokenReq) {
+			verifier = req.CodeVerifier
+		})}))
+	require.NoError(t, err)
+
+	store := NewMemoryOAuthStateStore(time.Minute)
+	handler, err := NewPKCEOAuthHandler(client, &OAuthHandlerConfig{
+		RedirectURI: "https://example.com/callback",
+		StateStore:  store,
+		OnToken: func(w http.ResponseWriter, r *http.Request, token *OAuthToken) {
+			w.WriteHeader(http.StatusNoContent)
+		},
+	})
+	require.NoError(t, err)
+
+	location, cookie := runOAuthLogin(t, handler)
+	assert.NotEmpty(t, location.Query().Get("code_challenge"))
+	assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+	state := location.Query().Get("state")
+
+	rec := runOAuthCallback(handler, "code=test_code&state="+state, cookie)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.NotEmpty(t, verifier)
+	challenge, err := genS256CodeChallenge(verifier)
+	require.NoError(t, err)
+	assert.Equal(t, location.Query().Get("code_challenge"), challenge)
+}
+
+func TestOAuthHandlerRequiresOnToken(t *testing.T) {
+	webClient, err := NewWebOAuthClient("test_client_id", "test_client_secret", WithAuthBaseURL(ComBaseURL))
+	require.NoError(t, err)
+	_, err = NewWebOAuthHandler(webClient, &OAuthHandlerConfig{RedirectURI: "https://example.com/callback"})
+	assert.EqualError(t, err, "oauth handler: OnToken is required")
+
+	pkceClient, err := NewPKCEOAuthClient("test_client_id", WithAuthBaseURL(ComBaseURL))
+	require.NoError(t, err)
+	_, err = NewPKCEOAuthHandler(pkceClient, &OAuthHandlerConfig{RedirectURI: "https://example.com/callback"})
+	assert.EqualError(t, err, "oauth handler: OnToken is required")
+}
+
+func TestMemoryOAuthStateStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryOAuthStateStore(time.Minute)
+
+	require.NoError(t, store.Save(ctx, "state", &OAuthSession{CreatedAt: time.Now()}))
+	session, err := store.Take(ctx, "state")
+	require.NoError(t, err)
+	assert.NotNil(t, session)
+	session, err = store.Take(ctx, "state")
+	require.NoError(t, err)
+	assert.Nil(t, session)
+
+	require.NoError(t, store.Save(ctx, "expired", &OAuthSession{CreatedAt: time.Now().Add(-time.Hour)}))
+	session, err = store.Take(ctx, "expired")
+	require.NoError(t, err)
+	assert.Nil(t, session)
+}
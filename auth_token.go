@@ -2,18 +2,85 @@ package coze
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"sync"
 	"time"
 )
 
+// Auth provides the access token of the requests. Implementations must be safe for concurrent use.
 type Auth interface {
 	Token(ctx context.Context) (string, error)
 }
 
+// InvalidatableAuth is implemented by Auth whose token is fetched and cached. The client calls
+// Invalidate when the API rejects the token, so that the next call fetches a new one.
+type InvalidatableAuth interface {
+	Auth
+	Invalidate()
+}
+
+var (
+	_ Auth              = &tokenAuthImpl{}
+	_ InvalidatableAuth = &jwtOAuthImpl{}
+	_ InvalidatableAuth = &refreshTokenAuthImpl{}
+)
+
+// TokenRefresher exchanges a refresh token for a new access token. It is implemented by
+// WebOAuthClient, PKCEOAuthClient and DeviceOAuthClient.
+type TokenRefresher interface {
+	RefreshToken(ctx context.Context, refreshToken string) (*OAuthToken, error)
+}
+
 var (
-	_ Auth = &tokenAuthImpl{}
-	_ Auth = &jwtOAuthImpl{}
+	_ TokenRefresher = &WebOAuthClient{}
+	_ TokenRefresher = &PKCEOAuthClient{}
+	_ TokenRefresher = &DeviceOAuthClient{}
 )
 
+// AuthOption configures an Auth which refreshes its token.
+type AuthOption func(*authOption)
+
+type authOption struct {
+	onTokenRefreshed func(ctx context.Context, token *OAuthToken)
+	onRefreshFailed  func(ctx context.Context, err error)
+	store            TokenStore
+	storeKey         string
+}
+
+func newAuthOption(opts []AuthOption) *authOption {
+	opt := &authOption{}
+	for _, o := range opts {
+		o(opt)
+	}
+	return opt
+}
+
+// WithTokenStore persists the tokens in store under key, see TokenStoreKey. A stored token is
+// reused until it expires, so that processes sharing the store do not authorize again.
+func WithTokenStore(store TokenStore, key string) AuthOption {
+	return func(o *authOption) {
+		o.store = store
+		o.storeKey = key
+	}
+}
+
+// WithTokenRefreshed sets a callback called with every refreshed token, e.g. to persist the
+// rotated refresh token.
+func WithTokenRefreshed(fn func(ctx context.Context, token *OAuthToken)) AuthOption {
+	return func(o *authOption) {
+		o.onTokenRefreshed = fn
+	}
+}
+
+// WithRefreshFailed sets a callback called once when the refresh token is rejected, e.g. with
+// an ExpiredToken or AccessDenied AuthError. The user has to authorize again.
+func WithRefreshFailed(fn func(ctx context.Context, err error)) AuthOption {
+	return func(o *authOption) {
+		o.onRefreshFailed = fn
+	}
+}
+
 // tokenAuthImpl implements the Auth interface with fixed access token.
 type tokenAuthImpl struct {
 	accessToken string
@@ -37,27 +104,26 @@ func getRefreshBefore(ttl int) int64 {
 	return 0
 }
 
-func NewJWTAuth(client *JWTOAuthClient, opt *GetJWTAccessTokenReq) Auth {
+// NewJWTAuth creates an Auth which exchanges a JWT for an access token, and refreshes it before it
+// expires. Concurrent refreshes are collapsed into a single request.
+func NewJWTAuth(client *JWTOAuthClient, opt *GetJWTAccessTokenReq, opts ...AuthOption) Auth {
 	ttl := 900
 	if opt == nil {
-		return &jwtOAuthImpl{
-			TTL:           ttl,
-			client:        client,
-			refreshBefore: getRefreshBefore(ttl),
-		}
+		opt = &GetJWTAccessTokenReq{}
 	}
 	if opt.TTL > 0 {
 		ttl = opt.TTL
 	}
 
-	return &jwtOAuthImpl{
-		TTL:           ttl,
-		Scope:         opt.Scope,
-		SessionName:   opt.SessionName,
-		refreshBefore: getRefreshBefore(ttl),
-		client:        client,
-		accountID:     opt.AccountID,
+	auth := &jwtOAuthImpl{
+		TTL:         ttl,
+		Scope:       opt.Scope,
+		SessionName: opt.SessionName,
+		client:      client,
+		accountID:   opt.AccountID,
 	}
+	auth.cache = newTokenCache(getRefreshBefore(ttl), auth.fetchToken, newAuthOption(opts))
+	return auth
 }
 
 // Token returns the access token.
@@ -66,36 +132,302 @@ func (r *tokenAuthImpl) Token(ctx context.Context) (string, error) {
 }
 
 type jwtOAuthImpl struct {
-	TTL           int
-	SessionName   *string
-	Scope         *Scope
-	client        *JWTOAuthClient
-	accessToken   *string
-	expireIn      int64
-	refreshBefore int64 // refresh moment before expireIn, unit second
-	refreshAt     int64
-	accountID     *int64
+	TTL         int
+	SessionName *string
+	Scope       *Scope
+	client      *JWTOAuthClient
+	accountID   *int64
+	cache       *tokenCache
 }
 
-func (r *jwtOAuthImpl) needRefresh() bool {
-	return r.accessToken == nil || time.Now().Unix() > r.refreshAt
+func (r *jwtOAuthImpl) Token(ctx context.Context) (string, error) {
+	return r.cache.token(ctx)
 }
 
-func (r *jwtOAuthImpl) Token(ctx context.Context) (string, error) {
-	if !r.needRefresh() {
-		return ptrValue(r.accessToken), nil
-	}
-	resp, err := r.client.GetAccessToken(ctx, &GetJWTAccessTokenReq{
+// Invalidate drops the cached token, the next call to Token fetches a new one.
+func (r *jwtOAuthImpl) Invalidate() {
+	r.cache.invalidate()
+}
+
+func (r *jwtOAuthImpl) fetchToken(ctx context.Context, current *OAuthToken) (*OAuthToken, error) {
+	return r.client.GetAccessToken(ctx, &GetJWTAccessTokenReq{
 		TTL:         r.TTL,
 		SessionName: r.SessionName,
 		Scope:       r.Scope,
 		AccountID:   r.accountID,
 	})
+}
+
+// NewRefreshTokenAuth creates an Auth from a token obtained through the Web, PKCE or Device OAuth
+// flow. The access token is refreshed with refresher before it expires, and the refresh token is
+// rotated on every refresh. token may be nil when a token store holding the token is set.
+func NewRefreshTokenAuth(token *OAuthToken, refresher TokenRefresher, opts ...AuthOption) Auth {
+	opt := newAuthOption(opts)
+	// the margin is not derived from the initial token, which may be about to expire when it is
+	// loaded from a store, the refreshed tokens last 15 minutes
+	refreshBefore := getRefreshBefore(900)
+	auth := &refreshTokenAuthImpl{
+		refresher:       refresher,
+		onRefreshFailed: opt.onRefreshFailed,
+	}
+	auth.cache = newTokenCache(refreshBefore, auth.fetchToken, opt)
+	if token != nil {
+		auth.cache.set(token)
+	}
+	return auth
+}
+
+type refreshTokenAuthImpl struct {
+	refresher       TokenRefresher
+	onRefreshFailed func(ctx context.Context, err error)
+	cache           *tokenCache
+}
+
+func (r *refreshTokenAuthImpl) Token(ctx context.Context) (string, error) {
+	return r.cache.token(ctx)
+}
+
+// Invalidate drops the cached token, the next call to Token refreshes it.
+func (r *refreshTokenAuthImpl) Invalidate() {
+	r.cache.invalidate()
+}
+
+func (r *refreshTokenAuthImpl) fetchToken(ctx context.Context, current *OAuthToken) (*OAuthToken, error) {
+	if current == nil || current.RefreshToken == "" {
+		return nil, errors.New("no refresh token available, authorize again")
+	}
+
+	token, err := r.refresher.RefreshToken(ctx, current.RefreshToken)
 	if err != nil {
+		if isRefreshTokenRejected(err) {
+			r.cache.fail(err)
+			r.cache.deleteStored(ctx)
+			if r.onRefreshFailed != nil {
+				r.onRefreshFailed(ctx, err)
+			}
+		}
+		return nil, err
+	}
+
+	if token.RefreshToken == "" {
+		// the refresh token was not rotated, keep using the current one
+		token.RefreshToken = current.RefreshToken
+	}
+	return token, nil
+}
+
+// isRefreshTokenRejected reports whether err means the refresh token can never be used again,
+// as opposed to a transient failure.
+func isRefreshTokenRejected(err error) bool {
+	authErr, ok := AsAuthError(err)
+	if !ok {
+		return false
+	}
+	switch authErr.Code {
+	case ExpiredToken, AccessDenied:
+		return true
+	}
+	return authErr.HttpCode >= 400 && authErr.HttpCode < 500 && authErr.HttpCode != http.StatusTooManyRequests
+}
+
+// invalidateAuth drops the cached token of the client auth, if it caches one.
+func (c *core) invalidateAuth() {
+	if auth, ok := c.auth.(InvalidatableAuth); ok {
+		auth.Invalidate()
+	}
+}
+
+// tokenCache caches an access token and refreshes it before it expires.
+//
+// The token is refreshed in the background once it is within 2*refreshBefore seconds of its
+// expiry, and callers block on the refresh once it is within refreshBefore seconds. Concurrent
+// refreshes are collapsed into a single call to fetch, which is bounded by timeout.
+// When a token store is set, a token stored by another process is preferred over fetching a new
+// one. It is safe for concurrent use.
+type tokenCache struct {
+	refreshBefore int64 // refresh moment before expireIn, unit second
+	fetch         func(ctx context.Context, current *OAuthToken) (*OAuthToken, error)
+	timeout       time.Duration
+	onRefreshed   func(ctx context.Context, token *OAuthToken)
+	store         TokenStore
+	storeKey      string
+
+	mu        sync.Mutex
+	current   *OAuthToken
+	valid     bool  // current holds a usable access token
+	refreshAt int64 // unix timestamp, unit second
+	inflight  *tokenFetch
+	failed    error // a permanent failure of fetch, no more refresh is started
+}
+
+// tokenRefreshTimeout bounds a refresh, which is not canceled by the callers waiting for it.
+const tokenRefreshTimeout = 30 * time.Second
+
+// tokenFetch is an in-flight refresh, shared by every caller waiting for it.
+type tokenFetch struct {
+	done  chan struct{}
+	token *OAuthToken
+	err   error
+}
+
+func newTokenCache(refreshBefore int64, fetch func(ctx context.Context, current *OAuthToken) (*OAuthToken, error), opt *authOption) *tokenCache {
+	return &tokenCache{
+		refreshBefore: refreshBefore,
+		fetch:         fetch,
+		timeout:       tokenRefreshTimeout,
+		onRefreshed:   opt.onTokenRefreshed,
+		store:         opt.store,
+		storeKey:      opt.storeKey,
+	}
+}
+
+// set caches the token, which expires at the unix timestamp token.ExpiresIn.
+func (c *tokenCache) set(token *OAuthToken) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(token)
+}
+
+func (c *tokenCache) setLocked(token *OAuthToken) {
+	c.current = token
+	c.valid = true
+	c.refreshAt = token.ExpiresIn - c.refreshBefore
+}
+
+// fail stops the refreshes after a permanent failure of fetch, e.g. a rejected refresh token. The
+// current access token is used until it expires, then err is returned.
+func (c *tokenCache) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failed = err
+}
+
+// invalidate drops the access token, the refresh token if any is kept. The stored token is not
+// reused since it is not newer than the current one.
+func (c *tokenCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.valid = false
+}
+
+func (c *tokenCache) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	now := time.Now().Unix()
+	if c.valid && now <= c.refreshAt {
+		accessToken := c.current.AccessToken
+		if now > c.refreshAt-c.refreshBefore && c.inflight == nil && c.failed == nil {
+			// refresh ahead of time, the current token is still valid
+			c.startRefresh(ctx)
+		}
+		c.mu.Unlock()
+		return accessToken, nil
+	}
+	if c.failed != nil {
+		err := c.failed
+		c.mu.Unlock()
 		return "", err
 	}
-	r.accessToken = ptr(resp.AccessToken)
-	r.expireIn = resp.ExpiresIn
-	r.refreshAt = resp.ExpiresIn - r.refreshBefore
-	return resp.AccessToken, nil
+	call := c.inflight
+	if call == nil {
+		call = c.startRefresh(ctx)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if call.err != nil {
+		return "", call.err
+	}
+	return call.token.AccessToken, nil
+}
+
+// startRefresh refreshes the token in the background, c.mu must be held. The refresh is not
+// canceled with ctx since other callers may be waiting for it, it times out after c.timeout
+// instead.
+func (c *tokenCache) startRefresh(ctx context.Context) *tokenFetch {
+	call := &tokenFetch{done: make(chan struct{})}
+	c.inflight = call
+	go func() {
+		refreshCtx, cancel := context.WithTimeout(withoutCancel(ctx), c.timeout)
+		defer cancel()
+		token, fetched, err := c.refresh(refreshCtx)
+		c.mu.Lock()
+		if err == nil {
+			c.setLocked(token)
+		} else if c.failed == nil {
+			loggerFromContext(refreshCtx).Warnf(refreshCtx, "refresh access token failed: %v", err)
+		}
+		c.inflight = nil
+		c.mu.Unlock()
+		if fetched && c.onRefreshed != nil {
+			c.onRefreshed(refreshCtx, token)
+		}
+		call.token, call.err = token, err
+		close(call.done)
+	}()
+	return call
+}
+
+// refresh returns a token newer than the current one, from the store if another process has
+// already refreshed it, or else from fetch.
+func (c *tokenCache) refresh(ctx context.Context) (*OAuthToken, bool, error) {
+	c.mu.Lock()
+	current := c.current
+	c.mu.Unlock()
+
+	if stored := c.getStored(ctx); stored != nil && (current == nil || stored.ExpiresIn > current.ExpiresIn) {
+		if stored.AccessToken != "" && time.Now().Unix() <= stored.ExpiresIn-c.refreshBefore {
+			return stored, false, nil
+		}
+		// the stored access token is expired, but it may carry a newer refresh token
+		current = stored
+	}
+
+	token, err := c.fetch(ctx, current)
+	if err != nil {
+		return nil, false, err
+	}
+	if c.store != nil {
+		if err := c.store.Set(ctx, c.storeKey, token); err != nil {
+			loggerFromContext(ctx).Warnf(ctx, "store access token failed: %v", err)
+		}
+	}
+	return token, true, nil
 }
+
+func (c *tokenCache) getStored(ctx context.Context) *OAuthToken {
+	if c.store == nil {
+		return nil
+	}
+	token, err := c.store.Get(ctx, c.storeKey)
+	if err != nil {
+		loggerFromContext(ctx).Warnf(ctx, "get stored access token failed: %v", err)
+		return nil
+	}
+	return token
+}
+
+func (c *tokenCache) deleteStored(ctx context.Context) {
+	if c.store == nil {
+		return
+	}
+	if err := c.store.Delete(ctx, c.storeKey); err != nil {
+		loggerFromContext(ctx).Warnf(ctx, "delete stored access token failed: %v", err)
+	}
+}
+
+// detachedContext keeps the values of its parent but is never canceled.
+type detachedContext struct {
+	context.Context
+}
+
+func withoutCancel(ctx context.Context) context.Context {
+	return detachedContext{Context: ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
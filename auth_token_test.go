@@ -2,7 +2,11 @@ package coze
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -220,3 +224,341 @@ qI39/arl6ZhTeQMv7TrpQ6Q=
 		assert.Equal(t, int64(0), getRefreshBefore(29))
 	})
 }
+
+func TestTokenCache(t *testing.T) {
+	t.Run("concurrent callers share a single fetch", func(t *testing.T) {
+		var calls int32
+		release := make(chan struct{})
+		cache := newTokenCache(30, func(ctx context.Context, current *OAuthToken) (*OAuthToken, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return &OAuthToken{AccessToken: "token", ExpiresIn: time.Now().Unix() + 900}, nil
+		}, &authOption{})
+
+		var wg sync.WaitGroup
+		tokens := make([]string, 10)
+		for i := range tokens {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				token, err := cache.token(context.Background())
+				assert.NoError(t, err)
+				tokens[i] = token
+			}(i)
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		for _, token := range tokens {
+			assert.Equal(t, "token", token)
+		}
+	})
+
+	t.Run("refreshes in the background before expiry", func(t *testing.T) {
+		fetched := make(chan struct{}, 1)
+		cache := newTokenCache(30, func(ctx context.Context, current *OAuthToken) (*OAuthToken, error) {
+			fetched <- struct{}{}
+			return &OAuthToken{AccessToken: "new", ExpiresIn: time.Now().Unix() + 900}, nil
+		}, &authOption{})
+		cache.set(&OAuthToken{AccessToken: "old", ExpiresIn: time.Now().Unix() + 45})
+
+		token, err := cache.token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "old", token)
+
+		<-fetched
+		assert.Eventually(t, func() bool {
+			token, _ := cache.token(context.Background())
+			return token == "new"
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("keeps the valid token when the background refresh fails", func(t *testing.T) {
+		cache := newTokenCache(30, func(ctx context.Context, current *OAuthToken) (*OAuthToken, error) {
+			return nil, errors.New("refresh failed")
+		}, &authOption{})
+		cache.set(&OAuthToken{AccessToken: "old", ExpiresIn: time.Now().Unix() + 45})
+
+		for i := 0; i < 3; i++ {
+			token, err := cache.token(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, "old", token)
+		}
+	})
+
+	t.Run("waiter gives up when its context is canceled", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		cache := newTokenCache(30, func(ctx context.Context, current *OAuthToken) (*OAuthToken, error) {
+			<-release
+			return &OAuthToken{AccessToken: "token", ExpiresIn: time.Now().Unix() + 900}, nil
+		}, &authOption{})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := cache.token(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("refresh times out", func(t *testing.T) {
+		cache := newTokenCache(30, func(ctx context.Context, current *OAuthToken) (*OAuthToken, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}, &authOption{})
+		cache.timeout = 20 * time.Millisecond
+
+		_, err := cache.token(context.Background())
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("invalidate forces a new fetch", func(t *testing.T) {
+		var calls int32
+		cache := newTokenCache(30, func(ctx context.Context, current *OAuthToken) (*OAuthToken, error) {
+			n := atomic.AddInt32(&calls, 1)
+			return &OAuthToken{AccessToken: fmt.Sprintf("token_%d", n), ExpiresIn: time.Now().Unix() + 900}, nil
+		}, &authOption{})
+
+		token, err := cache.token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "token_1", token)
+
+		cache.invalidate()
+		token, err = cache.token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "token_2", token)
+	})
+}
+
+type invalidatableAuth struct {
+	invalidated int32
+}
+
+func (a *invalidatableAuth) Token(ctx context.Context) (string, error) {
+	return "token", nil
+}
+
+func (a *invalidatableAuth) Invalidate() {
+	atomic.AddInt32(&a.invalidated, 1)
+}
+
+func TestInvalidateAuthOnUnauthorized(t *testing.T) {
+	auth := &invalidatableAuth{}
+	core := newCore(&clientOption{
+		baseURL: ComBaseURL,
+		auth:    auth,
+		client: &http.Client{Transport: &mockTransport{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				return mockResponse(http.StatusUnauthorized, &authErrorFormat{
+					ErrorCode:    "invalid_token",
+					ErrorMessage: "token expired",
+				})
+			},
+		}},
+	})
+
+	err := core.Request(context.Background(), http.MethodGet, "/v1/test", nil, &TestResponse{})
+	require.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&auth.invalidated))
+}
+
+type mockTokenRefresher struct {
+	refreshFunc func(ctx context.Context, refreshToken string) (*OAuthToken, error)
+}
+
+func (m *mockTokenRefresher) RefreshToken(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+	return m.refreshFunc(ctx, refreshToken)
+}
+
+func TestRefreshTokenAuth(t *testing.T) {
+	t.Run("uses the initial token until it expires", func(t *testing.T) {
+		refresher := &mockTokenRefresher{refreshFunc: func(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+			t.Fatal("unexpected refresh")
+			return nil, nil
+		}}
+		auth := NewRefreshTokenAuth(&OAuthToken{
+			AccessToken:  "access_1",
+			RefreshToken: "refresh_1",
+			ExpiresIn:    time.Now().Unix() + 900,
+		}, refresher)
+
+		token, err := auth.Token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "access_1", token)
+	})
+
+	t.Run("refreshes the expired token and rotates the refresh token", func(t *testing.T) {
+		var used []string
+		refresher := &mockTokenRefresher{refreshFunc: func(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+			used = append(used, refreshToken)
+			n := len(used) + 1
+			return &OAuthToken{
+				AccessToken:  fmt.Sprintf("access_%d", n),
+				RefreshToken: fmt.Sprintf("refresh_%d", n),
+				ExpiresIn:    time.Now().Unix() + 900,
+			}, nil
+		}}
+		rotated := make(chan *OAuthToken, 2)
+		auth := NewRefreshTokenAuth(&OAuthToken{
+			AccessToken:  "access_1",
+			RefreshToken: "refresh_1",
+			ExpiresIn:    time.Now().Unix() - 1,
+		}, refresher, WithTokenRefreshed(func(ctx context.Context, token *OAuthToken) {
+			rotated <- token
+		}))
+
+		token, err := auth.Token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "access_2", token)
+		assert.Equal(t, "refresh_2", (<-rotated).RefreshToken)
+
+		auth.(InvalidatableAuth).Invalidate()
+		token, err = auth.Token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "access_3", token)
+		assert.Equal(t, "refresh_3", (<-rotated).RefreshToken)
+		assert.Equal(t, []string{"refresh_1", "refresh_2"}, used)
+	})
+
+	t.Run("refreshes early after starting from an expired token", func(t *testing.T) {
+		refreshed := make(chan string, 2)
+		var calls int32
+		refresher := &mockTokenRefresher{refreshFunc: func(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+			refreshed <- refreshToken
+			n := atomic.AddInt32(&calls, 1) + 1
+			return &OAuthToken{
+				AccessToken:  fmt.Sprintf("access_%d", n),
+				RefreshToken: fmt.Sprintf("refresh_%d", n),
+				ExpiresIn:    time.Now().Unix() + 45,
+			}, nil
+		}}
+		auth := NewRefreshTokenAuth(&OAuthToken{
+			AccessToken:  "access_1",
+			RefreshToken: "refresh_1",
+			ExpiresIn:    time.Now().Unix() - 60,
+		}, refresher)
+
+		token, err := auth.Token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "access_2", token)
+		assert.Equal(t, "refresh_1", <-refreshed)
+
+		// the new token expires within the refresh margin, it is refreshed in the background
+		token, err = auth.Token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "access_2", token)
+		select {
+		case refreshToken := <-refreshed:
+			assert.Equal(t, "refresh_2", refreshToken)
+		case <-time.After(time.Second):
+			t.Fatal("the token was not refreshed early")
+		}
+	})
+
+	t.Run("keeps the refresh token when it is not rotated", func(t *testing.T) {
+		refresher := &mockTokenRefresher{refreshFunc: func(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+			return &OAuthToken{AccessToken: "access_2", ExpiresIn: time.Now().Unix() + 900}, nil
+		}}
+		rotated := make(chan *OAuthToken, 1)
+		auth := NewRefreshTokenAuth(&OAuthToken{
+			AccessToken:  "access_1",
+			RefreshToken: "refresh_1",
+		}, refresher, WithTokenRefreshed(func(ctx context.Context, token *OAuthToken) {
+			rotated <- token
+		}))
+
+		_, err := auth.Token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "refresh_1", (<-rotated).RefreshToken)
+	})
+
+	t.Run("reports a rejected refresh token once", func(t *testing.T) {
+		calls := 0
+		refresher := &mockTokenRefresher{refreshFunc: func(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+			calls++
+			return nil, &AuthError{HttpCode: http.StatusBadRequest, Code: ExpiredToken}
+		}}
+		var failed []error
+		auth := NewRefreshTokenAuth(&OAuthToken{
+			AccessToken:  "access_1",
+			RefreshToken: "refresh_1",
+		}, refresher, WithRefreshFailed(func(ctx context.Context, err error) {
+			failed = append(failed, err)
+		}))
+
+		for i := 0; i < 2; i++ {
+			_, err := auth.Token(context.Background())
+			authErr, ok := AsAuthError(err)
+			require.True(t, ok)
+			assert.Equal(t, ExpiredToken, authErr.Code)
+		}
+		assert.Equal(t, 1, calls)
+		assert.Len(t, failed, 1)
+	})
+
+	t.Run("stops refreshing early after a rejected refresh token", func(t *testing.T) {
+		var calls int32
+		refresher := &mockTokenRefresher{refreshFunc: func(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, &AuthError{HttpCode: http.StatusBadRequest, Code: ExpiredToken}
+		}}
+		failed := make(chan error, 1)
+		auth := NewRefreshTokenAuth(&OAuthToken{
+			AccessToken:  "access_1",
+			RefreshToken: "refresh_1",
+			ExpiresIn:    time.Now().Unix() + 45,
+		}, refresher, WithRefreshFailed(func(ctx context.Context, err error) {
+			failed <- err
+		}))
+		record := &recordLogger{}
+		ctx := withLogger(context.Background(), &levelLogger{Logger: record, level: LogLevelWarn})
+
+		// the background refresh of the valid token is rejected
+		token, err := auth.Token(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "access_1", token)
+		<-failed
+		for i := 0; i < 3; i++ {
+			token, err := auth.Token(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, "access_1", token)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		assert.Eventually(t, func() bool {
+			auth.(*refreshTokenAuthImpl).cache.mu.Lock()
+			defer auth.(*refreshTokenAuthImpl).cache.mu.Unlock()
+			return auth.(*refreshTokenAuthImpl).cache.inflight == nil
+		}, time.Second, 10*time.Millisecond)
+		assert.Empty(t, record.Lines())
+
+		// the expired token is not refreshed
+		auth.(InvalidatableAuth).Invalidate()
+		_, err = auth.Token(ctx)
+		authErr, ok := AsAuthError(err)
+		require.True(t, ok)
+		assert.Equal(t, ExpiredToken, authErr.Code)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("retries after a transient failure", func(t *testing.T) {
+		calls := 0
+		refresher := &mockTokenRefresher{refreshFunc: func(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+			calls++
+			if calls == 1 {
+				return nil, errors.New("connection reset")
+			}
+			return &OAuthToken{AccessToken: "access_2", ExpiresIn: time.Now().Unix() + 900}, nil
+		}}
+		auth := NewRefreshTokenAuth(&OAuthToken{AccessToken: "access_1", RefreshToken: "refresh_1"}, refresher,
+			WithRefreshFailed(func(ctx context.Context, err error) {
+				t.Fatal("unexpected refresh failure")
+			}))
+
+		_, err := auth.Token(context.Background())
+		assert.Error(t, err)
+		token, err := auth.Token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "access_2", token)
+	})
+}
@@ -0,0 +1,74 @@
+package coze
+
+import (
+	"context"
+	"io"
+)
+
+// Result is an item, or the error which stopped the iteration.
+type Result[T any] struct {
+	Value *T
+	Err   error
+}
+
+// StreamChan sends the events of the stream to the returned channel, which is closed at the end
+// of the stream or after the first error. When ctx is done the stream and the channel are closed.
+func StreamChan[T streamable](ctx context.Context, stream Stream[T]) <-chan Result[T] {
+	ch := make(chan Result[T])
+	go func() {
+		defer close(ch)
+		defer stream.Close()
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			// unblock Recv when ctx is done
+			select {
+			case <-ctx.Done():
+				stream.Close()
+			case <-done:
+			}
+		}()
+
+		for {
+			event, err := stream.Recv()
+			if err == io.EOF {
+				return
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case ch <- Result[T]{Value: event, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// PagedChan sends the items of all the pages to the returned channel, which is closed after the
+// last item or the first error. It stops when ctx is done.
+func PagedChan[T any](ctx context.Context, pager BasePaged[T]) <-chan Result[T] {
+	ch := make(chan Result[T])
+	go func() {
+		defer close(ch)
+		for pager.Next() {
+			select {
+			case ch <- Result[T]{Value: pager.Current()}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := pager.Err(); err != nil {
+			select {
+			case ch <- Result[T]{Err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return ch
+}
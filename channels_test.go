@@ -0,0 +1,196 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamChan(t *testing.T) {
+	t.Run("all events", func(t *testing.T) {
+		resp := createMockResponse([]string{"first", "second", "done"})
+		stream := newStreamReader[WorkflowEvent](context.Background(), resp, mockEventProcessor)
+
+		var contents []string
+		for result := range StreamChan[WorkflowEvent](context.Background(), stream) {
+			require.NoError(t, result.Err)
+			contents = append(contents, result.Value.Message.Content)
+		}
+		assert.Equal(t, []string{"first", "second", "done"}, contents)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		resp, _ := mockDroppedStreamResponse("data: first\n\n")
+		stream := newStreamReader[WorkflowEvent](context.Background(), resp, mockEventProcessor)
+
+		var results []Result[WorkflowEvent]
+		for result := range StreamChan[WorkflowEvent](context.Background(), stream) {
+			results = append(results, result)
+		}
+		require.Len(t, results, 2)
+		assert.Equal(t, "first", results[0].Value.Message.Content)
+		assert.EqualError(t, results[1].Err, "connection reset by peer")
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		body, writer := io.Pipe()
+		defer writer.Close()
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: body}
+		stream := newStreamReader[WorkflowEvent](context.Background(), resp, mockEventProcessor)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := StreamChan[WorkflowEvent](ctx, stream)
+		go func() {
+			_, _ = writer.Write([]byte("data: first\n\n"))
+		}()
+		result := <-ch
+		require.NoError(t, result.Err)
+		assert.Equal(t, "first", result.Value.Message.Content)
+
+		cancel()
+		select {
+		case _, ok := <-ch:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("channel not closed")
+		}
+	})
+
+	t.Run("context canceled with observers", func(t *testing.T) {
+		body, writer := io.Pipe()
+		go func() {
+			for {
+				if _, err := writer.Write([]byte("event:conversation.message.delta\ndata:{\"content\":\"hi\"}\n\n")); err != nil {
+					return
+				}
+			}
+		}()
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+					Body:       body,
+				}, nil
+			},
+		}}
+		tracer := &mockTracer{}
+		metrics := &mockMetrics{}
+		chats := newChats(newCore(&clientOption{baseURL: "https://api.test.com", client: mock, tracer: tracer, metrics: metrics}))
+		stream, err := chats.Stream(context.Background(), &CreateChatsReq{BotID: "bot1"})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := StreamChan[ChatEvent](ctx, stream)
+		for i := 0; i < 10; i++ {
+			result := <-ch
+			require.NoError(t, result.Err)
+		}
+		cancel()
+		for range ch {
+		}
+		_ = body.Close()
+
+		require.Len(t, tracer.spans, 1)
+		assert.Equal(t, 1, tracer.spans[0].ended)
+	})
+}
+
+func TestStreamChanCanceledInWrappers(t *testing.T) {
+	defer func(interval time.Duration) { resumePollInterval = interval }(resumePollInterval)
+	resumePollInterval = time.Millisecond
+
+	t.Run("during a resume", func(t *testing.T) {
+		resuming := make(chan struct{})
+		var once sync.Once
+		core := newCore(&clientOption{baseURL: ComBaseURL, client: &http.Client{Transport: &mockTransport{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				if req.URL.Path == "/v3/chat" {
+					return mockDroppedStreamResponse("event: conversation.chat.created\n" +
+						`data: {"id":"chat1","conversation_id":"conv1","status":"created"}` + "\n\n")
+				}
+				once.Do(func() { close(resuming) })
+				return mockResponse(http.StatusOK, map[string]any{
+					"data": &Chat{ID: "chat1", ConversationID: "conv1", Status: ChatStatusInProgress},
+				})
+			},
+		}}})
+		stream, err := newChats(core).StreamResumable(context.Background(), &CreateChatsReq{BotID: "bot1"})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := StreamChan[ChatEvent](ctx, stream)
+		result := <-ch
+		require.NoError(t, result.Err)
+		<-resuming
+		cancel()
+		for range ch {
+		}
+	})
+
+	t.Run("during a tool call", func(t *testing.T) {
+		action, err := json.Marshal(requiresActionChat(toolCall("call1", "block", "")))
+		require.NoError(t, err)
+		core := newCore(&clientOption{baseURL: ComBaseURL, client: &http.Client{Transport: &mockTransport{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				if req.URL.Path == "/v3/chat" {
+					return mockStreamResponse("event: conversation.chat.requires_action\ndata: " + string(action) +
+						"\n\nevent: done\ndata: \n\n")
+				}
+				t.Errorf("unexpected request %s", req.URL.Path)
+				return nil, errors.New("unexpected request")
+			},
+		}}})
+		calling := make(chan struct{})
+		registry := NewToolRegistry()
+		registry.Register("block", func(ctx context.Context, arguments string) (string, error) {
+			close(calling)
+			<-ctx.Done()
+			return "", ctx.Err()
+		})
+		stream, err := newChats(core).StreamWithTools(context.Background(), &CreateChatsReq{BotID: "bot1"}, registry)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := StreamChan[ChatEvent](ctx, stream)
+		result := <-ch
+		require.NoError(t, result.Err)
+		<-calling
+		cancel()
+		for range ch {
+		}
+	})
+}
+
+func TestPagedChan(t *testing.T) {
+	pager, err := NewNumberPaged[TestData](newMockDataSource(25).getNumberPageData, 10, 1)
+	require.NoError(t, err)
+
+	count := 0
+	for result := range PagedChan[TestData](context.Background(), pager) {
+		require.NoError(t, result.Err)
+		count++
+		assert.Equal(t, count, result.Value.ID)
+	}
+	assert.Equal(t, 25, count)
+
+	t.Run("context canceled", func(t *testing.T) {
+		pager, err := NewNumberPaged[TestData](newMockDataSource(25).getNumberPageData, 10, 1)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := PagedChan[TestData](ctx, pager)
+		<-ch
+		cancel()
+		for range ch {
+		}
+	})
+}
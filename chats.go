@@ -1,12 +1,9 @@
 package coze
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
-	"strings"
 	"time"
 )
 
@@ -24,7 +21,11 @@ func (r *chat) Create(ctx context.Context, req *CreateChatsReq) (*CreateChatsRes
 	return resp.Chat, nil
 }
 
-func (r *chat) CreateAndPoll(ctx context.Context, req *CreateChatsReq, timeout *int) (*ChatPoll, error) {
+// CreateAndPoll creates a chat and polls it until it is finished, the timeout in seconds elapses or
+// ctx is done. A failed chat returns a ChatFailedError, and a chat which requires action is
+// returned with its messages, so that its tool calls can be submitted. When the timeout elapses or
+// ctx is done the chat is canceled, but only the timeout returns the canceled chat without error.
+func (r *chat) CreateAndPoll(ctx context.Context, req *CreateChatsReq, timeout *int, opts ...PollOption) (*ChatPoll, error) {
 	req.Stream = ptr(false)
 	req.AutoSaveHistory = ptr(true)
 
@@ -32,50 +33,86 @@ func (r *chat) CreateAndPoll(ctx context.Context, req *CreateChatsReq, timeout *
 	if err != nil {
 		return nil, err
 	}
-	chat := chatResp.Chat
-	conversationID := chat.ConversationID
+	created := &chatResp.Chat
+	pollCtx := ctx
+	if timeout != nil {
+		var cancel context.CancelFunc
+		pollCtx, cancel = context.WithTimeout(ctx, time.Duration(*timeout)*time.Second)
+		defer cancel()
+	}
 	now := time.Now()
-	for {
-		time.Sleep(time.Second)
-		if timeout != nil && time.Since(now) > time.Duration(*timeout)*time.Second {
-			logger.Infof(ctx, "Create timeout: ", *timeout, " seconds, cancel Create")
-			cancelResp, err := r.Cancel(ctx, &CancelChatsReq{
-				ConversationID: conversationID,
-				ChatID:         chat.ID,
-			})
-			if err != nil {
-				logger.Warnf(ctx, "Cancel chat failed, err:%v", err)
-				return nil, err
-			}
-			chat = cancelResp.Chat
-			break
+	chat, err := r.pollChat(pollCtx, created, newPollOption(opts))
+	if err != nil {
+		if pollCtx.Err() == nil {
+			return nil, err
 		}
-		retrieveChat, err := r.Retrieve(ctx, &RetrieveChatsReq{
-			ConversationID: conversationID,
-			ChatID:         chat.ID,
+		if ctx.Err() != nil {
+			r.cancelDetached(ctx, created)
+			return nil, err
+		}
+		r.client.log.Infof(ctx, "Create timeout: %d seconds, cancel Create", *timeout)
+		cancelResp, err := r.Cancel(ctx, &CancelChatsReq{
+			ConversationID: created.ConversationID,
+			ChatID:         created.ID,
 		})
 		if err != nil {
+			r.client.log.Warnf(ctx, "Cancel chat failed, err:%v", err)
 			return nil, err
 		}
-		if retrieveChat.Chat.Status == ChatStatusCompleted {
-			chat = retrieveChat.Chat
-			logger.Infof(ctx, "Create completed, spend: %v", time.Since(now))
-			break
-		}
+		chat = &cancelResp.Chat
+	}
+	if chat.Status == ChatStatusCompleted {
+		r.client.observeChatUsage(ctx, chat)
+		r.client.log.Infof(ctx, "Create completed, spend: %v", time.Since(now))
 	}
 	messages, err := r.Messages.List(ctx, &ListChatsMessagesReq{
-		ConversationID: conversationID,
+		ConversationID: chat.ConversationID,
 		ChatID:         chat.ID,
 	})
 	if err != nil {
 		return nil, err
 	}
 	return &ChatPoll{
-		Chat:     &chat,
+		Chat:     chat,
 		Messages: messages.Messages,
 	}, nil
 }
 
+// pollChat polls the chat until it is no longer created or in progress, and returns a
+// ChatFailedError if it failed.
+func (r *chat) pollChat(ctx context.Context, chat *Chat, opt *pollOption) (*Chat, error) {
+	if chat.Status == ChatStatusCreated || chat.Status == ChatStatusInProgress {
+		polled, err := poll(ctx, opt, func(ctx context.Context) (*Chat, bool, error) {
+			resp, err := r.Retrieve(ctx, &RetrieveChatsReq{
+				ConversationID: chat.ConversationID,
+				ChatID:         chat.ID,
+			})
+			if err != nil {
+				return nil, false, err
+			}
+			status := resp.Chat.Status
+			return &resp.Chat, status != ChatStatusCreated && status != ChatStatusInProgress, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		chat = polled
+	}
+	if chat.Status == ChatStatusFailed {
+		return nil, &ChatFailedError{Chat: chat}
+	}
+	return chat, nil
+}
+
+// cancelDetached cancels the chat once ctx is done, the error is only logged.
+func (r *chat) cancelDetached(ctx context.Context, chat *Chat) {
+	ctx, cancel := context.WithTimeout(withoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if _, err := r.Cancel(ctx, &CancelChatsReq{ConversationID: chat.ConversationID, ChatID: chat.ID}); err != nil {
+		r.client.log.Warnf(ctx, "Cancel chat failed, err:%v", err)
+	}
+}
+
 func (r *chat) Stream(ctx context.Context, req *CreateChatsReq) (Stream[ChatEvent], error) {
 	method := http.MethodPost
 	uri := "/v3/chat"
@@ -85,13 +122,7 @@ func (r *chat) Stream(ctx context.Context, req *CreateChatsReq) (Stream[ChatEven
 		return nil, err
 	}
 
-	return &streamReader[ChatEvent]{
-		ctx:          ctx,
-		response:     resp,
-		reader:       bufio.NewReader(resp.Body),
-		processor:    parseChatEvent,
-		httpResponse: newHTTPResponse(resp),
-	}, nil
+	return newClientStreamReader(ctx, r.client, resp, parseChatEvent), nil
 }
 
 type chat struct {
@@ -106,29 +137,19 @@ func newChats(core *core) *chat {
 	}
 }
 
-func parseChatEvent(lineBytes []byte, reader *bufio.Reader) (*ChatEvent, bool, error) {
-	line := string(lineBytes)
-	if strings.HasPrefix(line, "event:") {
-		event := strings.TrimSpace(line[6:])
-		data, err := reader.ReadString('\n')
-		if err != nil {
-			return nil, false, err
-		}
-		data = strings.TrimSpace(data[5:])
-
-		eventLine := map[string]string{
-			"event": event,
-			"data":  data,
-		}
-
-		eventData, err := doParseChatEvent(eventLine)
-		if err != nil {
-			return nil, false, err
-		}
-
-		return eventData, eventData.IsDone(), nil
+func parseChatEvent(ctx context.Context, event *SSEEvent) (*ChatEvent, bool, error) {
+	if event.Event == "" {
+		return nil, false, nil
 	}
-	return nil, false, nil
+	eventLine := map[string]string{
+		"event": event.Event,
+		"data":  event.Data,
+	}
+	eventData, err := doParseChatEvent(ctx, eventLine)
+	if err != nil {
+		return nil, false, newStreamDecodeError(ctx, event, err)
+	}
+	return eventData, eventData.IsDone(), nil
 }
 
 func (r *chat) Cancel(ctx context.Context, req *CancelChatsReq) (*CancelChatsResp, error) {
@@ -186,13 +207,7 @@ func (r *chat) StreamSubmitToolOutputs(ctx context.Context, req *SubmitToolOutpu
 		return nil, err
 	}
 
-	return &streamReader[ChatEvent]{
-		ctx:          ctx,
-		response:     resp,
-		reader:       bufio.NewReader(resp.Body),
-		processor:    parseChatEvent,
-		httpResponse: newHTTPResponse(resp),
-	}, nil
+	return newClientStreamReader(ctx, r.client, resp, parseChatEvent), nil
 }
 
 // ChatStatus The running status of the session.
@@ -453,9 +468,24 @@ type ChatEvent struct {
 	Chat          *Chat          `json:"chat,omitempty"`
 	Message       *Message       `json:"message,omitempty"`
 	WorkflowDebug *WorkflowDebug `json:"workflow_debug,omitempty"`
+	// Error is the error of an error event, returned from Recv instead with WithStreamErrorsReturned.
+	Error *Error `json:"error,omitempty"`
+}
+
+// parseStreamError parses the payload of an error event, which is either a JSON object with code
+// and msg, or a plain message.
+func parseStreamError(data string) *Error {
+	payload := struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}{}
+	if err := json.Unmarshal([]byte(data), &payload); err != nil || (payload.Code == 0 && payload.Msg == "") {
+		return &Error{Message: data}
+	}
+	return &Error{Code: payload.Code, Message: payload.Msg}
 }
 
-func doParseChatEvent(eventLine map[string]string) (*ChatEvent, error) {
+func doParseChatEvent(ctx context.Context, eventLine map[string]string) (*ChatEvent, error) {
 	eventType := ChatEventType(eventLine["event"])
 	data := eventLine["data"]
 	switch eventType {
@@ -463,14 +493,14 @@ func doParseChatEvent(eventLine map[string]string) (*ChatEvent, error) {
 		if data != "" && data != "[DONE]" && data != `"[DONE]"` {
 			workflowDebug := &WorkflowDebug{}
 			if err := json.Unmarshal([]byte(data), workflowDebug); err != nil {
-				logger.Warnf(context.Background(), "workflow.done unmarshal WorkflowDebug failed, msg=%s, err=%s", data, err)
+				loggerFromContext(ctx).Warnf(ctx, "workflow.done unmarshal WorkflowDebug failed, msg=%s, err=%s", data, err)
 				return &ChatEvent{Event: eventType}, nil
 			}
 			return &ChatEvent{Event: eventType, WorkflowDebug: workflowDebug}, nil
 		}
 		return &ChatEvent{Event: eventType}, nil
 	case ChatEventError:
-		return nil, errors.New(data)
+		return &ChatEvent{Event: eventType, Error: parseStreamError(data)}, nil
 	case ChatEventConversationMessageDelta, ChatEventConversationMessageCompleted, ChatEventConversationAudioDelta:
 		message := &Message{}
 		if err := json.Unmarshal([]byte(data), message); err != nil {
@@ -0,0 +1,131 @@
+package coze
+
+import (
+	"io"
+)
+
+// ChatStreamAccumulator assembles the events of a chat stream into complete messages, and into
+// the same ChatPoll returned by CreateAndPoll. The callbacks are optional, and are called as the
+// events are added. It is not safe for concurrent use.
+//
+//	acc := &coze.ChatStreamAccumulator{
+//		OnTextDelta: func(message *coze.Message, delta string) { fmt.Print(delta) },
+//	}
+//	result, err := acc.Consume(stream)
+type ChatStreamAccumulator struct {
+	// OnTextDelta is called with every content delta.
+	OnTextDelta func(message *Message, delta string)
+	// OnReasoningDelta is called with every reasoning content delta.
+	OnReasoningDelta func(message *Message, delta string)
+	// OnMessageCompleted is called when a message is completed, with its full content.
+	OnMessageCompleted func(message *Message)
+	// OnRequiresAction is called when the chat is interrupted to submit tool outputs.
+	OnRequiresAction func(chat *Chat)
+
+	chat     *Chat
+	messages []*Message
+	byID     map[string]*Message
+}
+
+// Add accumulates the event.
+func (a *ChatStreamAccumulator) Add(event *ChatEvent) {
+	if event.Chat != nil {
+		a.chat = event.Chat
+	}
+	switch event.Event {
+	case ChatEventConversationMessageDelta:
+		if event.Message == nil {
+			return
+		}
+		message := a.message(event.Message)
+		if event.Message.Content != "" {
+			message.Content += event.Message.Content
+			if a.OnTextDelta != nil {
+				a.OnTextDelta(message, event.Message.Content)
+			}
+		}
+		if event.Message.ReasoningContent != "" {
+			message.ReasoningContent += event.Message.ReasoningContent
+			if a.OnReasoningDelta != nil {
+				a.OnReasoningDelta(message, event.Message.ReasoningContent)
+			}
+		}
+	case ChatEventConversationMessageCompleted:
+		if event.Message == nil {
+			return
+		}
+		message := a.message(event.Message)
+		content, reasoning := message.Content, message.ReasoningContent
+		*message = *event.Message
+		// the completed event may not repeat the content of the deltas
+		if message.Content == "" {
+			message.Content = content
+		}
+		if message.ReasoningContent == "" {
+			message.ReasoningContent = reasoning
+		}
+		if a.OnMessageCompleted != nil {
+			a.OnMessageCompleted(message)
+		}
+	case ChatEventConversationChatRequiresAction:
+		if a.OnRequiresAction != nil && event.Chat != nil {
+			a.OnRequiresAction(event.Chat)
+		}
+	}
+}
+
+// message returns the accumulated message of the event message, created on its first event.
+func (a *ChatStreamAccumulator) message(eventMessage *Message) *Message {
+	if a.byID == nil {
+		a.byID = map[string]*Message{}
+	}
+	if message, ok := a.byID[eventMessage.ID]; ok {
+		return message
+	}
+	message := *eventMessage
+	message.Content = ""
+	message.ReasoningContent = ""
+	a.byID[message.ID] = &message
+	a.messages = append(a.messages, &message)
+	return &message
+}
+
+// Consume adds all the events of the stream, until it is finished, and returns the result. The
+// stream is closed when Consume returns.
+func (a *ChatStreamAccumulator) Consume(stream Stream[ChatEvent]) (*ChatPoll, error) {
+	defer stream.Close()
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return a.Result(), nil
+		} else if err != nil {
+			return a.Result(), err
+		}
+		a.Add(event)
+	}
+}
+
+// Result returns the chat and the messages accumulated so far, in the order of their first event.
+func (a *ChatStreamAccumulator) Result() *ChatPoll {
+	return &ChatPoll{
+		Chat:     a.chat,
+		Messages: a.messages,
+	}
+}
+
+// RequiresAction reports whether the chat is interrupted to submit tool outputs.
+func (a *ChatStreamAccumulator) RequiresAction() bool {
+	return a.chat != nil && a.chat.Status == ChatStatusRequiresAction
+}
+
+// MessagesOfType returns the messages of the given type, e.g. the answers or the follow-up
+// questions.
+func (c *ChatPoll) MessagesOfType(messageType MessageType) []*Message {
+	var messages []*Message
+	for _, message := range c.Messages {
+		if message.Type == messageType {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
@@ -0,0 +1,99 @@
+package coze
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatStreamAccumulator(t *testing.T) {
+	core := newCore(&clientOption{baseURL: ComBaseURL, client: &http.Client{Transport: &mockTransport{
+		roundTripFunc: func(req *http.Request) (*http.Response, error) {
+			return mockStreamResponse(`event: conversation.chat.created
+data: {"id":"chat1","conversation_id":"conv1","status":"created"}
+
+event: conversation.message.delta
+data: {"id":"msg1","role":"assistant","type":"answer","reasoning_content":"Think"}
+
+event: conversation.message.delta
+data: {"id":"msg1","role":"assistant","type":"answer","content":"Hel"}
+
+event: conversation.message.delta
+data: {"id":"msg1","role":"assistant","type":"answer","content":"lo"}
+
+event: conversation.message.completed
+data: {"id":"msg1","role":"assistant","type":"answer","content":"Hello","reasoning_content":"Think"}
+
+event: conversation.message.completed
+data: {"id":"msg2","role":"assistant","type":"follow_up","content":"More?"}
+
+event: conversation.chat.completed
+data: {"id":"chat1","conversation_id":"conv1","status":"completed","usage":{"token_count":3,"input_count":1,"output_count":2}}
+
+event: done
+data: 
+
+`)
+		},
+	}}})
+
+	stream, err := newChats(core).Stream(context.Background(), &CreateChatsReq{BotID: "bot1"})
+	require.NoError(t, err)
+	closed := &closeRecorder{Stream: stream}
+
+	var text, reasoning string
+	var completed []string
+	acc := &ChatStreamAccumulator{
+		OnTextDelta:        func(message *Message, delta string) { text += delta },
+		OnReasoningDelta:   func(message *Message, delta string) { reasoning += delta },
+		OnMessageCompleted: func(message *Message) { completed = append(completed, message.ID) },
+		OnRequiresAction:   func(chat *Chat) { t.Fatal("unexpected requires action") },
+	}
+	result, err := acc.Consume(closed)
+	require.NoError(t, err)
+	assert.True(t, closed.closed)
+
+	assert.Equal(t, "Hello", text)
+	assert.Equal(t, "Think", reasoning)
+	assert.Equal(t, []string{"msg1", "msg2"}, completed)
+	assert.False(t, acc.RequiresAction())
+
+	assert.Equal(t, ChatStatusCompleted, result.Chat.Status)
+	assert.Equal(t, 3, result.Chat.Usage.TokenCount)
+	require.Len(t, result.Messages, 2)
+	assert.Equal(t, "Hello", result.Messages[0].Content)
+	answers := result.MessagesOfType(MessageTypeAnswer)
+	require.Len(t, answers, 1)
+	assert.Equal(t, "Think", answers[0].ReasoningContent)
+	followUps := result.MessagesOfType(MessageTypeFollowUp)
+	require.Len(t, followUps, 1)
+	assert.Equal(t, "More?", followUps[0].Content)
+}
+
+func TestChatStreamAccumulatorRequiresAction(t *testing.T) {
+	var action *Chat
+	acc := &ChatStreamAccumulator{OnRequiresAction: func(chat *Chat) { action = chat }}
+
+	acc.Add(&ChatEvent{Event: ChatEventConversationMessageDelta, Message: &Message{ID: "msg1", Type: MessageTypeAnswer, Content: "partial"}})
+	acc.Add(&ChatEvent{Event: ChatEventConversationMessageCompleted, Message: &Message{ID: "msg1", Type: MessageTypeAnswer}})
+	acc.Add(&ChatEvent{Event: ChatEventConversationChatRequiresAction, Chat: &Chat{ID: "chat1", Status: ChatStatusRequiresAction}})
+
+	require.NotNil(t, action)
+	assert.True(t, acc.RequiresAction())
+	// the content of the deltas is kept when the completed event does not repeat it
+	assert.Equal(t, "partial", acc.Result().Messages[0].Content)
+}
+
+// closeRecorder records whether the stream was closed.
+type closeRecorder struct {
+	Stream[ChatEvent]
+	closed bool
+}
+
+func (s *closeRecorder) Close() error {
+	s.closed = true
+	return s.Stream.Close()
+}
@@ -385,3 +385,55 @@ data:
 		assert.Equal(t, ChatEventDone, event.Event)
 	})
 }
+
+func TestChatStreamErrorEvent(t *testing.T) {
+	newTestChats := func(errorsReturned bool) *chat {
+		return newChats(newCore(&clientOption{baseURL: ComBaseURL, streamErrorsReturned: errorsReturned, client: &http.Client{Transport: &mockTransport{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				resp, err := mockStreamResponse("event: conversation.chat.created\ndata: {\"id\":\"chat1\"}\n\n" +
+					"event: error\ndata: {\"code\":4000,\"msg\":\"invalid bot\"}\n\n")
+				resp.Header.Set(httpLogIDKey, "stream-log-id")
+				return resp, err
+			},
+		}}}))
+	}
+
+	t.Run("returned from Recv", func(t *testing.T) {
+		stream, err := newTestChats(true).Stream(context.Background(), &CreateChatsReq{BotID: "bot1"})
+		require.NoError(t, err)
+		defer stream.Close()
+
+		_, err = stream.Recv()
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		cozeErr, ok := AsCozeError(err)
+		require.True(t, ok)
+		assert.Equal(t, 4000, cozeErr.Code)
+		assert.Equal(t, "invalid bot", cozeErr.Message)
+		assert.Equal(t, "stream-log-id", cozeErr.LogID)
+		assert.ErrorIs(t, err, ErrInvalidParameter)
+		_, err = stream.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("delivered as event", func(t *testing.T) {
+		stream, err := newTestChats(false).Stream(context.Background(), &CreateChatsReq{BotID: "bot1"})
+		require.NoError(t, err)
+		defer stream.Close()
+
+		_, err = stream.Recv()
+		require.NoError(t, err)
+		event, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, ChatEventError, event.Event)
+		assert.Equal(t, 4000, event.Error.Code)
+		assert.Equal(t, "stream-log-id", event.Error.LogID)
+		_, err = stream.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+}
+
+func TestParseStreamError(t *testing.T) {
+	assert.Equal(t, &Error{Code: 4000, Message: "invalid"}, parseStreamError(`{"code":4000,"msg":"invalid"}`))
+	assert.Equal(t, &Error{Message: "plain failure"}, parseStreamError("plain failure"))
+}
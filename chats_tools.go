@@ -0,0 +1,325 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ToolHandler executes a tool call with the JSON arguments generated by the model, and returns the
+// output reported back to the model.
+type ToolHandler func(ctx context.Context, arguments string) (string, error)
+
+// ToolOption configures a tool registered in a ToolRegistry.
+type ToolOption func(*toolOption)
+
+type toolOption struct {
+	timeout time.Duration
+}
+
+// WithToolTimeout sets the time a tool call may run, 30 seconds by default. A call which times out
+// is reported to the model as an error.
+func WithToolTimeout(timeout time.Duration) ToolOption {
+	return func(o *toolOption) {
+		o.timeout = timeout
+	}
+}
+
+const defaultToolTimeout = 30 * time.Second
+
+// ToolRegistry maps the functions of a bot's local plugins to Go handlers. It is safe for
+// concurrent use.
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]*registeredTool
+}
+
+type registeredTool struct {
+	handler ToolHandler
+	timeout time.Duration
+}
+
+// NewToolRegistry creates an empty ToolRegistry.
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: map[string]*registeredTool{}}
+}
+
+// Register registers the handler of the function name, replacing any previous handler.
+func (r *ToolRegistry) Register(name string, handler ToolHandler, opts ...ToolOption) {
+	opt := &toolOption{timeout: defaultToolTimeout}
+	for _, option := range opts {
+		option(opt)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[name] = &registeredTool{handler: handler, timeout: opt.timeout}
+}
+
+// RegisterTool registers a typed handler of the function name. The arguments are decoded from
+// JSON into Args, and the result is reported as is if it is a string, or encoded as JSON.
+//
+//	coze.RegisterTool(registry, "get_weather", func(ctx context.Context, args WeatherArgs) (*Weather, error) {
+//		return getWeather(ctx, args.City)
+//	})
+func RegisterTool[Args, Result any](registry *ToolRegistry, name string, handler func(ctx context.Context, args Args) (Result, error), opts ...ToolOption) {
+	registry.Register(name, func(ctx context.Context, arguments string) (string, error) {
+		var args Args
+		if arguments != "" {
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+		}
+		result, err := handler(ctx, args)
+		if err != nil {
+			return "", err
+		}
+		if output, ok := any(result).(string); ok {
+			return output, nil
+		}
+		output, err := json.Marshal(result)
+		if err != nil {
+			return "", err
+		}
+		return string(output), nil
+	}, opts...)
+}
+
+func (r *ToolRegistry) lookup(name string) (*registeredTool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// Execute runs the tool calls concurrently, and returns their outputs in the order of the calls.
+// Unknown tools, handler errors, timeouts and panics are reported as the output of their call, so
+// that the model can recover from them; Execute only fails when ctx is done.
+func (r *ToolRegistry) Execute(ctx context.Context, calls []*ChatToolCall) ([]*ToolOutput, error) {
+	outputs := make([]*ToolOutput, len(calls))
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func(i int, call *ChatToolCall) {
+			defer wg.Done()
+			outputs[i] = &ToolOutput{ToolCallID: call.ID, Output: r.execute(ctx, call)}
+		}(i, call)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+func (r *ToolRegistry) execute(ctx context.Context, call *ChatToolCall) string {
+	if call.Function == nil {
+		return fmt.Sprintf("error: tool call %s is not a function call", call.ID)
+	}
+	name := call.Function.Name
+	tool, ok := r.lookup(name)
+	if !ok {
+		return fmt.Sprintf("error: unknown tool %q", name)
+	}
+
+	toolCtx, cancel := context.WithTimeout(ctx, tool.timeout)
+	defer cancel()
+	type result struct {
+		output string
+		err    error
+	}
+	// buffered, so that a handler ignoring ctx does not leak once the call timed out
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- result{err: fmt.Errorf("tool %q panicked: %v", name, p)}
+			}
+		}()
+		output, err := tool.handler(toolCtx, call.Function.Arguments)
+		done <- result{output: output, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			loggerFromContext(ctx).Warnf(ctx, "tool %s failed: %v", name, res.err)
+			return fmt.Sprintf("error: %v", res.err)
+		}
+		return res.output
+	case <-toolCtx.Done():
+		// the parent ctx is done when the whole run was canceled, not only this call
+		if err := ctx.Err(); err != nil {
+			loggerFromContext(ctx).Warnf(ctx, "tool %s canceled: %v", name, err)
+			return fmt.Sprintf("error: tool %q canceled: %v", name, err)
+		}
+		loggerFromContext(ctx).Warnf(ctx, "tool %s timed out after %v", name, tool.timeout)
+		return fmt.Sprintf("error: tool %q timed out after %v", name, tool.timeout)
+	}
+}
+
+// RunWithTools creates a chat, and keeps submitting the outputs of the tool calls handled by
+// registry until the chat is finished. It returns the finished chat and its messages, or errors
+// like CreateAndPoll.
+func (r *chat) RunWithTools(ctx context.Context, req *CreateChatsReq, registry *ToolRegistry, opts ...PollOption) (*ChatPoll, error) {
+	req.Stream = ptr(false)
+	req.AutoSaveHistory = ptr(true)
+
+	ctx = withLogger(ctx, r.client.log)
+	resp, err := r.Create(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	chat := &resp.Chat
+	opt := newPollOption(opts)
+	for {
+		polled, err := r.pollChat(ctx, chat, opt)
+		if err != nil {
+			if ctx.Err() != nil {
+				r.cancelDetached(ctx, chat)
+			}
+			return nil, err
+		}
+		chat = polled
+		if chat.Status != ChatStatusRequiresAction {
+			break
+		}
+		outputs, err := r.executeRequiredAction(ctx, chat, registry)
+		if err != nil {
+			return nil, err
+		}
+		submitResp, err := r.SubmitToolOutputs(ctx, &SubmitToolOutputsChatReq{
+			ConversationID: chat.ConversationID,
+			ChatID:         chat.ID,
+			ToolOutputs:    outputs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		chat = &submitResp.Chat
+	}
+
+	if chat.Status == ChatStatusCompleted {
+		r.client.observeChatUsage(ctx, chat)
+	}
+	messages, err := r.Messages.List(ctx, &ListChatsMessagesReq{
+		ConversationID: chat.ConversationID,
+		ChatID:         chat.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ChatPoll{
+		Chat:     chat,
+		Messages: messages.Messages,
+	}, nil
+}
+
+func (r *chat) executeRequiredAction(ctx context.Context, chat *Chat, registry *ToolRegistry) ([]*ToolOutput, error) {
+	if chat.RequiredAction == nil || chat.RequiredAction.SubmitToolOutputs == nil {
+		return nil, fmt.Errorf("chat %s requires an unsupported action", chat.ID)
+	}
+	return registry.Execute(ctx, chat.RequiredAction.SubmitToolOutputs.ToolCalls)
+}
+
+// StreamWithTools is like Stream, but when the chat requires action it runs the tool calls handled
+// by registry, and continues with the events of StreamSubmitToolOutputs in the same stream. The
+// requires_action events are still emitted, but only the last done event is.
+func (r *chat) StreamWithTools(ctx context.Context, req *CreateChatsReq, registry *ToolRegistry) (Stream[ChatEvent], error) {
+	stream, err := r.Stream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(withLogger(ctx, r.client.log))
+	return &toolStream{ctx: ctx, cancel: cancel, chat: r, registry: registry, stream: stream}, nil
+}
+
+type toolStream struct {
+	// ctx is canceled by Close, which stops the tools and the submission in progress.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	chat     *chat
+	registry *ToolRegistry
+
+	// mu guards stream and closed, as Close may be called while Recv is blocked, e.g. by
+	// StreamChan when its context is done.
+	mu     sync.Mutex
+	stream Stream[ChatEvent]
+	closed bool
+
+	// action is the chat which requires action in the current stream.
+	action *Chat
+}
+
+func (s *toolStream) Recv() (*ChatEvent, error) {
+	for {
+		event, err := s.current().Recv()
+		if err == io.EOF && s.action != nil {
+			if err := s.submit(); err != nil {
+				return nil, err
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch event.Event {
+		case ChatEventConversationChatRequiresAction:
+			s.action = event.Chat
+		case ChatEventDone:
+			if s.action != nil {
+				continue
+			}
+		}
+		return event, nil
+	}
+}
+
+func (s *toolStream) submit() error {
+	action := s.action
+	s.action = nil
+	outputs, err := s.chat.executeRequiredAction(s.ctx, action, s.registry)
+	if err != nil {
+		return err
+	}
+	stream, err := s.chat.StreamSubmitToolOutputs(s.ctx, &SubmitToolOutputsChatReq{
+		ConversationID: action.ConversationID,
+		ChatID:         action.ID,
+		ToolOutputs:    outputs,
+	})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = stream.Close()
+		return s.ctx.Err()
+	}
+	previous := s.stream
+	s.stream = stream
+	s.mu.Unlock()
+	_ = previous.Close()
+	return nil
+}
+
+func (s *toolStream) current() Stream[ChatEvent] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stream
+}
+
+func (s *toolStream) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	stream := s.stream
+	s.mu.Unlock()
+	s.cancel()
+	return stream.Close()
+}
+
+func (s *toolStream) Response() HTTPResponse {
+	return s.current().Response()
+}
@@ -0,0 +1,225 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type weatherArgs struct {
+	City string `json:"city"`
+}
+
+type weather struct {
+	Temperature int `json:"temperature"`
+}
+
+func toolCall(id, name, arguments string) *ChatToolCall {
+	return &ChatToolCall{ID: id, Type: "function", Function: &ChatToolCallFunction{Name: name, Arguments: arguments}}
+}
+
+func TestToolRegistryExecute(t *testing.T) {
+	registry := NewToolRegistry()
+	RegisterTool(registry, "get_weather", func(ctx context.Context, args weatherArgs) (*weather, error) {
+		assert.Equal(t, "Shenzhen", args.City)
+		return &weather{Temperature: 21}, nil
+	})
+	RegisterTool(registry, "echo", func(ctx context.Context, args weatherArgs) (string, error) {
+		return args.City, nil
+	})
+	registry.Register("panic", func(ctx context.Context, arguments string) (string, error) {
+		panic("boom")
+	})
+	registry.Register("slow", func(ctx context.Context, arguments string) (string, error) {
+		time.Sleep(time.Second)
+		return "late", nil
+	}, WithToolTimeout(10*time.Millisecond))
+
+	outputs, err := registry.Execute(context.Background(), []*ChatToolCall{
+		toolCall("call1", "get_weather", `{"city":"Shenzhen"}`),
+		toolCall("call2", "echo", `{"city":"Beijing"}`),
+		toolCall("call3", "unknown", `{}`),
+		toolCall("call4", "panic", `{}`),
+		toolCall("call5", "slow", `{}`),
+		toolCall("call6", "echo", `not json`),
+	})
+	require.NoError(t, err)
+	require.Len(t, outputs, 6)
+	for i, output := range outputs {
+		assert.Equal(t, []string{"call1", "call2", "call3", "call4", "call5", "call6"}[i], output.ToolCallID)
+	}
+	assert.Equal(t, `{"temperature":21}`, outputs[0].Output)
+	assert.Equal(t, "Beijing", outputs[1].Output)
+	assert.Equal(t, `error: unknown tool "unknown"`, outputs[2].Output)
+	assert.Equal(t, `error: tool "panic" panicked: boom`, outputs[3].Output)
+	assert.Contains(t, outputs[4].Output, `error: tool "slow" timed out`)
+	assert.Contains(t, outputs[5].Output, "error: invalid arguments")
+}
+
+func TestToolRegistryExecuteConcurrently(t *testing.T) {
+	registry := NewToolRegistry()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	// each call only returns once both are running
+	registry.Register("wait", func(ctx context.Context, arguments string) (string, error) {
+		wg.Done()
+		wg.Wait()
+		return "ok", nil
+	}, WithToolTimeout(time.Second))
+
+	outputs, err := registry.Execute(context.Background(), []*ChatToolCall{
+		toolCall("call1", "wait", ""),
+		toolCall("call2", "wait", ""),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", outputs[0].Output)
+	assert.Equal(t, "ok", outputs[1].Output)
+}
+
+func TestToolRegistryExecuteCanceled(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.Register("block", func(ctx context.Context, arguments string) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := registry.Execute(ctx, []*ChatToolCall{toolCall("call1", "block", "")})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// the cancellation of the run is not reported as a timeout of the tool
+	output := registry.execute(ctx, toolCall("call1", "block", ""))
+	assert.Equal(t, `error: tool "block" canceled: context canceled`, output)
+}
+
+func requiresActionChat(calls ...*ChatToolCall) Chat {
+	return Chat{
+		ID:             "chat1",
+		ConversationID: "conv1",
+		Status:         ChatStatusRequiresAction,
+		RequiredAction: &ChatRequiredAction{
+			Type:              "submit_tool_outputs",
+			SubmitToolOutputs: &ChatSubmitToolOutputs{ToolCalls: calls},
+		},
+	}
+}
+
+func TestChatRunWithTools(t *testing.T) {
+	defer func(interval time.Duration) { chatPollInterval = interval }(chatPollInterval)
+	chatPollInterval = time.Millisecond
+
+	var retrieves, submits int32
+	core := newCore(&clientOption{baseURL: ComBaseURL, client: &http.Client{Transport: &mockTransport{
+		roundTripFunc: func(req *http.Request) (*http.Response, error) {
+			switch req.URL.Path {
+			case "/v3/chat":
+				body := &CreateChatsReq{}
+				require.NoError(t, json.NewDecoder(req.Body).Decode(body))
+				require.NotNil(t, body.Stream)
+				assert.False(t, *body.Stream)
+				return mockResponse(http.StatusOK, &createChatsResp{
+					Chat: &CreateChatsResp{Chat: Chat{ID: "chat1", ConversationID: "conv1", Status: ChatStatusInProgress}},
+				})
+			case "/v3/chat/retrieve":
+				chat := requiresActionChat(toolCall("call1", "get_weather", `{"city":"Shenzhen"}`))
+				if atomic.AddInt32(&retrieves, 1) > 1 {
+					chat = Chat{ID: "chat1", ConversationID: "conv1", Status: ChatStatusCompleted, Usage: &ChatUsage{TokenCount: 5}}
+				}
+				return mockResponse(http.StatusOK, &retrieveChatsResp{Chat: &RetrieveChatsResp{Chat: chat}})
+			case "/v3/chat/submit_tool_outputs":
+				atomic.AddInt32(&submits, 1)
+				body := &SubmitToolOutputsChatReq{}
+				require.NoError(t, json.NewDecoder(req.Body).Decode(body))
+				require.Len(t, body.ToolOutputs, 1)
+				assert.Equal(t, "call1", body.ToolOutputs[0].ToolCallID)
+				assert.Equal(t, `{"temperature":21}`, body.ToolOutputs[0].Output)
+				assert.Equal(t, "chat1", req.URL.Query().Get("chat_id"))
+				return mockResponse(http.StatusOK, &submitToolOutputsChatResp{
+					Chat: &SubmitToolOutputsChatResp{Chat: Chat{ID: "chat1", ConversationID: "conv1", Status: ChatStatusInProgress}},
+				})
+			case "/v3/chat/message/list":
+				return mockResponse(http.StatusOK, &listChatsMessagesResp{
+					ListChatsMessagesResp: &ListChatsMessagesResp{Messages: []*Message{{ID: "msg1", Type: MessageTypeAnswer, Content: "21 degrees"}}},
+				})
+			}
+			return nil, nil
+		},
+	}}})
+
+	registry := NewToolRegistry()
+	RegisterTool(registry, "get_weather", func(ctx context.Context, args weatherArgs) (*weather, error) {
+		return &weather{Temperature: 21}, nil
+	})
+
+	result, err := newChats(core).RunWithTools(context.Background(), &CreateChatsReq{BotID: "bot1", Stream: ptr(true)}, registry)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&submits))
+	assert.Equal(t, ChatStatusCompleted, result.Chat.Status)
+	assert.Equal(t, 5, result.Chat.Usage.TokenCount)
+	require.Len(t, result.Messages, 1)
+	assert.Equal(t, "21 degrees", result.Messages[0].Content)
+}
+
+func TestChatStreamWithTools(t *testing.T) {
+	action, err := json.Marshal(requiresActionChat(toolCall("call1", "echo", `{"city":"Shenzhen"}`)))
+	require.NoError(t, err)
+
+	core := newCore(&clientOption{baseURL: ComBaseURL, client: &http.Client{Transport: &mockTransport{
+		roundTripFunc: func(req *http.Request) (*http.Response, error) {
+			switch req.URL.Path {
+			case "/v3/chat":
+				return mockStreamResponse("event: conversation.chat.requires_action\ndata: " + string(action) +
+					"\n\nevent: done\ndata: \n\n")
+			case "/v3/chat/submit_tool_outputs":
+				data, _ := io.ReadAll(req.Body)
+				assert.True(t, strings.Contains(string(data), `"output":"Shenzhen"`))
+				return mockStreamResponse(`event: conversation.message.delta
+data: {"id":"msg1","type":"answer","content":"Sunny"}
+
+event: conversation.chat.completed
+data: {"id":"chat1","conversation_id":"conv1","status":"completed"}
+
+event: done
+data: 
+
+`)
+			}
+			return nil, nil
+		},
+	}}})
+
+	registry := NewToolRegistry()
+	RegisterTool(registry, "echo", func(ctx context.Context, args weatherArgs) (string, error) {
+		return args.City, nil
+	})
+
+	stream, err := newChats(core).StreamWithTools(context.Background(), &CreateChatsReq{BotID: "bot1"}, registry)
+	require.NoError(t, err)
+	defer stream.Close()
+
+	var events []ChatEventType
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		events = append(events, event.Event)
+	}
+	assert.Equal(t, []ChatEventType{
+		ChatEventConversationChatRequiresAction,
+		ChatEventConversationMessageDelta,
+		ChatEventConversationChatCompleted,
+		ChatEventDone,
+	}, events)
+}
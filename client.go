@@ -20,11 +20,21 @@ type CozeAPI struct {
 }
 
 type clientOption struct {
-	baseURL     string
-	client      HTTPClient
-	logLevel    LogLevel
-	auth        Auth
-	enableLogID bool
+	baseURL      string
+	client       HTTPClient
+	logLevel     LogLevel
+	auth         Auth
+	enableLogID  bool
+	retryPolicy  *RetryPolicy
+	rateLimiter  RateLimiter
+	interceptors []Interceptor
+	tracer       Tracer
+	metrics      Metrics
+	logger       Logger
+	redactor     *Redactor
+
+	streamErrorsReturned bool
+	streamResumeTimeout  time.Duration
 }
 
 type CozeAPIOption func(*clientOption)
@@ -43,16 +53,17 @@ func WithHttpClient(client HTTPClient) CozeAPIOption {
 	}
 }
 
-// WithLogLevel sets the logging level
+// WithLogLevel sets the logging level of the client
 func WithLogLevel(level LogLevel) CozeAPIOption {
 	return func(opt *clientOption) {
 		opt.logLevel = level
 	}
 }
 
+// WithLogger sets the logger of the client, the other clients of the process are not affected
 func WithLogger(logger Logger) CozeAPIOption {
 	return func(opt *clientOption) {
-		setLogger(logger)
+		opt.logger = logger
 	}
 }
 
@@ -62,6 +73,68 @@ func WithEnableLogID(enableLogID bool) CozeAPIOption {
 	}
 }
 
+// WithRetryPolicy retries rate-limited responses and failed idempotent requests according to the policy.
+// Requests are not retried by default; use DefaultRetryPolicy for the recommended settings.
+func WithRetryPolicy(policy *RetryPolicy) CozeAPIOption {
+	return func(opt *clientOption) {
+		opt.retryPolicy = policy
+	}
+}
+
+// WithRateLimiter limits the requests sent by the client, see NewRateLimiter.
+func WithRateLimiter(limiter RateLimiter) CozeAPIOption {
+	return func(opt *clientOption) {
+		opt.rateLimiter = limiter
+	}
+}
+
+// WithInterceptors wraps every API call with the interceptors, the first one being the outermost.
+func WithInterceptors(interceptors ...Interceptor) CozeAPIOption {
+	return func(opt *clientOption) {
+		opt.interceptors = append(opt.interceptors, interceptors...)
+	}
+}
+
+// WithTracer opens a span for every API call, see Tracer.
+func WithTracer(tracer Tracer) CozeAPIOption {
+	return func(opt *clientOption) {
+		opt.tracer = tracer
+	}
+}
+
+// WithMetrics reports request latency, errors, retries, stream throughput and token usage, see Metrics.
+func WithMetrics(metrics Metrics) CozeAPIOption {
+	return func(opt *clientOption) {
+		opt.metrics = metrics
+	}
+}
+
+// WithRedactor sets the redaction of the log lines and error strings of the client, which
+// defaults to NewRedactor().
+func WithRedactor(redactor *Redactor) CozeAPIOption {
+	return func(opt *clientOption) {
+		opt.redactor = redactor
+	}
+}
+
+// WithStreamErrorsReturned returns the error events of chat and workflow streams from Recv as an
+// *Error, which ends the stream, instead of delivering them as events with ChatEvent.Error or
+// WorkflowEvent.Error set.
+func WithStreamErrorsReturned(enabled bool) CozeAPIOption {
+	return func(opt *clientOption) {
+		opt.streamErrorsReturned = enabled
+	}
+}
+
+// WithStreamResumeTimeout bounds the wait of the resumable streams for the end of the chat or
+// workflow run once their connection dropped, it defaults to 10 minutes. The read error is
+// returned when the call has not ended in time.
+func WithStreamResumeTimeout(timeout time.Duration) CozeAPIOption {
+	return func(opt *clientOption) {
+		opt.streamResumeTimeout = timeout
+	}
+}
+
 func NewCozeAPI(auth Auth, opts ...CozeAPIOption) CozeAPI {
 	opt := &clientOption{
 		baseURL:  ComBaseURL,
@@ -79,7 +152,6 @@ func NewCozeAPI(auth Auth, opts ...CozeAPIOption) CozeAPI {
 	}
 
 	core := newCore(opt)
-	setLevel(opt.logLevel)
 
 	cozeClient := CozeAPI{
 		Audio:         newAudio(core),
@@ -108,7 +180,7 @@ func (h *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	}
 	accessToken, err := h.auth.Token(req.Context())
 	if err != nil {
-		logger.Errorf(req.Context(), "Failed to get access token: %v", err)
+		loggerFromContext(req.Context()).Errorf(req.Context(), "Failed to get access token: %v", err)
 		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+accessToken)
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/coze-dev/coze-go"
+)
+
+var audioCommands = []*command{
+	{name: "speak", usage: "synthesize speech from text", run: speak},
+	{name: "transcribe", usage: "transcribe an audio file", run: transcribe},
+}
+
+// speak writes the speech of the text to a file, or to stdout when the file is "-".
+func speak(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("audio speak", "<text>")
+	voiceID := flags.String("voice", "", "voice ID, see the voices of the Coze console")
+	format := flags.String("format", string(coze.AudioFormatMP3), "audio format: mp3, wav, pcm, ogg_opus, m4a or aac")
+	output := flags.String("o", "", `output file, speech.<format> by default, "-" for stdout`)
+	speed := flags.Float64("speed", 0, "speech speed, the default speed when zero")
+	if err := parseArgs(flags, args, 1, "voice"); err != nil {
+		return err
+	}
+	req := &coze.CreateAudioSpeechReq{Input: flags.Arg(0), VoiceID: *voiceID}
+	audioFormat := coze.AudioFormat(*format)
+	req.ResponseFormat = &audioFormat
+	if *speed != 0 {
+		s := float32(*speed)
+		req.Speed = &s
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Audio.Speech.Create(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Data.Close()
+
+	if *output == "-" {
+		_, err := io.Copy(a.stdout, resp.Data)
+		return err
+	}
+	path := *output
+	if path == "" {
+		path = "speech." + *format
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stderr, "Wrote %s\n", path)
+	return nil
+}
+
+func transcribe(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("audio transcribe", "<audio-file>")
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Audio.Transcriptions.Create(ctx, &coze.AudioSpeechTranscriptionsReq{
+		Filename: filepath.Base(f.Name()),
+		Audio:    f,
+	})
+	if err != nil {
+		return err
+	}
+	if a.out.json {
+		return a.out.value(resp.Data)
+	}
+	_, err = fmt.Fprintln(a.stdout, resp.Data.Text)
+	return err
+}
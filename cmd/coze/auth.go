@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/coze-dev/coze-go"
+)
+
+var authCommands = []*command{
+	{name: "login", usage: "authorize the profile with its OAuth app", run: login},
+	{name: "logout", usage: "remove the token of the profile", run: logout},
+}
+
+// login runs the flow of the OAuth app of the profile, and stores the token for the next commands:
+// the device flow prompts for a code, the PKCE and web flows receive the code on a local redirect
+// URI, and the JWT flow checks the key of the app.
+func login(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("auth login", "")
+	redirectURI := flags.String("redirect-uri", "http://127.0.0.1:8765/callback", "local redirect URI of the PKCE and web apps")
+	qrCode := flags.Bool("qr", false, "also print the verification URL of the device app as a QR code")
+	if err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+	p, err := a.loadProfile()
+	if err != nil {
+		return err
+	}
+	oauth, err := p.OAuthApp()
+	if err != nil {
+		return err
+	}
+
+	var token *coze.OAuthToken
+	switch {
+	case oauth.Device != nil:
+		req := &coze.DeviceAuthorizeReq{
+			Prompt: func(ctx context.Context, code *coze.GetDeviceAuthResp) error {
+				_, err := fmt.Fprintf(a.stderr, "Open %s in your browser and enter the code %s to authorize.\n",
+					code.VerificationURI, code.UserCode)
+				return err
+			},
+		}
+		if *qrCode {
+			req.QRCode = a.stderr
+		}
+		token, err = oauth.Device.Authorize(ctx, req)
+	case oauth.PKCE != nil:
+		token, err = a.loginWithRedirect(ctx, *redirectURI, func(config *coze.OAuthHandlerConfig) (*coze.OAuthHandler, error) {
+			return coze.NewPKCEOAuthHandler(oauth.PKCE, config)
+		})
+	case oauth.Web != nil:
+		token, err = a.loginWithRedirect(ctx, *redirectURI, func(config *coze.OAuthHandlerConfig) (*coze.OAuthHandler, error) {
+			return coze.NewWebOAuthHandler(oauth.Web, config)
+		})
+	case oauth.JWT != nil:
+		token, err = oauth.JWT.GetAccessToken(ctx, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	store, key := p.TokenStore()
+	if err := store.Set(ctx, key, token); err != nil {
+		return fmt.Errorf("store token: %w", err)
+	}
+	fmt.Fprintf(a.stderr, "Logged in to %s with profile %s, the access token expires at %s.\n",
+		p.APIBase, p.Name, formatTime(token.ExpiresIn))
+	return nil
+}
+
+// loginWithRedirect serves the login and callback handlers of the SDK on the host of the redirect
+// URI, prints the login URL, and returns the token of the callback.
+func (a *app) loginWithRedirect(ctx context.Context, redirectURI string,
+	newHandler func(config *coze.OAuthHandlerConfig) (*coze.OAuthHandler, error),
+) (*coze.OAuthToken, error) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect URI: %w", err)
+	}
+	callbackPath := u.Path
+	if callbackPath == "" {
+		callbackPath = "/"
+	}
+	if callbackPath == loginPath {
+		return nil, fmt.Errorf("invalid redirect URI: %s is the path of the login URL", loginPath)
+	}
+	tokens := make(chan *coze.OAuthToken, 1)
+	failures := make(chan error, 1)
+	handler, err := newHandler(&coze.OAuthHandlerConfig{
+		RedirectURI: redirectURI,
+		OnToken: func(w http.ResponseWriter, r *http.Request, token *coze.OAuthToken) {
+			fmt.Fprintln(w, "Authorized, you can close this page.")
+			select {
+			case tokens <- token:
+			default:
+			}
+		},
+		OnError: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, "authorization failed: "+err.Error(), http.StatusBadRequest)
+			// a request which was not started by the login URL does not end the login
+			if errors.Is(err, coze.ErrOAuthStateMismatch) {
+				return
+			}
+			select {
+			case failures <- fmt.Errorf("authorization failed: %w", err):
+			default:
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", u.Host)
+	if err != nil {
+		return nil, fmt.Errorf("listen on the redirect URI: %w", err)
+	}
+
+	loginURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: loginPath}
+	mux := http.NewServeMux()
+	mux.Handle(loginPath, handler.Login())
+	mux.Handle(callbackPath, handler.Callback())
+	server := &http.Server{ReadHeaderTimeout: 10 * time.Second, Handler: mux}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Close()
+
+	fmt.Fprintf(a.stderr, "Open this URL in your browser to authorize:\n%s\n", loginURL)
+	select {
+	case token := <-tokens:
+		return token, nil
+	case err := <-failures:
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// loginPath is the path of the local login URL, which redirects to the authorization page.
+const loginPath = "/login"
+
+func logout(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("auth logout", "")
+	if err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+	p, err := a.loadProfile()
+	if err != nil {
+		return err
+	}
+	if p.OAuth == nil {
+		return errors.New("the profile has no oauth app")
+	}
+	store, key := p.TokenStore()
+	if err := store.Delete(ctx, key); err != nil {
+		return fmt.Errorf("delete token: %w", err)
+	}
+	fmt.Fprintf(a.stderr, "Logged out of profile %s.\n", p.Name)
+	return nil
+}
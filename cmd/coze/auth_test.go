@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+)
+
+func TestLoginJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	privateKey := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/permission/oauth2/token" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&coze.OAuthToken{
+			AccessToken: "jwt-token",
+			ExpiresIn:   time.Now().Add(time.Hour).Unix(),
+		})
+	}))
+	defer server.Close()
+
+	configPath := writeConfig(t, &coze.Config{
+		DefaultProfile: "jwt",
+		Profiles: map[string]*coze.Profile{"jwt": {
+			APIBase: server.URL,
+			OAuth: &coze.OAuthConfig{
+				ClientID:    "client",
+				ClientType:  "jwt",
+				PrivateKey:  privateKey,
+				PublicKeyID: "public-key",
+			},
+		}},
+	})
+	tokenPath := filepath.Join(filepath.Dir(configPath), "tokens")
+
+	_, stderr, err := runCLI(t, configPath, "", "auth", "login")
+	require.NoError(t, err)
+	assert.Contains(t, stderr, "Logged in to "+server.URL+" with profile jwt")
+	entries, err := os.ReadDir(tokenPath)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	_, stderr, err = runCLI(t, configPath, "", "auth", "logout")
+	require.NoError(t, err)
+	assert.Contains(t, stderr, "Logged out of profile jwt.")
+	entries, err = os.ReadDir(tokenPath)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, _, err = runCLI(t, configPath, "", "auth", "login", "extra")
+	assert.Error(t, err)
+}
+
+// syncBuffer is a buffer written by the command while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLoginPKCE(t *testing.T) {
+	var verifier string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/permission/oauth2/authorize":
+			// the user authorizes the app
+			query := r.URL.Query()
+			assert.Equal(t, "S256", query.Get("code_challenge_method"))
+			http.Redirect(w, r, query.Get("redirect_uri")+"?code=test_code&state="+url.QueryEscape(query.Get("state")), http.StatusFound)
+		case "/api/permission/oauth2/token":
+			var req struct {
+				Code         string `json:"code"`
+				CodeVerifier string `json:"code_verifier"`
+			}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, "test_code", req.Code)
+			verifier = req.CodeVerifier
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(&coze.OAuthToken{
+				AccessToken:  "pkce-token",
+				RefreshToken: "refresh",
+				ExpiresIn:    time.Now().Add(time.Hour).Unix(),
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	redirectURI := "http://" + listener.Addr().String() + "/callback"
+	require.NoError(t, listener.Close())
+
+	configPath := writeConfig(t, &coze.Config{
+		DefaultProfile: "pkce",
+		Profiles: map[string]*coze.Profile{"pkce": {
+			APIBase: server.URL,
+			OAuth:   &coze.OAuthConfig{ClientID: "client", ClientType: "pkce"},
+		}},
+	})
+	stderr := &syncBuffer{}
+	e := &env{stdin: strings.NewReader(""), stdout: io.Discard, stderr: stderr, getenv: func(string) string { return "" }}
+	done := make(chan error, 1)
+	go func() {
+		done <- run(context.Background(), []string{"-config", configPath, "auth", "login", "-redirect-uri", redirectURI}, e)
+	}()
+
+	var loginURL string
+	require.Eventually(t, func() bool {
+		loginURL = regexp.MustCompile(`http://\S+/login`).FindString(stderr.String())
+		return loginURL != ""
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the browser keeps the state cookie of the login URL for the callback
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	resp, err := (&http.Client{Jar: jar}).Get(loginURL)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "Authorized")
+
+	require.NoError(t, <-done)
+	assert.NotEmpty(t, verifier)
+	assert.Contains(t, stderr.String(), "Logged in to "+server.URL+" with profile pkce")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/coze-dev/coze-go"
+)
+
+var botCommands = []*command{
+	{name: "list", usage: "list the published bots of a space", run: listBots},
+	{name: "get", usage: "show a published bot", run: getBot},
+	{name: "publish", usage: "publish a bot to connectors", run: publishBot},
+}
+
+// collect returns the items of all the pages.
+func collect[T any](paged coze.BasePaged[T]) ([]*T, error) {
+	var items []*T
+	for paged.Next() {
+		items = append(items, paged.Current())
+	}
+	return items, paged.Err()
+}
+
+func listBots(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("bots list", "")
+	spaceID := flags.String("space", "", "space ID")
+	pageSize := flags.Int("page-size", 20, "number of bots fetched per request")
+	if err := parse(flags, args, "space"); err != nil {
+		return err
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	paged, err := client.Bots.List(ctx, &coze.ListBotsReq{SpaceID: *spaceID, PageSize: *pageSize})
+	if err != nil {
+		return err
+	}
+	bots, err := collect[coze.SimpleBot](paged)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(bots))
+	for _, bot := range bots {
+		rows = append(rows, []string{bot.BotID, bot.BotName, bot.PublishTime, bot.Description})
+	}
+	return a.out.table(bots, []string{"ID", "NAME", "PUBLISHED", "DESCRIPTION"}, rows)
+}
+
+func getBot(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("bots get", "<bot-id>")
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	bot, err := client.Bots.Retrieve(ctx, &coze.RetrieveBotsReq{BotID: flags.Arg(0)})
+	if err != nil {
+		return err
+	}
+	fields := [][2]string{
+		{"ID", bot.BotID},
+		{"Name", bot.Name},
+		{"Description", bot.Description},
+		{"Version", bot.Version},
+		{"Mode", botMode(bot.BotMode)},
+		{"Created", formatTime(bot.CreateTime)},
+		{"Updated", formatTime(bot.UpdateTime)},
+	}
+	if bot.ModelInfo != nil {
+		fields = append(fields, [2]string{"Model", bot.ModelInfo.ModelName})
+	}
+	if bot.PromptInfo != nil {
+		fields = append(fields, [2]string{"Prompt", bot.PromptInfo.Prompt})
+	}
+	return a.out.object(bot, fields)
+}
+
+func botMode(mode coze.BotMode) string {
+	switch mode {
+	case coze.BotModeMultiAgent:
+		return "multi agent"
+	case coze.BotModeSingleAgentWorkflow:
+		return "single agent workflow"
+	}
+	return itoa(int(mode))
+}
+
+func publishBot(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("bots publish", "<bot-id>")
+	connectors := flags.String("connectors", "1024", "comma separated connector IDs, 1024 is the API connector")
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Bots.Publish(ctx, &coze.PublishBotsReq{
+		BotID:        flags.Arg(0),
+		ConnectorIDs: strings.Split(*connectors, ","),
+	})
+	if err != nil {
+		return err
+	}
+	return a.out.object(resp, [][2]string{{"ID", resp.BotID}, {"Version", resp.BotVersion}})
+}
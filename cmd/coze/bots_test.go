@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+)
+
+func TestBots(t *testing.T) {
+	server, configPath := newCLI(t)
+	botID := server.AddBot("space", &coze.Bot{Name: "assistant", Description: "helps", ModelInfo: &coze.BotModelInfo{ModelName: "doubao"}})
+	server.AddBot("space", &coze.Bot{Name: "other"})
+
+	t.Run("list", func(t *testing.T) {
+		stdout, _, err := runCLI(t, configPath, "", "bots", "list", "-space", "space", "-page-size", "1")
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(stdout), "\n")
+		require.Len(t, lines, 3)
+		assert.Equal(t, []string{"ID", "NAME", "PUBLISHED", "DESCRIPTION"}, strings.Fields(lines[0]))
+		assert.Contains(t, lines[1], botID)
+		assert.Contains(t, lines[1], "helps")
+	})
+
+	t.Run("list as json", func(t *testing.T) {
+		stdout, _, err := runCLI(t, configPath, "", "-output", "json", "bots", "list", "-space", "space")
+		require.NoError(t, err)
+		var bots []*coze.SimpleBot
+		require.NoError(t, json.Unmarshal([]byte(stdout), &bots))
+		require.Len(t, bots, 2)
+		assert.Equal(t, "assistant", bots[0].BotName)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		stdout, _, err := runCLI(t, configPath, "", "bots", "get", botID)
+		require.NoError(t, err)
+		assert.Contains(t, stdout, "Name:         assistant\n")
+		assert.Contains(t, stdout, "Model:        doubao\n")
+	})
+
+	t.Run("publish", func(t *testing.T) {
+		stdout, _, err := runCLI(t, configPath, "", "bots", "publish", "-connectors", "1024,999", botID)
+		require.NoError(t, err)
+		assert.Contains(t, stdout, botID)
+		requests := server.Requests()
+		assert.JSONEq(t, `{"bot_id":"`+botID+`","connector_ids":["1024","999"]}`, string(requests[len(requests)-1].Body))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, _, err := runCLI(t, configPath, "", "bots", "get", "1")
+		assert.ErrorIs(t, err, coze.ErrNotFound)
+	})
+}
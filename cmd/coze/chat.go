@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// runChat chats with a bot in the same conversation, one streamed chat per line read from stdin,
+// or once with the question given as argument. With the JSON output, the events are written as
+// JSON lines.
+func runChat(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("chat", "[question]")
+	botID := flags.String("bot", "", "bot ID")
+	userID := flags.String("user", "coze-cli", "user ID")
+	conversationID := flags.String("conversation", "", "conversation ID, a new conversation by default")
+	if err := parse(flags, args, "bot"); err != nil {
+		return err
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	s := &chatSession{app: a, client: client, botID: *botID, userID: *userID, conversationID: *conversationID}
+	if flags.NArg() > 0 {
+		return s.send(ctx, strings.Join(flags.Args(), " "))
+	}
+
+	scanner := bufio.NewScanner(a.stdin)
+	for {
+		fmt.Fprint(a.stderr, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(a.stderr)
+			return scanner.Err()
+		}
+		question := strings.TrimSpace(scanner.Text())
+		if question == "" {
+			continue
+		}
+		if question == "/exit" || question == "/quit" {
+			return nil
+		}
+		if err := s.send(ctx, question); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			// the session goes on after a failed chat
+			fmt.Fprintln(a.stderr, "error:", err)
+		}
+	}
+}
+
+type chatSession struct {
+	*app
+	client         *coze.CozeAPI
+	botID          string
+	userID         string
+	conversationID string
+}
+
+// send streams the answer to the question, and keeps the conversation of the chat for the next
+// questions.
+func (s *chatSession) send(ctx context.Context, question string) error {
+	stream, err := s.client.Chat.Stream(ctx, &coze.CreateChatsReq{
+		ConversationID: s.conversationID,
+		BotID:          s.botID,
+		UserID:         s.userID,
+		Messages:       []*coze.Message{coze.BuildUserQuestionText(question, nil)},
+	})
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	var encoder *json.Encoder
+	if s.out.json {
+		encoder = json.NewEncoder(s.stdout)
+	}
+	for {
+		event, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if event.Chat != nil && event.Chat.ConversationID != "" {
+			s.conversationID = event.Chat.ConversationID
+		}
+		if encoder != nil {
+			if err := encoder.Encode(event); err != nil {
+				return err
+			}
+			continue
+		}
+		switch event.Event {
+		case coze.ChatEventConversationMessageDelta:
+			fmt.Fprint(s.stdout, event.Message.Content)
+		case coze.ChatEventConversationMessageCompleted:
+			if event.Message.Type == coze.MessageTypeAnswer {
+				fmt.Fprintln(s.stdout)
+			}
+		case coze.ChatEventConversationChatCompleted:
+			if usage := event.Chat.Usage; usage != nil {
+				fmt.Fprintf(s.stderr, "[%d tokens]\n", usage.TokenCount)
+			}
+		case coze.ChatEventConversationChatRequiresAction:
+			return errors.New("the bot requires tool outputs, which the command does not support")
+		case coze.ChatEventConversationChatFailed:
+			if event.Chat.LastError != nil {
+				return fmt.Errorf("chat failed: %s (code %d)", event.Chat.LastError.Msg, event.Chat.LastError.Code)
+			}
+			return errors.New("chat failed")
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+	"github.com/coze-dev/coze-go/cozetest"
+)
+
+func TestChat(t *testing.T) {
+	t.Run("session", func(t *testing.T) {
+		server, configPath := newCLI(t)
+		server.ReplyChat("bot", &cozetest.ChatReply{Answer: "Hello!"})
+
+		stdout, stderr, err := runCLI(t, configPath, "Hi\n\nHow are you?\n/exit\nignored\n", "chat", "-bot", "bot")
+		require.NoError(t, err)
+		assert.Equal(t, "Hello!\nYou said: How are you?\n", stdout)
+		assert.Contains(t, stderr, "tokens]")
+
+		// the questions are asked in the same conversation
+		var conversations []string
+		for _, req := range server.Requests() {
+			if req.Path == "/v3/chat" {
+				conversations = append(conversations, req.Query.Get("conversation_id"))
+			}
+		}
+		require.Len(t, conversations, 2)
+		assert.Empty(t, conversations[0])
+		assert.NotEmpty(t, conversations[1])
+		assert.Len(t, server.Messages(conversations[1]), 4)
+	})
+
+	t.Run("question as argument", func(t *testing.T) {
+		_, configPath := newCLI(t)
+		stdout, _, err := runCLI(t, configPath, "", "-output", "json", "chat", "-bot", "bot", "Hi", "there")
+		require.NoError(t, err)
+		var events []*coze.ChatEvent
+		for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+			event := &coze.ChatEvent{}
+			require.NoError(t, json.Unmarshal([]byte(line), event))
+			events = append(events, event)
+		}
+		require.NotEmpty(t, events)
+		assert.Equal(t, coze.ChatEventConversationChatCreated, events[0].Event)
+		assert.Equal(t, coze.ChatEventDone, events[len(events)-1].Event)
+	})
+
+	t.Run("failed chat", func(t *testing.T) {
+		server, configPath := newCLI(t)
+		server.ReplyChat("bot", &cozetest.ChatReply{Error: &coze.ChatError{Code: 5000, Msg: "model overloaded"}})
+		stdout, stderr, err := runCLI(t, configPath, "Hi\nAgain\n", "chat", "-bot", "bot")
+		require.NoError(t, err)
+		assert.Contains(t, stderr, "error: chat failed: model overloaded (code 5000)")
+		assert.Equal(t, "You said: Again\n", stdout)
+	})
+}
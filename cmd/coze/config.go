@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// loadProfile loads the selected profile from the config file, which may not exist, and fills what
+// it does not set from the environment, see coze.LoadConfig.
+func (a *app) loadProfile() (*coze.Profile, error) {
+	if a.profile != nil {
+		return a.profile, nil
+	}
+	cfg, err := coze.LoadConfig(a.configPath)
+	if err != nil {
+		return nil, err
+	}
+	p, err := cfg.Profile(a.profileName)
+	if err != nil {
+		return nil, err
+	}
+	p.ApplyEnv(a.getenv)
+	if p.APIBase == "" {
+		p.APIBase = coze.ComBaseURL
+	}
+	a.profile = p
+	return p, nil
+}
+
+// newClient returns a client of the profile. Its HTTP client has no timeout, so that long streams
+// are not cut, and it only logs warnings unless the profile sets its log level.
+func newClient(p *coze.Profile) (*coze.CozeAPI, error) {
+	if p.OAuth != nil && p.OAuth.ClientType != "jwt" {
+		store, key := p.TokenStore()
+		token, err := store.Get(context.Background(), key)
+		if err != nil {
+			return nil, err
+		}
+		if token == nil {
+			return nil, fmt.Errorf("profile %q is not logged in, run coze -profile %s auth login", p.Name, p.Name)
+		}
+	}
+	opts := []coze.CozeAPIOption{coze.WithHttpClient(&http.Client{})}
+	if p.LogLevel == "" {
+		opts = append(opts, coze.WithLogLevel(coze.LogLevelWarn))
+	}
+	client, err := coze.NewCozeAPIFromConfig(p, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
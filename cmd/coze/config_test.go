@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+)
+
+func TestLoadProfile(t *testing.T) {
+	configPath := writeConfig(t, &coze.Config{
+		DefaultProfile: "work",
+		Profiles: map[string]*coze.Profile{
+			"work": {APIBase: "https://api.example.com", APIToken: "work-token"},
+			"cn":   {APIToken: "cn-token"},
+		},
+	})
+	noEnv := func(string) string { return "" }
+
+	tests := []struct {
+		name    string
+		config  string
+		profile string
+		getenv  func(string) string
+		base    string
+		err     string
+	}{
+		{name: "default of the config file", config: configPath, base: "https://api.example.com"},
+		{name: "no api base", config: configPath, profile: "cn", base: coze.ComBaseURL},
+		{name: "builtin profile", config: configPath, profile: "com", base: coze.ComBaseURL},
+		{name: "no config file", config: filepath.Join(t.TempDir(), "missing.json"), base: coze.ComBaseURL},
+		{
+			name:   "api base of the environment",
+			config: filepath.Join(t.TempDir(), "missing.json"),
+			getenv: func(key string) string {
+				if key == "COZE_API_BASE" {
+					return coze.CnBaseURL
+				}
+				return ""
+			},
+			base: coze.CnBaseURL,
+		},
+		{
+			name:   "environment overrides the config file",
+			config: configPath,
+			getenv: func(key string) string {
+				if key == "COZE_API_BASE" {
+					return coze.CnBaseURL
+				}
+				return ""
+			},
+			base: coze.CnBaseURL,
+		},
+		{name: "unknown profile", config: configPath, profile: "other", err: `unknown profile "other" in ` + configPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := tt.getenv
+			if getenv == nil {
+				getenv = noEnv
+			}
+			a := &app{env: &env{getenv: getenv}, configPath: tt.config, profileName: tt.profile}
+			p, err := a.loadProfile()
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.base, p.APIBase)
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	configPath := writeConfig(t, &coze.Config{
+		Profiles: map[string]*coze.Profile{
+			"device": {OAuth: &coze.OAuthConfig{ClientID: "client", ClientType: "device"}},
+		},
+	})
+	a := &app{env: &env{getenv: func(string) string { return "" }}, configPath: configPath}
+
+	_, err := a.client()
+	assert.EqualError(t, err, `profile "default" has no credentials, set COZE_API_TOKEN or configure it in the config file`)
+
+	a.profileName = "device"
+	a.profile = nil
+	_, err = a.client()
+	assert.EqualError(t, err, `profile "device" is not logged in, run coze -profile device auth login`)
+
+	p, err := a.loadProfile()
+	require.NoError(t, err)
+	store, key := p.TokenStore()
+	require.NoError(t, store.Set(context.Background(), key, &coze.OAuthToken{
+		AccessToken:  "oauth-token",
+		RefreshToken: "refresh",
+		ExpiresIn:    time.Now().Add(time.Hour).Unix(),
+	}))
+	_, err = a.client()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/coze-dev/coze-go"
+)
+
+var datasetCommands = []*command{
+	{name: "list", usage: "list the datasets of a space", run: listDatasets},
+}
+
+var documentCommands = []*command{
+	{name: "list", usage: "list the documents of a dataset", run: listDocuments},
+	{name: "upload", usage: "upload local files to a dataset", run: uploadDocuments},
+}
+
+func listDatasets(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("datasets list", "")
+	spaceID := flags.String("space", "", "space ID")
+	name := flags.String("name", "", "only the datasets whose name contains it")
+	if err := parseArgs(flags, args, 0, "space"); err != nil {
+		return err
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	req := coze.NewListDatasetsReq(*spaceID)
+	req.Name = *name
+	paged, err := client.Datasets.List(ctx, req)
+	if err != nil {
+		return err
+	}
+	datasets, err := collect[coze.Dataset](paged)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(datasets))
+	for _, ds := range datasets {
+		rows = append(rows, []string{ds.ID, ds.Name, formatType(ds.FormatType), itoa(ds.DocCount), formatTime(ds.UpdateTime)})
+	}
+	return a.out.table(datasets, []string{"ID", "NAME", "FORMAT", "DOCUMENTS", "UPDATED"}, rows)
+}
+
+func formatType(formatType coze.DocumentFormatType) string {
+	switch formatType {
+	case coze.DocumentFormatTypeDocument:
+		return "document"
+	case coze.DocumentFormatTypeSpreadsheet:
+		return "spreadsheet"
+	case coze.DocumentFormatTypeImage:
+		return "image"
+	}
+	return itoa(int(formatType))
+}
+
+// datasetID parses the ID of a dataset, which the document endpoints take as a number.
+func datasetID(id string) (int64, error) {
+	parsed, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid dataset ID %q", id)
+	}
+	return parsed, nil
+}
+
+func listDocuments(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("documents list", "")
+	dataset := flags.String("dataset", "", "dataset ID")
+	if err := parseArgs(flags, args, 0, "dataset"); err != nil {
+		return err
+	}
+	id, err := datasetID(*dataset)
+	if err != nil {
+		return err
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	paged, err := client.Datasets.Documents.List(ctx, &coze.ListDatasetsDocumentsReq{DatasetID: id})
+	if err != nil {
+		return err
+	}
+	documents, err := collect[coze.Document](paged)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(documents))
+	for _, doc := range documents {
+		rows = append(rows, []string{doc.DocumentID, doc.Name, documentStatus(doc.Status), itoa(doc.Size), formatTime(doc.UpdateTime)})
+	}
+	return a.out.table(documents, []string{"ID", "NAME", "STATUS", "SIZE", "UPDATED"}, rows)
+}
+
+func documentStatus(status coze.DocumentStatus) string {
+	switch status {
+	case coze.DocumentStatusProcessing:
+		return "processing"
+	case coze.DocumentStatusCompleted:
+		return "completed"
+	case coze.DocumentStatusFailed:
+		return "failed"
+	}
+	return itoa(int(status))
+}
+
+// uploadDocuments adds the files to the dataset, their type is given by their extension.
+func uploadDocuments(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("documents upload", "<file>...")
+	dataset := flags.String("dataset", "", "dataset ID")
+	if err := parse(flags, args, "dataset"); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("%s: no file to upload", flags.Name())
+	}
+	id, err := datasetID(*dataset)
+	if err != nil {
+		return err
+	}
+	var bases []*coze.DocumentBase
+	for _, path := range flags.Args() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name := filepath.Base(path)
+		fileType := strings.TrimPrefix(filepath.Ext(name), ".")
+		if fileType == "" {
+			fileType = "txt"
+		}
+		bases = append(bases, coze.DocumentBaseBuildLocalFile(name, string(content), fileType))
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Datasets.Documents.Create(ctx, &coze.CreateDatasetsDocumentsReq{DatasetID: id, DocumentBases: bases})
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(resp.DocumentInfos))
+	for _, doc := range resp.DocumentInfos {
+		rows = append(rows, []string{doc.DocumentID, doc.Name, documentStatus(doc.Status), itoa(doc.Size)})
+	}
+	return a.out.table(resp.DocumentInfos, []string{"ID", "NAME", "STATUS", "SIZE"}, rows)
+}
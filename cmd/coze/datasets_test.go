@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+)
+
+func TestDatasetsAndDocuments(t *testing.T) {
+	server, configPath := newCLI(t)
+	client := coze.NewCozeAPI(coze.NewTokenAuth("token"), coze.WithBaseURL(server.URL))
+	created, err := client.Datasets.Create(context.Background(), &coze.CreateDatasetsReq{Name: "docs", SpaceID: "space"})
+	require.NoError(t, err)
+
+	stdout, _, err := runCLI(t, configPath, "", "datasets", "list", "-space", "space")
+	require.NoError(t, err)
+	assert.Contains(t, stdout, created.DatasetID)
+	assert.Contains(t, stdout, "document")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "readme.md"), []byte("# Hello"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes"), []byte("notes"), 0o600))
+	stdout, _, err = runCLI(t, configPath, "", "documents", "upload", "-dataset", created.DatasetID,
+		filepath.Join(dir, "readme.md"), filepath.Join(dir, "notes"))
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "readme.md")
+
+	stdout, _, err = runCLI(t, configPath, "", "documents", "list", "-dataset", created.DatasetID)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"readme.md", "completed", "7"}, strings.Fields(lines[1])[1:4])
+	assert.Equal(t, []string{"notes", "completed", "5"}, strings.Fields(lines[2])[1:4])
+
+	_, _, err = runCLI(t, configPath, "", "documents", "list", "-dataset", "docs")
+	assert.EqualError(t, err, `invalid dataset ID "docs"`)
+	_, _, err = runCLI(t, configPath, "", "documents", "upload", "-dataset", created.DatasetID)
+	assert.EqualError(t, err, "documents upload: no file to upload")
+}
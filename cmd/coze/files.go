@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/coze-dev/coze-go"
+)
+
+var fileCommands = []*command{
+	{name: "upload", usage: "upload a local file", run: uploadFile},
+	{name: "get", usage: "show an uploaded file", run: getFile},
+}
+
+func printFile(a *app, info any, file *coze.FileInfo) error {
+	return a.out.object(info, [][2]string{
+		{"ID", file.ID},
+		{"Name", file.FileName},
+		{"Bytes", itoa(file.Bytes)},
+		{"Created", formatTime(file.CreatedAt)},
+	})
+}
+
+func uploadFile(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("files upload", "<file>")
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Files.Upload(ctx, &coze.UploadFilesReq{File: coze.NewUploadFile(f, filepath.Base(f.Name()))})
+	if err != nil {
+		return err
+	}
+	return printFile(a, resp, &resp.FileInfo)
+}
+
+func getFile(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("files get", "<file-id>")
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Files.Retrieve(ctx, &coze.RetrieveFilesReq{FileID: flags.Arg(0)})
+	if err != nil {
+		return err
+	}
+	return printFile(a, resp, &resp.FileInfo)
+}
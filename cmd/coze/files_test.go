@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFiles(t *testing.T) {
+	server, configPath := newCLI(t)
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0o600))
+
+	stdout, _, err := runCLI(t, configPath, "", "files", "upload", path)
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "Name:     hello.txt\n")
+	id := regexp.MustCompile(`ID:\s+(\d+)`).FindStringSubmatch(stdout)
+	require.Len(t, id, 2)
+	content, ok := server.File(id[1])
+	require.True(t, ok)
+	assert.Equal(t, "hello", string(content))
+
+	stdout, _, err = runCLI(t, configPath, "", "-output", "json", "files", "get", id[1])
+	require.NoError(t, err)
+	assert.Contains(t, stdout, `"file_name": "hello.txt"`)
+}
+
+func TestAudioSpeak(t *testing.T) {
+	_, configPath := newCLI(t)
+
+	stdout, _, err := runCLI(t, configPath, "", "audio", "speak", "-voice", "voice", "-o", "-", "Hello")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", stdout)
+
+	path := filepath.Join(t.TempDir(), "out", "hello.wav")
+	_, stderr, err := runCLI(t, configPath, "", "audio", "speak", "-voice", "voice", "-format", "wav", "-o", path, "Hello")
+	require.NoError(t, err)
+	assert.Contains(t, stderr, path)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", string(content))
+
+	_, _, err = runCLI(t, configPath, "", "audio", "speak", "Hello")
+	assert.EqualError(t, err, "audio speak: missing -voice")
+}
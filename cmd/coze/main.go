@@ -0,0 +1,187 @@
+// Command coze is a command-line client of the Coze API, built on the SDK.
+//
+//	coze [-profile name] [-output json|table] <command> [flags] [args]
+//
+// The commands are:
+//
+//	auth login|logout                 authorize the profile with its OAuth app
+//	bots list|get|publish             manage the bots of a space
+//	chat                              chat with a bot interactively
+//	workflows run|stream|resume|history
+//	datasets list                     list the datasets of a space
+//	documents list|upload             manage the documents of a dataset
+//	files upload|get                  upload and retrieve files
+//	audio speak|transcribe            text to speech and speech to text
+//
+// The profiles, e.g. one for api.coze.com and one for api.coze.cn, are read from
+// ~/.coze/config.json, see the README of the command.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/coze-dev/coze-go"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	env := &env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, getenv: os.Getenv}
+	if err := run(ctx, os.Args[1:], env); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "coze:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// env is the environment of the command, replaced in tests.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+}
+
+// app is the state shared by the commands of an invocation.
+type app struct {
+	*env
+	profileName string
+	configPath  string
+	out         *printer
+	profile     *coze.Profile
+}
+
+// command is a command, or a group of subcommands.
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, a *app, args []string) error
+	subs  []*command
+}
+
+var commands = []*command{
+	{name: "auth", usage: "authorize the profile with its OAuth app", subs: authCommands},
+	{name: "bots", usage: "manage the bots of a space", subs: botCommands},
+	{name: "chat", usage: "chat with a bot interactively", run: runChat},
+	{name: "workflows", usage: "run workflows and read their history", subs: workflowCommands},
+	{name: "datasets", usage: "list the datasets of a space", subs: datasetCommands},
+	{name: "documents", usage: "manage the documents of a dataset", subs: documentCommands},
+	{name: "files", usage: "upload and retrieve files", subs: fileCommands},
+	{name: "audio", usage: "text to speech and speech to text", subs: audioCommands},
+}
+
+func run(ctx context.Context, args []string, env *env) error {
+	a := &app{env: env}
+	flags := flag.NewFlagSet("coze", flag.ContinueOnError)
+	flags.SetOutput(env.stderr)
+	flags.StringVar(&a.profileName, "profile", env.getenv("COZE_PROFILE"), "profile of the config file")
+	flags.StringVar(&a.configPath, "config", env.getenv("COZE_CONFIG"), "config file, ~/.coze/config.json by default")
+	output := flags.String("output", "table", "output format, json or table")
+	flags.Usage = func() {
+		fmt.Fprintln(env.stderr, "Usage: coze [flags] <command> [flags] [args]\n\nCommands:")
+		printCommands(env.stderr, commands)
+		fmt.Fprintln(env.stderr, "\nFlags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	out, err := newPrinter(env.stdout, *output)
+	if err != nil {
+		return err
+	}
+	a.out = out
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	return dispatch(ctx, a, "coze", commands, flags.Args())
+}
+
+// dispatch runs the command named by the first argument.
+func dispatch(ctx context.Context, a *app, path string, cmds []*command, args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "-help" {
+		fmt.Fprintf(a.stderr, "Usage: %s <command>\n\nCommands:\n", path)
+		printCommands(a.stderr, cmds)
+		return flag.ErrHelp
+	}
+	for _, cmd := range cmds {
+		if cmd.name != args[0] {
+			continue
+		}
+		if cmd.subs != nil {
+			return dispatch(ctx, a, path+" "+cmd.name, cmd.subs, args[1:])
+		}
+		return cmd.run(ctx, a, args[1:])
+	}
+	return fmt.Errorf("unknown command %q, see %s help", args[0], path)
+}
+
+func printCommands(w io.Writer, cmds []*command) {
+	for _, cmd := range cmds {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+// newFlags returns the flag set of a command, whose usage lists its arguments.
+func (a *app) newFlags(name, arguments string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(a.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(a.stderr, "Usage: coze %s [flags] %s\n", name, arguments)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parse parses the flags of a command, and checks that the required ones are set.
+func parse(flags *flag.FlagSet, args []string, required ...string) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	var missing []string
+	for _, name := range required {
+		if !set[name] && flags.Lookup(name).Value.String() == "" {
+			missing = append(missing, "-"+name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%s: missing %s", flags.Name(), strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// parseArgs parses the flags of a command, and checks its number of arguments.
+func parseArgs(flags *flag.FlagSet, args []string, n int, required ...string) error {
+	if err := parse(flags, args, required...); err != nil {
+		return err
+	}
+	if flags.NArg() != n {
+		flags.Usage()
+		return fmt.Errorf("%s: expected %d argument(s), got %d", flags.Name(), n, flags.NArg())
+	}
+	return nil
+}
+
+// client returns the client of the profile.
+func (a *app) client() (*coze.CozeAPI, error) {
+	p, err := a.loadProfile()
+	if err != nil {
+		return nil, err
+	}
+	return newClient(p)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+	"github.com/coze-dev/coze-go/cozetest"
+)
+
+// newCLI starts a server, and writes a config file whose default profile uses it.
+func newCLI(t *testing.T) (*cozetest.Server, string) {
+	server := cozetest.NewServer()
+	t.Cleanup(server.Close)
+	return server, writeConfig(t, &coze.Config{
+		DefaultProfile: "test",
+		Profiles:       map[string]*coze.Profile{"test": {APIBase: server.URL, APIToken: "token"}},
+	})
+}
+
+func writeConfig(t *testing.T, cfg *coze.Config) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// runCLI runs the command with the config file, and returns what it wrote to stdout and stderr.
+func runCLI(t *testing.T, configPath, stdin string, args ...string) (string, string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	e := &env{
+		stdin:  strings.NewReader(stdin),
+		stdout: &stdout,
+		stderr: &stderr,
+		getenv: func(string) string { return "" },
+	}
+	err := run(context.Background(), append([]string{"-config", configPath}, args...), e)
+	return stdout.String(), stderr.String(), err
+}
+
+func TestUsage(t *testing.T) {
+	_, configPath := newCLI(t)
+
+	_, stderr, err := runCLI(t, configPath, "")
+	assert.True(t, errors.Is(err, flag.ErrHelp))
+	assert.Contains(t, stderr, "workflows")
+
+	_, stderr, err = runCLI(t, configPath, "", "bots")
+	assert.True(t, errors.Is(err, flag.ErrHelp))
+	assert.Contains(t, stderr, "publish")
+
+	_, _, err = runCLI(t, configPath, "", "unknown")
+	assert.EqualError(t, err, `unknown command "unknown", see coze help`)
+
+	_, _, err = runCLI(t, configPath, "", "bots", "list")
+	assert.EqualError(t, err, "bots list: missing -space")
+
+	_, _, err = runCLI(t, configPath, "", "-output", "yaml", "bots", "list")
+	assert.EqualError(t, err, `unknown output format "yaml", use json or table`)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// printer writes the results of the commands, as indented JSON or as tables.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case "json":
+		return &printer{w: w, json: true}, nil
+	case "table", "":
+		return &printer{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, use json or table", format)
+	}
+}
+
+// table writes v as JSON, or the rows under the header as a table.
+func (p *printer) table(v any, header []string, rows [][]string) error {
+	if p.json {
+		return p.value(v)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// object writes v as JSON, or its fields as a table of names and values.
+func (p *printer) object(v any, fields [][2]string) error {
+	if p.json {
+		return p.value(v)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	for _, field := range fields {
+		fmt.Fprintf(tw, "%s:\t%s\n", field[0], field[1])
+	}
+	return tw.Flush()
+}
+
+func (p *printer) value(v any) error {
+	encoder := json.NewEncoder(p.w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(v)
+}
+
+// formatTime formats a unix time, empty when it is zero.
+func formatTime[T int | int64](unix T) string {
+	if unix == 0 {
+		return ""
+	}
+	return time.Unix(int64(unix), 0).Format(time.RFC3339)
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/coze-dev/coze-go"
+)
+
+var workflowCommands = []*command{
+	{name: "run", usage: "run a workflow and print its output", run: runWorkflow},
+	{name: "stream", usage: "run a workflow and stream its output", run: streamWorkflow},
+	{name: "resume", usage: "resume an interrupted workflow stream", run: resumeWorkflow},
+	{name: "history", usage: "show the result of a run", run: workflowHistory},
+}
+
+// workflowRunFlags are the flags of the commands which run a workflow.
+type workflowRunFlags struct {
+	workflowID string
+	botID      string
+	parameters string
+}
+
+func (f *workflowRunFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.workflowID, "workflow", "", "workflow ID")
+	flags.StringVar(&f.botID, "bot", "", "bot ID, for the workflows which use a bot")
+	flags.StringVar(&f.parameters, "parameters", "", `parameters as a JSON object, e.g. {"input":"hello"}`)
+}
+
+func (f *workflowRunFlags) request() (*coze.RunWorkflowsReq, error) {
+	req := &coze.RunWorkflowsReq{WorkflowID: f.workflowID, BotID: f.botID}
+	if f.parameters != "" {
+		if err := json.Unmarshal([]byte(f.parameters), &req.Parameters); err != nil {
+			return nil, fmt.Errorf("invalid parameters: %w", err)
+		}
+	}
+	return req, nil
+}
+
+func runWorkflow(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("workflows run", "")
+	runFlags := &workflowRunFlags{}
+	runFlags.register(flags)
+	async := flags.Bool("async", false, "run asynchronously, the result is read with workflows history")
+	if err := parseArgs(flags, args, 0, "workflow"); err != nil {
+		return err
+	}
+	req, err := runFlags.request()
+	if err != nil {
+		return err
+	}
+	req.IsAsync = *async
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Workflows.Runs.Create(ctx, req)
+	if err != nil {
+		return err
+	}
+	return a.out.object(resp, [][2]string{
+		{"Execute ID", resp.ExecuteID},
+		{"Output", resp.Data},
+		{"Debug URL", resp.DebugURL},
+	})
+}
+
+func streamWorkflow(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("workflows stream", "")
+	runFlags := &workflowRunFlags{}
+	runFlags.register(flags)
+	if err := parseArgs(flags, args, 0, "workflow"); err != nil {
+		return err
+	}
+	req, err := runFlags.request()
+	if err != nil {
+		return err
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	stream, err := client.Workflows.Runs.Stream(ctx, req)
+	if err != nil {
+		return err
+	}
+	return a.printWorkflowEvents(req.WorkflowID, stream)
+}
+
+func resumeWorkflow(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("workflows resume", "<resume-data>")
+	workflowID := flags.String("workflow", "", "workflow ID")
+	eventID := flags.String("event", "", "event ID of the interrupt")
+	interruptType := flags.Int("type", 0, "type of the interrupt")
+	if err := parseArgs(flags, args, 1, "event", "workflow"); err != nil {
+		return err
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	stream, err := client.Workflows.Runs.Resume(ctx, &coze.ResumeRunWorkflowsReq{
+		WorkflowID:    *workflowID,
+		EventID:       *eventID,
+		ResumeData:    flags.Arg(0),
+		InterruptType: *interruptType,
+	})
+	if err != nil {
+		return err
+	}
+	return a.printWorkflowEvents(*workflowID, stream)
+}
+
+// printWorkflowEvents prints the messages of the stream, and how to resume it when it is
+// interrupted. With the JSON output, the events are written as JSON lines.
+func (a *app) printWorkflowEvents(workflowID string, stream coze.Stream[coze.WorkflowEvent]) error {
+	defer stream.Close()
+	for {
+		event, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if a.out.json {
+			if err := json.NewEncoder(a.stdout).Encode(event); err != nil {
+				return err
+			}
+			continue
+		}
+		switch {
+		case event.Message != nil:
+			fmt.Fprintln(a.stdout, event.Message.Content)
+		case event.Interrupt != nil:
+			data := event.Interrupt.InterruptData
+			fmt.Fprintf(a.stderr, "Interrupted at %s, resume with:\n  coze workflows resume -workflow %s -event %s -type %d <resume-data>\n",
+				event.Interrupt.NodeTitle, workflowID, data.EventID, data.Type)
+		case event.Error != nil:
+			return fmt.Errorf("workflow failed: %s (code %d)", event.Error.ErrorMessage, event.Error.ErrorCode)
+		}
+	}
+}
+
+func workflowHistory(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlags("workflows history", "")
+	workflowID := flags.String("workflow", "", "workflow ID")
+	executeID := flags.String("execute", "", "execute ID of the run")
+	if err := parseArgs(flags, args, 0, "execute", "workflow"); err != nil {
+		return err
+	}
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Workflows.Runs.Histories.Retrieve(ctx, &coze.RetrieveWorkflowsRunsHistoriesReq{
+		WorkflowID: *workflowID,
+		ExecuteID:  *executeID,
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Histories) == 0 {
+		return fmt.Errorf("run %s not found", *executeID)
+	}
+	history := resp.Histories[0]
+	fields := [][2]string{
+		{"Execute ID", history.ExecuteID},
+		{"Status", string(history.ExecuteStatus)},
+		{"Output", history.Output},
+		{"Created", formatTime(history.CreateTime)},
+		{"Updated", formatTime(history.UpdateTime)},
+		{"Debug URL", history.DebugURL},
+	}
+	if history.ErrorCode != "" && history.ErrorCode != "0" {
+		fields = append(fields, [2]string{"Error", fmt.Sprintf("%s (code %s)", history.ErrorMessage, history.ErrorCode)})
+	}
+	return a.out.object(history, fields)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+	"github.com/coze-dev/coze-go/cozetest"
+)
+
+func TestWorkflows(t *testing.T) {
+	t.Run("run and history", func(t *testing.T) {
+		_, configPath := newCLI(t)
+		stdout, _, err := runCLI(t, configPath, "", "workflows", "run", "-workflow", "wf", "-parameters", `{"city":"Paris"}`)
+		require.NoError(t, err)
+		assert.Contains(t, stdout, `Output:      {"city":"Paris"}`)
+
+		stdout, _, err = runCLI(t, configPath, "", "workflows", "run", "-workflow", "wf", "-async")
+		require.NoError(t, err)
+		executeID := regexp.MustCompile(`Execute ID:\s+(\d+)`).FindStringSubmatch(stdout)
+		require.Len(t, executeID, 2)
+
+		stdout, _, err = runCLI(t, configPath, "", "workflows", "history", "-workflow", "wf", "-execute", executeID[1])
+		require.NoError(t, err)
+		assert.Contains(t, stdout, "Status:      Success")
+		assert.Contains(t, stdout, "Output:      {}")
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		_, configPath := newCLI(t)
+		_, _, err := runCLI(t, configPath, "", "workflows", "run", "-workflow", "wf", "-parameters", "city")
+		assert.ErrorContains(t, err, "invalid parameters")
+	})
+
+	t.Run("stream and resume", func(t *testing.T) {
+		server, configPath := newCLI(t)
+		server.ReplyWorkflow("wf", &cozetest.WorkflowRun{
+			Output:    "Which city?",
+			Interrupt: &coze.WorkflowEventInterrupt{NodeTitle: "Question", InterruptData: &coze.WorkflowEventInterruptData{Type: 2}},
+		})
+
+		stdout, stderr, err := runCLI(t, configPath, "", "workflows", "stream", "-workflow", "wf")
+		require.NoError(t, err)
+		assert.Equal(t, "Which city?\n", stdout)
+		resume := regexp.MustCompile(`coze workflows resume -workflow wf -event (\d+) -type 2`).FindStringSubmatch(stderr)
+		require.Len(t, resume, 2)
+
+		stdout, _, err = runCLI(t, configPath, "", "workflows", "resume", "-workflow", "wf", "-event", resume[1], "-type", "2", "Paris")
+		require.NoError(t, err)
+		assert.Equal(t, "Paris\n", stdout)
+	})
+
+	t.Run("stream error", func(t *testing.T) {
+		server, configPath := newCLI(t)
+		server.ReplyWorkflow("wf", &cozetest.WorkflowRun{Error: &coze.WorkflowEventError{ErrorCode: 4000, ErrorMessage: "bad input"}})
+		_, _, err := runCLI(t, configPath, "", "workflows", "stream", "-workflow", "wf")
+		assert.ErrorContains(t, err, "bad input")
+	})
+}
@@ -0,0 +1,250 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config is a profile file, shared with the coze command and ~/.coze/config.json by default:
+//
+//	{
+//	  "default_profile": "cn",
+//	  "profiles": {
+//	    "cn": {"api_base": "https://api.coze.cn", "api_token": "pat_..."},
+//	    "service": {"oauth": {"client_type": "jwt", "client_id": "...", "public_key_id": "...", "private_key": "..."}}
+//	  }
+//	}
+type Config struct {
+	DefaultProfile string              `json:"default_profile,omitempty"`
+	Profiles       map[string]*Profile `json:"profiles,omitempty"`
+	// TokenDir is the directory of the stored tokens of the OAuth profiles, relative to the
+	// directory of the file. It defaults to the tokens directory beside the file.
+	TokenDir string `json:"token_dir,omitempty"`
+
+	path string
+}
+
+// Profile is an API endpoint and its credentials: a personal access token, or an OAuth app. The
+// token of a Web, PKCE or Device app is obtained by authorizing the app once, and stored in
+// TokenStore.
+type Profile struct {
+	Name     string       `json:"-"`
+	APIBase  string       `json:"api_base,omitempty"`
+	APIToken string       `json:"api_token,omitempty"`
+	OAuth    *OAuthConfig `json:"oauth,omitempty"`
+	// LogLevel is one of trace, debug, info, warn or error.
+	LogLevel string `json:"log_level,omitempty"`
+
+	tokenDir string
+}
+
+// DefaultProfileName is the profile used when neither the caller nor the file selects one.
+const DefaultProfileName = "default"
+
+// builtinProfiles are available without being defined in the file.
+var builtinProfiles = map[string]string{
+	"com": ComBaseURL,
+	"cn":  CnBaseURL,
+}
+
+// DefaultConfigPath returns the path of the profile file: COZE_CONFIG, or ~/.coze/config.json.
+func DefaultConfigPath() string {
+	return defaultConfigPath(os.Getenv)
+}
+
+func defaultConfigPath(getenv func(string) string) string {
+	if path := getenv("COZE_CONFIG"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".coze", "config.json")
+}
+
+// LoadConfig reads the profile file at path, DefaultConfigPath if empty. A missing file is an
+// empty config.
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		path = DefaultConfigPath()
+	}
+	cfg := &Config{path: path}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parse config %s: %w", path, err)
+		}
+	}
+	if cfg.TokenDir == "" {
+		cfg.TokenDir = "tokens"
+	}
+	if !filepath.IsAbs(cfg.TokenDir) {
+		cfg.TokenDir = filepath.Join(filepath.Dir(path), cfg.TokenDir)
+	}
+	return cfg, nil
+}
+
+// Profile returns a copy of the named profile, the default profile of the file if name is empty.
+// The com and cn profiles, for api.coze.com and api.coze.cn, and the default profile are
+// available even if the file does not define them.
+func (c *Config) Profile(name string) (*Profile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		name = DefaultProfileName
+	}
+	var p Profile
+	if defined, ok := c.Profiles[name]; ok && defined != nil {
+		p = *defined
+		if p.OAuth != nil {
+			oauth := *p.OAuth
+			p.OAuth = &oauth
+		}
+	} else if base, ok := builtinProfiles[name]; ok {
+		p.APIBase = base
+	} else if name != DefaultProfileName {
+		if c.path != "" {
+			return nil, fmt.Errorf("unknown profile %q in %s", name, c.path)
+		}
+		return nil, fmt.Errorf("unknown profile %q", name)
+	}
+	p.Name = name
+	p.tokenDir = c.TokenDir
+	return &p, nil
+}
+
+// ApplyEnv overrides the settings of the profile with COZE_API_BASE, COZE_API_TOKEN and
+// COZE_LOG_LEVEL, read with getenv, e.g. os.Getenv. The variables which are empty keep the settings
+// of the profile, and the token is only used by the profiles without an OAuth app.
+func (p *Profile) ApplyEnv(getenv func(string) string) {
+	if base := getenv("COZE_API_BASE"); base != "" {
+		p.APIBase = base
+	}
+	if token := getenv("COZE_API_TOKEN"); token != "" && p.OAuth == nil {
+		p.APIToken = token
+	}
+	if level := getenv("COZE_LOG_LEVEL"); level != "" {
+		p.LogLevel = level
+	}
+}
+
+func (p *Profile) apiBase() string {
+	if p.APIBase == "" {
+		return ComBaseURL
+	}
+	return p.APIBase
+}
+
+// OAuthApp returns the client of the OAuth app of the profile, which uses the API base of the
+// profile unless the app sets its own.
+func (p *Profile) OAuthApp() (*OAuthApp, error) {
+	if p.OAuth == nil {
+		return nil, fmt.Errorf("profile %q has no oauth app", p.Name)
+	}
+	config := *p.OAuth
+	if config.CozeAPIBase == "" {
+		config.CozeAPIBase = p.apiBase()
+	}
+	return LoadOAuthApp(&config)
+}
+
+// TokenStore returns the store of the tokens of the OAuth app of the profile, in the token
+// directory of its file, and the key of its token.
+func (p *Profile) TokenStore() (TokenStore, string) {
+	clientID := ""
+	if p.OAuth != nil {
+		clientID = p.OAuth.ClientID
+	}
+	return NewFileTokenStore(p.tokenDir), TokenStoreKey(clientID, p.Name)
+}
+
+// Auth returns the Auth of the credentials of the profile: its access token, the JWT of its app,
+// or the token of its Web, PKCE or Device app stored in TokenStore, refreshed as it expires.
+func (p *Profile) Auth() (Auth, error) {
+	if p.APIToken != "" {
+		return NewTokenAuth(p.APIToken), nil
+	}
+	if p.OAuth == nil {
+		return nil, fmt.Errorf("profile %q has no credentials, set COZE_API_TOKEN or configure it in the config file", p.Name)
+	}
+	app, err := p.OAuthApp()
+	if err != nil {
+		return nil, err
+	}
+	store, key := p.TokenStore()
+	if app.JWT != nil {
+		return NewJWTAuth(app.JWT, nil, WithTokenStore(store, key)), nil
+	}
+	token, err := store.Get(context.Background(), key)
+	if err != nil {
+		return nil, fmt.Errorf("read the token of profile %q: %w", p.Name, err)
+	}
+	if token == nil {
+		return nil, fmt.Errorf("profile %q has no stored token, authorize its oauth app first", p.Name)
+	}
+	return NewRefreshTokenAuth(token, app.TokenRefresher(), WithTokenStore(store, key)), nil
+}
+
+// NewCozeAPIFromConfig creates a client of the API base of the profile, with its credentials and
+// log level. opts are applied after the settings of the profile.
+func NewCozeAPIFromConfig(profile *Profile, opts ...CozeAPIOption) (CozeAPI, error) {
+	auth, err := profile.Auth()
+	if err != nil {
+		return CozeAPI{}, err
+	}
+	options := []CozeAPIOption{WithBaseURL(profile.apiBase())}
+	if profile.LogLevel != "" {
+		level, err := parseLogLevel(profile.LogLevel)
+		if err != nil {
+			return CozeAPI{}, err
+		}
+		options = append(options, WithLogLevel(level))
+	}
+	return NewCozeAPI(auth, append(options, opts...)...), nil
+}
+
+// NewCozeAPIFromEnv creates a client of the profile COZE_PROFILE of the profile file COZE_CONFIG,
+// see DefaultConfigPath. COZE_API_BASE, COZE_API_TOKEN and COZE_LOG_LEVEL override the profile, so
+// that without a file the client is configured by the environment alone.
+func NewCozeAPIFromEnv(opts ...CozeAPIOption) (CozeAPI, error) {
+	profile, err := loadProfileFromEnv(os.Getenv)
+	if err != nil {
+		return CozeAPI{}, err
+	}
+	return NewCozeAPIFromConfig(profile, opts...)
+}
+
+func loadProfileFromEnv(getenv func(string) string) (*Profile, error) {
+	cfg, err := LoadConfig(defaultConfigPath(getenv))
+	if err != nil {
+		return nil, err
+	}
+	profile, err := cfg.Profile(getenv("COZE_PROFILE"))
+	if err != nil {
+		return nil, err
+	}
+	profile.ApplyEnv(getenv)
+	return profile, nil
+}
+
+func parseLogLevel(s string) (LogLevel, error) {
+	for level := LogLevelTrace; level <= LogLevelError; level++ {
+		if strings.EqualFold(s, level.String()) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid log level %q", s)
+}
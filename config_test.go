@@ -0,0 +1,269 @@
+package coze
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestConfig(t *testing.T, cfg *Config) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.json")
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+		assert.Empty(t, cfg.Profiles)
+		assert.Equal(t, filepath.Join(filepath.Dir(path), "tokens"), cfg.TokenDir)
+	})
+
+	t.Run("relative token dir", func(t *testing.T) {
+		path := writeTestConfig(t, &Config{TokenDir: "cache"})
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(filepath.Dir(path), "cache"), cfg.TokenDir)
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(path, []byte("profiles:"), 0o600))
+		_, err := LoadConfig(path)
+		assert.ErrorContains(t, err, "parse config "+path)
+	})
+}
+
+func TestConfigProfile(t *testing.T) {
+	path := writeTestConfig(t, &Config{
+		DefaultProfile: "work",
+		Profiles: map[string]*Profile{
+			"work":  {APIBase: "https://api.example.com", APIToken: "token"},
+			"oauth": {OAuth: &OAuthConfig{ClientID: "client", ClientType: "device"}},
+		},
+	})
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		profile string
+		want    *Profile
+		err     string
+	}{
+		{name: "default of the file", want: &Profile{Name: "work", APIBase: "https://api.example.com", APIToken: "token"}},
+		{name: "builtin", profile: "cn", want: &Profile{Name: "cn", APIBase: CnBaseURL}},
+		{name: "default", profile: DefaultProfileName, want: &Profile{Name: DefaultProfileName}},
+		{name: "unknown", profile: "other", err: `unknown profile "other" in ` + path},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := cfg.Profile(tt.profile)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			tt.want.tokenDir = cfg.TokenDir
+			assert.Equal(t, tt.want, p)
+		})
+	}
+
+	t.Run("copy", func(t *testing.T) {
+		p, err := cfg.Profile("oauth")
+		require.NoError(t, err)
+		p.OAuth.ClientID = "changed"
+		assert.Equal(t, "client", cfg.Profiles["oauth"].OAuth.ClientID)
+	})
+}
+
+func TestLoadProfileFromEnv(t *testing.T) {
+	path := writeTestConfig(t, &Config{
+		Profiles: map[string]*Profile{
+			"work":  {APIToken: "token", LogLevel: "debug"},
+			"oauth": {OAuth: &OAuthConfig{ClientID: "client", ClientType: "device"}},
+		},
+	})
+	env := map[string]string{
+		"COZE_CONFIG":    path,
+		"COZE_API_TOKEN": "env-token",
+		"COZE_API_BASE":  CnBaseURL,
+		"COZE_LOG_LEVEL": "warn",
+	}
+	getenv := func(key string) string { return env[key] }
+
+	p, err := loadProfileFromEnv(getenv)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultProfileName, p.Name)
+	assert.Equal(t, CnBaseURL, p.APIBase)
+	assert.Equal(t, "env-token", p.APIToken)
+	assert.Equal(t, "warn", p.LogLevel)
+
+	// the environment overrides the profile
+	env["COZE_PROFILE"] = "work"
+	p, err = loadProfileFromEnv(getenv)
+	require.NoError(t, err)
+	assert.Equal(t, CnBaseURL, p.APIBase)
+	assert.Equal(t, "env-token", p.APIToken)
+	assert.Equal(t, "warn", p.LogLevel)
+
+	// the empty variables keep the settings of the profile
+	env["COZE_API_TOKEN"], env["COZE_LOG_LEVEL"] = "", ""
+	p, err = loadProfileFromEnv(getenv)
+	require.NoError(t, err)
+	assert.Equal(t, "token", p.APIToken)
+	assert.Equal(t, "debug", p.LogLevel)
+	env["COZE_API_TOKEN"] = "env-token"
+
+	// the token of the environment is not used for an oauth app
+	env["COZE_PROFILE"] = "oauth"
+	p, err = loadProfileFromEnv(getenv)
+	require.NoError(t, err)
+	assert.Empty(t, p.APIToken)
+
+	env["COZE_PROFILE"] = "other"
+	_, err = loadProfileFromEnv(getenv)
+	assert.Error(t, err)
+}
+
+// authServer answers the token requests of the OAuth apps with a new access token, and the
+// retrieval of files with the access token of the request.
+type authServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	grants  []string
+	counter int
+}
+
+func newAuthServer(t *testing.T) *authServer {
+	s := &authServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/permission/oauth2/token":
+			var req struct {
+				GrantType string `json:"grant_type"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			s.mu.Lock()
+			s.grants = append(s.grants, req.GrantType)
+			s.counter++
+			token := &OAuthToken{
+				AccessToken:  "oauth-token-" + strconv.Itoa(s.counter),
+				RefreshToken: "refresh-" + strconv.Itoa(s.counter),
+				ExpiresIn:    time.Now().Add(time.Hour).Unix(),
+			}
+			s.mu.Unlock()
+			_ = json.NewEncoder(w).Encode(token)
+		case "/v1/files/retrieve":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"code": 0,
+				"data": map[string]string{"id": r.Header.Get("Authorization")},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *authServer) authorization(t *testing.T, client CozeAPI) string {
+	file, err := client.Files.Retrieve(context.Background(), &RetrieveFilesReq{FileID: "file"})
+	require.NoError(t, err)
+	return file.ID
+}
+
+func TestNewCozeAPIFromConfig(t *testing.T) {
+	server := newAuthServer(t)
+	cfg, err := LoadConfig(filepath.Join(t.TempDir(), "config.json"))
+	require.NoError(t, err)
+
+	t.Run("token", func(t *testing.T) {
+		p := &Profile{APIBase: server.URL, APIToken: "token", LogLevel: "ERROR"}
+		client, err := NewCozeAPIFromConfig(p)
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer token", server.authorization(t, client))
+
+		_, err = NewCozeAPIFromConfig(&Profile{APIToken: "token", LogLevel: "verbose"})
+		assert.EqualError(t, err, `invalid log level "verbose"`)
+	})
+
+	t.Run("no credentials", func(t *testing.T) {
+		_, err := NewCozeAPIFromConfig(&Profile{Name: "p"})
+		assert.EqualError(t, err, `profile "p" has no credentials, set COZE_API_TOKEN or configure it in the config file`)
+	})
+
+	t.Run("jwt", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		require.NoError(t, err)
+		cfg.Profiles = map[string]*Profile{"jwt": {APIBase: server.URL, OAuth: &OAuthConfig{
+			ClientID:    "jwt-client",
+			ClientType:  "jwt",
+			PublicKeyID: "public-key",
+			PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		}}}
+		p, err := cfg.Profile("jwt")
+		require.NoError(t, err)
+
+		client, err := NewCozeAPIFromConfig(p)
+		require.NoError(t, err)
+		authorization := server.authorization(t, client)
+		assert.Regexp(t, `^Bearer oauth-token-\d+$`, authorization)
+
+		// the token is stored, and reused by the next client
+		client, err = NewCozeAPIFromConfig(p)
+		require.NoError(t, err)
+		assert.Equal(t, authorization, server.authorization(t, client))
+	})
+
+	t.Run("stored refresh token", func(t *testing.T) {
+		cfg.Profiles = map[string]*Profile{"device": {APIBase: server.URL, OAuth: &OAuthConfig{
+			ClientID:   "device-client",
+			ClientType: "device",
+		}}}
+		p, err := cfg.Profile("device")
+		require.NoError(t, err)
+		_, err = NewCozeAPIFromConfig(p)
+		assert.EqualError(t, err, `profile "device" has no stored token, authorize its oauth app first`)
+
+		store, key := p.TokenStore()
+		require.NoError(t, store.Set(context.Background(), key, &OAuthToken{
+			AccessToken:  "expired",
+			RefreshToken: "refresh",
+			ExpiresIn:    time.Now().Add(-time.Minute).Unix(),
+		}))
+		client, err := NewCozeAPIFromConfig(p)
+		require.NoError(t, err)
+		authorization := server.authorization(t, client)
+		assert.Regexp(t, `^Bearer oauth-token-\d+$`, authorization)
+		server.mu.Lock()
+		assert.Equal(t, "refresh_token", server.grants[len(server.grants)-1])
+		server.mu.Unlock()
+
+		// the refreshed token is stored
+		stored, err := store.Get(context.Background(), key)
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer "+stored.AccessToken, authorization)
+	})
+}
@@ -0,0 +1,44 @@
+package cozetest
+
+import (
+	"net/http"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// speechContentTypes are the content types of the audio formats, mp3 by default.
+var speechContentTypes = map[coze.AudioFormat]string{
+	coze.AudioFormatWAV:     "audio/wav",
+	coze.AudioFormatPCM:     "audio/pcm",
+	coze.AudioFormatOGGOPUS: "audio/ogg",
+	coze.AudioFormatM4A:     "audio/mp4",
+	coze.AudioFormatAAC:     "audio/aac",
+	coze.AudioFormatMP3:     "audio/mpeg",
+}
+
+func (s *Server) registerAudio() {
+	s.handle(http.MethodPost, "/v1/audio/speech", s.createSpeech)
+}
+
+// createSpeech answers with the input as the audio, so that tests can check what was synthesized.
+func (s *Server) createSpeech(c *call) {
+	req := &coze.CreateAudioSpeechReq{}
+	if !c.decode(req) {
+		return
+	}
+	if req.Input == "" || req.VoiceID == "" {
+		c.invalid("input and voice_id are required")
+		return
+	}
+	format := coze.AudioFormatMP3
+	if req.ResponseFormat != nil {
+		format = *req.ResponseFormat
+	}
+	contentType, ok := speechContentTypes[format]
+	if !ok {
+		c.invalid("unsupported response_format " + string(format))
+		return
+	}
+	c.w.Header().Set("Content-Type", contentType)
+	_, _ = c.w.Write([]byte(req.Input))
+}
@@ -0,0 +1,174 @@
+package cozetest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/coze-dev/coze-go"
+)
+
+type bot struct {
+	coze.Bot
+	spaceID     string
+	publishedAt int64
+}
+
+// AddBot adds a published bot to the space, and returns its ID. An ID is generated when the bot
+// has none.
+func (s *Server) AddBot(spaceID string, b *coze.Bot) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	added := &bot{Bot: *b, spaceID: spaceID, publishedAt: now()}
+	if added.BotID == "" {
+		added.BotID = s.newIDLocked()
+	}
+	if added.Version == "" {
+		added.Version = "1"
+	}
+	s.addBotLocked(added)
+	return added.BotID
+}
+
+func (s *Server) addBotLocked(b *bot) {
+	if _, ok := s.bots[b.BotID]; !ok {
+		s.botOrder = append(s.botOrder, b.BotID)
+	}
+	s.bots[b.BotID] = b
+}
+
+func (s *Server) registerBots() {
+	s.handle(http.MethodPost, "/v1/bot/create", s.createBot)
+	s.handle(http.MethodPost, "/v1/bot/update", s.updateBot)
+	s.handle(http.MethodPost, "/v1/bot/publish", s.publishBot)
+	s.handle(http.MethodGet, "/v1/bot/get_online_info", s.retrieveBot)
+	s.handle(http.MethodGet, "/v1/space/published_bots_list", s.listBots)
+}
+
+func (s *Server) createBot(c *call) {
+	req := &coze.CreateBotsReq{}
+	if !c.decode(req) {
+		return
+	}
+	if req.SpaceID == "" || req.Name == "" {
+		c.invalid("space_id and name are required")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := &bot{
+		Bot: coze.Bot{
+			BotID:          s.newIDLocked(),
+			Name:           req.Name,
+			Description:    req.Description,
+			CreateTime:     now(),
+			UpdateTime:     now(),
+			PromptInfo:     req.PromptInfo,
+			OnboardingInfo: req.OnboardingInfo,
+		},
+		spaceID: req.SpaceID,
+	}
+	if req.ModelInfoConfig != nil {
+		b.ModelInfo = &coze.BotModelInfo{ModelID: req.ModelInfoConfig.ModelID}
+	}
+	s.addBotLocked(b)
+	c.data(map[string]string{"bot_id": b.BotID})
+}
+
+func (s *Server) updateBot(c *call) {
+	req := &coze.UpdateBotsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.bots[req.BotID]
+	if !ok {
+		c.notFound("bot", req.BotID)
+		return
+	}
+	if req.Name != "" {
+		b.Name = req.Name
+	}
+	if req.Description != "" {
+		b.Description = req.Description
+	}
+	if req.PromptInfo != nil {
+		b.PromptInfo = req.PromptInfo
+	}
+	if req.OnboardingInfo != nil {
+		b.OnboardingInfo = req.OnboardingInfo
+	}
+	if req.ModelInfoConfig != nil {
+		b.ModelInfo = &coze.BotModelInfo{ModelID: req.ModelInfoConfig.ModelID}
+	}
+	b.UpdateTime = now()
+	c.data(map[string]any{})
+}
+
+func (s *Server) publishBot(c *call) {
+	req := &coze.PublishBotsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.bots[req.BotID]
+	if !ok {
+		c.notFound("bot", req.BotID)
+		return
+	}
+	version, _ := strconv.Atoi(b.Version)
+	b.Version = strconv.Itoa(version + 1)
+	b.publishedAt = now()
+	c.data(map[string]string{"bot_id": b.BotID, "version": b.Version})
+}
+
+// retrieveBot returns the online information of a bot, which only exists once it is published.
+func (s *Server) retrieveBot(c *call) {
+	id := c.query("bot_id")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.bots[id]
+	if !ok || b.publishedAt == 0 {
+		c.notFound("published bot", id)
+		return
+	}
+	c.data(b.Bot)
+}
+
+func (s *Server) listBots(c *call) {
+	spaceID := c.query("space_id")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var published []*coze.SimpleBot
+	for _, id := range s.botOrder {
+		b := s.bots[id]
+		if b.spaceID != spaceID || b.publishedAt == 0 {
+			continue
+		}
+		published = append(published, &coze.SimpleBot{
+			BotID:       b.BotID,
+			BotName:     b.Name,
+			Description: b.Description,
+			IconURL:     b.IconURL,
+			PublishTime: strconv.FormatInt(b.publishedAt, 10),
+		})
+	}
+	c.data(map[string]any{
+		"space_bots": page(published, c.queryInt("page_index", 1), c.queryInt("page_size", 20)),
+		"total":      len(published),
+	})
+}
+
+// page returns the items of the page pageNum, numbered from 1.
+func page[T any](items []T, pageNum, pageSize int) []T {
+	start := (pageNum - 1) * pageSize
+	if start >= len(items) {
+		return []T{}
+	}
+	end := start + pageSize
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
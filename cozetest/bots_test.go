@@ -0,0 +1,44 @@
+package cozetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+)
+
+func TestBots(t *testing.T) {
+	server, client := newClient(t, nil)
+	ctx := context.Background()
+	server.AddBot("space", &coze.Bot{Name: "existing"})
+
+	created, err := client.Bots.Create(ctx, &coze.CreateBotsReq{SpaceID: "space", Name: "assistant"})
+	require.NoError(t, err)
+
+	// a bot is only online once published
+	_, err = client.Bots.Retrieve(ctx, &coze.RetrieveBotsReq{BotID: created.BotID})
+	assert.True(t, errors.Is(err, coze.ErrNotFound))
+
+	_, err = client.Bots.Update(ctx, &coze.UpdateBotsReq{BotID: created.BotID, Name: "renamed"})
+	require.NoError(t, err)
+	published, err := client.Bots.Publish(ctx, &coze.PublishBotsReq{BotID: created.BotID, ConnectorIDs: []string{"1024"}})
+	require.NoError(t, err)
+	assert.NotEmpty(t, published.BotVersion)
+
+	retrieved, err := client.Bots.Retrieve(ctx, &coze.RetrieveBotsReq{BotID: created.BotID})
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", retrieved.Name)
+
+	paged, err := client.Bots.List(ctx, &coze.ListBotsReq{SpaceID: "space", PageNum: 1, PageSize: 1})
+	require.NoError(t, err)
+	var names []string
+	for paged.Next() {
+		names = append(names, paged.Current().BotName)
+	}
+	require.NoError(t, paged.Err())
+	assert.Equal(t, []string{"existing", "renamed"}, names)
+}
@@ -0,0 +1,434 @@
+package cozetest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// RecordEnv is the environment variable which makes UseCassette record the cassettes instead of
+// replaying them.
+const RecordEnv = "COZE_RECORD"
+
+// ErrUnmatchedRequest is returned by a replaying Cassette for a request which matches none of its
+// interactions.
+var ErrUnmatchedRequest = errors.New("cozetest: no recorded interaction matches the request")
+
+// scrubbedHeaders are the headers whose values are never written to a cassette.
+var scrubbedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// defaultScrubber redacts the credentials from the bodies and queries. Unlike the default rules of
+// the clients, the content of the messages is kept so that the replayed responses are complete.
+var defaultScrubber = coze.NewRedactor(
+	coze.WithoutDefaultRedactRules(),
+	coze.WithRedactKeys("access_token", "refresh_token", "client_secret", "private_key", "code_verifier", "device_code"),
+	coze.WithRedactPatterns(
+		regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+		regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/=-]+`),
+	),
+)
+
+// CassetteOption configures a Cassette.
+type CassetteOption func(*cassetteOption)
+
+type cassetteOption struct {
+	scrubber *coze.Redactor
+}
+
+// WithScrubber replaces the redactor which scrubs the secrets from the recorded queries and
+// bodies. The replayed requests are scrubbed with it too before being matched.
+func WithScrubber(scrubber *coze.Redactor) CassetteOption {
+	return func(o *cassetteOption) {
+		o.scrubber = scrubber
+	}
+}
+
+// Interaction is a request and its response, as written to a cassette.
+type Interaction struct {
+	Request  *RecordedRequest  `json:"request"`
+	Response *RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a recorded request. Its query is sorted and its JSON body normalized, the
+// parts of a multipart upload are recorded in Form instead of Body.
+type RecordedRequest struct {
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	Query      string      `json:"query,omitempty"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 string      `json:"body_base64,omitempty"`
+	Form       []*FormPart `json:"form,omitempty"`
+}
+
+// FormPart is a part of a recorded multipart upload.
+type FormPart struct {
+	Name       string `json:"name"`
+	FileName   string `json:"file_name,omitempty"`
+	Body       string `json:"body,omitempty"`
+	BodyBase64 string `json:"body_base64,omitempty"`
+}
+
+// RecordedResponse is a recorded response. Streamed responses are recorded whole, binary bodies
+// are encoded in base64.
+type RecordedResponse struct {
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 string      `json:"body_base64,omitempty"`
+}
+
+type cassetteFile struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Cassette is a coze.HTTPClient which records the requests sent with another client, or replays
+// the recorded responses. It is safe for concurrent use.
+type Cassette struct {
+	path     string
+	client   coze.HTTPClient
+	scrubber *coze.Redactor
+	t        testing.TB
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+}
+
+func newCassette(path string, opts []CassetteOption) *Cassette {
+	opt := &cassetteOption{scrubber: defaultScrubber}
+	for _, option := range opts {
+		option(opt)
+	}
+	return &Cassette{path: path, scrubber: opt.scrubber}
+}
+
+// NewRecorder returns a Cassette which sends the requests with client, http.DefaultClient when it
+// is nil, and records them. Save writes them to path.
+func NewRecorder(path string, client coze.HTTPClient, opts ...CassetteOption) *Cassette {
+	c := newCassette(path, opts)
+	c.client = client
+	if c.client == nil {
+		c.client = http.DefaultClient
+	}
+	return c
+}
+
+// LoadCassette returns a Cassette which replays the interactions recorded at path. Each
+// interaction is replayed once, in the order they were recorded, so that repeated requests, e.g.
+// polls, get the successive responses.
+func LoadCassette(path string, opts ...CassetteOption) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+	file := &cassetteFile{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+	}
+	c := newCassette(path, opts)
+	c.interactions = file.Interactions
+	c.used = make([]bool, len(file.Interactions))
+	return c, nil
+}
+
+// UseCassette returns a Cassette for the test, which replays the cassette at path, or records it
+// with client when the RecordEnv environment variable is set. The test fails when the cassette
+// can not be loaded or saved, and for every request which is not matched.
+func UseCassette(t testing.TB, path string, client coze.HTTPClient, opts ...CassetteOption) *Cassette {
+	t.Helper()
+	if os.Getenv(RecordEnv) != "" {
+		c := NewRecorder(path, client, opts...)
+		c.t = t
+		t.Cleanup(func() {
+			if err := c.Save(); err != nil {
+				t.Errorf("save cassette: %v", err)
+			}
+		})
+		return c
+	}
+	c, err := LoadCassette(path, opts...)
+	if err != nil {
+		t.Fatalf("%v, set %s=1 to record it", err, RecordEnv)
+	}
+	c.t = t
+	return c
+}
+
+// Recording reports whether the cassette records the requests.
+func (c *Cassette) Recording() bool {
+	return c.client != nil
+}
+
+// Interactions returns the recorded interactions.
+func (c *Cassette) Interactions() []*Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Interaction(nil), c.interactions...)
+}
+
+// Do implements coze.HTTPClient.
+func (c *Cassette) Do(req *http.Request) (*http.Response, error) {
+	recorded, err := c.recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if c.Recording() {
+		return c.record(req, recorded)
+	}
+	return c.replay(req, recorded)
+}
+
+// Save writes the recorded interactions to the cassette file. Streamed responses are saved as far
+// as they were read, they should be closed before.
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	data, err := json.MarshalIndent(&cassetteFile{Interactions: c.interactions}, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("marshal cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("create cassette directory: %w", err)
+	}
+	if err := os.WriteFile(c.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write cassette: %w", err)
+	}
+	return nil
+}
+
+func (c *Cassette) record(req *http.Request, recorded *RecordedRequest) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	interaction := &Interaction{
+		Request:  recorded,
+		Response: &RecordedResponse{Status: resp.StatusCode, Header: c.scrubHeader(resp.Header)},
+	}
+	interaction.Response.Header.Del("Content-Length")
+	c.mu.Lock()
+	c.interactions = append(c.interactions, interaction)
+	c.mu.Unlock()
+
+	// the body is recorded as it is read, so that streams are not delayed
+	resp.Body = &recordingBody{ReadCloser: resp.Body, done: func(body []byte) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		interaction.Response.Body, interaction.Response.BodyBase64 = encodeBody(c.scrubber.Redact(string(body)))
+	}}
+	return resp, nil
+}
+
+func (c *Cassette) replay(req *http.Request, recorded *RecordedRequest) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, interaction := range c.interactions {
+		if c.used[i] || !matchRequest(interaction.Request, recorded) {
+			continue
+		}
+		c.used[i] = true
+		return interaction.Response.httpResponse(req)
+	}
+
+	err := fmt.Errorf("%w: %s %s", ErrUnmatchedRequest, recorded.Method, recorded.Path)
+	if recorded.Query != "" {
+		err = fmt.Errorf("%w?%s", err, recorded.Query)
+	}
+	if recorded.Body != "" {
+		err = fmt.Errorf("%w with body %s", err, recorded.Body)
+	}
+	if c.t != nil {
+		c.t.Errorf("%v (cassette %s)", err, c.path)
+	}
+	return nil, err
+}
+
+// recordRequest returns the request as it is recorded, and restores its body so that it can still
+// be sent.
+func (c *Cassette) recordRequest(req *http.Request) (*RecordedRequest, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("read request body: %w", err)
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	recorded := &RecordedRequest{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  c.scrubber.Redact(req.URL.Query().Encode()),
+		Header: c.scrubHeader(req.Header),
+	}
+	mediaType, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "multipart/") {
+		form, err := readForm(body, params["boundary"])
+		if err != nil {
+			return nil, err
+		}
+		recorded.Form = form
+		return recorded, nil
+	}
+	recorded.Body, recorded.BodyBase64 = encodeBody(c.scrubber.Redact(normalizeJSON(body)))
+	return recorded, nil
+}
+
+func (c *Cassette) scrubHeader(header http.Header) http.Header {
+	scrubbed := header.Clone()
+	if scrubbed == nil {
+		scrubbed = http.Header{}
+	}
+	for _, key := range scrubbedHeaders {
+		if scrubbed.Get(key) != "" {
+			scrubbed.Set(key, "[REDACTED]")
+		}
+	}
+	return scrubbed
+}
+
+// readForm returns the parts of a multipart body, sorted by name since the fields of an upload are
+// written in no particular order.
+func readForm(body []byte, boundary string) ([]*FormPart, error) {
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	var form []*FormPart
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read multipart body: %w", err)
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return nil, fmt.Errorf("read multipart body: %w", err)
+		}
+		formPart := &FormPart{Name: part.FormName(), FileName: part.FileName()}
+		formPart.Body, formPart.BodyBase64 = encodeBody(string(content))
+		form = append(form, formPart)
+	}
+	sort.SliceStable(form, func(i, j int) bool {
+		return form[i].Name < form[j].Name
+	})
+	return form, nil
+}
+
+// normalizeJSON returns the body with its keys sorted and no whitespace when it is JSON, unchanged
+// otherwise.
+func normalizeJSON(body []byte) string {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil || decoder.More() {
+		return string(body)
+	}
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return string(body)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// encodeBody returns the body as text, or encoded in base64 when it is binary.
+func encodeBody(body string) (string, string) {
+	if utf8.ValidString(body) {
+		return body, ""
+	}
+	return "", base64.StdEncoding.EncodeToString([]byte(body))
+}
+
+func decodeBody(body, bodyBase64 string) ([]byte, error) {
+	if bodyBase64 == "" {
+		return []byte(body), nil
+	}
+	return base64.StdEncoding.DecodeString(bodyBase64)
+}
+
+// matchRequest reports whether the request matches the recorded one, by method, path, query and
+// body. The headers are not matched.
+func matchRequest(recorded, req *RecordedRequest) bool {
+	if recorded.Method != req.Method || recorded.Path != req.Path || recorded.Query != req.Query {
+		return false
+	}
+	if recorded.Body != req.Body || recorded.BodyBase64 != req.BodyBase64 || len(recorded.Form) != len(req.Form) {
+		return false
+	}
+	for i, part := range recorded.Form {
+		if *part != *req.Form[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *RecordedResponse) httpResponse(req *http.Request) (*http.Response, error) {
+	body, err := decodeBody(r.Body, r.BodyBase64)
+	if err != nil {
+		return nil, fmt.Errorf("decode recorded body: %w", err)
+	}
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// recordingBody records a response body as it is read, and passes it to done once it is read
+// entirely or closed.
+type recordingBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	once sync.Once
+	done func([]byte)
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+func (b *recordingBody) finish() {
+	b.once.Do(func() {
+		b.done(b.buf.Bytes())
+	})
+}
@@ -0,0 +1,180 @@
+package cozetest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// session runs a few calls, a streamed chat and a file upload among them, and returns their results.
+func session(t *testing.T, client coze.CozeAPI) []string {
+	ctx := context.Background()
+	conv, err := client.Conversations.Create(ctx, &coze.CreateConversationsReq{BotID: "bot", MetaData: map[string]string{"a": "1", "b": "2"}})
+	require.NoError(t, err)
+
+	stream, err := client.Chat.Stream(ctx, &coze.CreateChatsReq{
+		BotID:          "bot",
+		ConversationID: conv.ID,
+		Messages:       []*coze.Message{coze.BuildUserQuestionText("Hi", nil)},
+	})
+	require.NoError(t, err)
+	events, err := recvAll[coze.ChatEvent](t, stream)
+	require.NoError(t, err)
+
+	uploaded, err := client.Files.Upload(ctx, &coze.UploadFilesReq{File: coze.NewUploadFile(strings.NewReader("hello"), "hello.txt")})
+	require.NoError(t, err)
+
+	return []string{conv.ID, events[len(events)-2].Chat.ID, uploaded.ID, uploaded.FileName}
+}
+
+func TestCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassettes", "session.json")
+	server := NewServer()
+	defer server.Close()
+
+	recorder := NewRecorder(path, nil)
+	assert.True(t, recorder.Recording())
+	recorded := session(t, coze.NewCozeAPI(coze.NewTokenAuth("secret-token"),
+		coze.WithBaseURL(server.URL), coze.WithHttpClient(recorder)))
+	require.NoError(t, recorder.Save())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret-token")
+	interactions := recorder.Interactions()
+	require.Len(t, interactions, 3)
+	assert.Equal(t, "[REDACTED]", interactions[0].Request.Header.Get("Authorization"))
+	assert.Contains(t, interactions[1].Response.Body, "event: conversation.chat.completed")
+	require.Len(t, interactions[2].Request.Form, 1)
+	assert.Equal(t, &FormPart{Name: "file", FileName: "hello.txt", Body: "hello"}, interactions[2].Request.Form[0])
+
+	// the replay does not reach the server, which is closed
+	server.Close()
+	player, err := LoadCassette(path)
+	require.NoError(t, err)
+	assert.False(t, player.Recording())
+	replayed := session(t, coze.NewCozeAPI(coze.NewTokenAuth("other-token"),
+		coze.WithBaseURL("https://api.coze.invalid"), coze.WithHttpClient(player)))
+	assert.Equal(t, recorded, replayed)
+
+	// every interaction is replayed once
+	_, err = coze.NewCozeAPI(coze.NewTokenAuth("token"), coze.WithHttpClient(player)).
+		Conversations.Create(context.Background(), &coze.CreateConversationsReq{BotID: "bot", MetaData: map[string]string{"a": "1", "b": "2"}})
+	assert.True(t, errors.Is(err, ErrUnmatchedRequest))
+}
+
+func TestCassetteMatching(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"interactions": [{
+		"request": {"method": "POST", "path": "/v1/run", "query": "a=1&b=2", "body": "{\"x\":1,\"y\":[\"z\"]}"},
+		"response": {"status": 200, "body_base64": "/wA="}
+	}]}`), 0o644))
+
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		body    string
+		matched bool
+	}{
+		{"normalized body and query", http.MethodPost, "https://api.coze.com/v1/run?b=2&a=1", `{ "y": ["z"], "x": 1 }`, true},
+		{"other method", http.MethodPut, "https://api.coze.com/v1/run?a=1&b=2", `{"x":1,"y":["z"]}`, false},
+		{"other path", http.MethodPost, "https://api.coze.com/v1/other?a=1&b=2", `{"x":1,"y":["z"]}`, false},
+		{"other query", http.MethodPost, "https://api.coze.com/v1/run?a=1", `{"x":1,"y":["z"]}`, false},
+		{"other body", http.MethodPost, "https://api.coze.com/v1/run?a=1&b=2", `{"x":2,"y":["z"]}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player, err := LoadCassette(path)
+			require.NoError(t, err)
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			resp, err := player.Do(req)
+			if !tt.matched {
+				assert.True(t, errors.Is(err, ErrUnmatchedRequest))
+				return
+			}
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, []byte{0xff, 0x00}, body)
+		})
+	}
+}
+
+func TestCassetteScrubbing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder := NewRecorder(path, &mockDoer{body: `{"access_token":"at-123","refresh_token":"rt-456","expires_in":900}`})
+
+	req, err := http.NewRequest(http.MethodPost, "https://api.coze.com/api/permission/oauth2/token?client_secret=cs-789",
+		strings.NewReader(`{"grant_type":"refresh_token","refresh_token":"rt-000"}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer jwt-abc")
+	resp, err := recorder.Do(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	// the caller gets the response as it is
+	assert.Contains(t, string(body), "at-123")
+	require.NoError(t, recorder.Save())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for _, secret := range []string{"at-123", "rt-456", "cs-789", "rt-000", "jwt-abc"} {
+		assert.NotContains(t, string(data), secret)
+	}
+
+	// the replayed request is scrubbed before being matched
+	player, err := LoadCassette(path)
+	require.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPost, "https://api.coze.com/api/permission/oauth2/token?client_secret=other",
+		strings.NewReader(`{"refresh_token":"rt-111","grant_type":"refresh_token"}`))
+	require.NoError(t, err)
+	resp, err = player.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"access_token":"[REDACTED]","refresh_token":"[REDACTED]","expires_in":900}`, string(body))
+}
+
+func TestUseCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recordAndReplay := func(t *testing.T) {
+		cassette := UseCassette(t, path, &mockDoer{body: `{"code":0,"msg":"","data":{"id":"1"}}`})
+		_, err := coze.NewCozeAPI(coze.NewTokenAuth("token"), coze.WithHttpClient(cassette)).
+			Conversations.Retrieve(context.Background(), &coze.RetrieveConversationsReq{ConversationID: "1"})
+		assert.NoError(t, err)
+	}
+
+	t.Setenv(RecordEnv, "1")
+	t.Run("record", recordAndReplay)
+	require.FileExists(t, path)
+	t.Setenv(RecordEnv, "")
+	t.Run("replay", recordAndReplay)
+}
+
+type mockDoer struct {
+	body string
+}
+
+func (d *mockDoer) Do(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(d.body)),
+		Request:    req,
+	}, nil
+}
@@ -0,0 +1,500 @@
+package cozetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// ChatReply scripts the reply of a chat, see Server.ReplyChat.
+type ChatReply struct {
+	// Answer is the text answered by the bot, streamed in deltas of a word.
+	Answer string
+	// ReasoningContent is the reasoning of the bot, streamed before the answer.
+	ReasoningContent string
+	// ToolCalls make the chat require action. The chat continues with the next reply once the tool
+	// outputs are submitted.
+	ToolCalls []*coze.ChatToolCall
+	// Error fails the chat.
+	Error *coze.ChatError
+	// StreamError ends the stream with an error event, and fails the chat.
+	StreamError *coze.ChatError
+	// Usage is the usage of the completed chat, by default the number of characters of the
+	// question and of the answer.
+	Usage *coze.ChatUsage
+	// Polls is the number of retrievals which report the chat in progress before it finishes.
+	Polls int
+	// DisconnectAfter drops the connection of the stream after that number of events, the chat
+	// still finishes on the server.
+	DisconnectAfter int
+	// Events are streamed instead of the events generated from the reply. The IDs missing in their
+	// chat and messages are filled, and the chat finishes in the state of the last chat event.
+	Events []*coze.ChatEvent
+}
+
+// ReplyChat queues the replies of the next chats with the bot, or with the workflow of a workflow
+// chat. A chat which requires action takes the next reply once the tool outputs are submitted.
+//
+// Without a queued reply, a chat answers "You said: " followed by the last question, and a chat
+// continued with tool outputs answers the outputs, one per line.
+func (s *Server) ReplyChat(botID string, replies ...*ChatReply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chatReplies[botID] = append(s.chatReplies[botID], replies...)
+}
+
+// Chat returns the current state of a chat.
+func (s *Server) Chat(chatID string) (*coze.Chat, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.chats[chatID]
+	if !ok {
+		return nil, false
+	}
+	chat := state.current()
+	return &chat, true
+}
+
+func (s *Server) nextChatReplyLocked(key, answer string) *ChatReply {
+	replies := s.chatReplies[key]
+	if len(replies) == 0 {
+		return &ChatReply{Answer: answer}
+	}
+	s.chatReplies[key] = replies[1:]
+	return replies[0]
+}
+
+type chatState struct {
+	// key is the bot or workflow whose replies the chat takes.
+	key  string
+	conv *conversation
+	// final is the chat once the reply has run, its messages are pending until then.
+	final   coze.Chat
+	pending []*coze.Message
+	polls   int
+}
+
+// current returns the chat as seen by the client, in progress while polls remain.
+func (st *chatState) current() coze.Chat {
+	chat := st.final
+	if st.polls > 0 {
+		chat.Status = coze.ChatStatusInProgress
+		chat.CompletedAt, chat.FailedAt = 0, 0
+		chat.RequiredAction, chat.Usage, chat.LastError = nil, nil, nil
+	}
+	return chat
+}
+
+// finishLocked saves the pending messages of the chat once it is finished.
+func (s *Server) finishLocked(st *chatState) {
+	if st.polls > 0 {
+		return
+	}
+	for _, message := range st.pending {
+		s.addMessageLocked(st.conv, message, st.final.BotID, st.final.ID)
+	}
+	st.pending = nil
+}
+
+type sseEvent struct {
+	id    string
+	event string
+	data  any
+}
+
+func (s *Server) registerChats() {
+	s.handle(http.MethodPost, "/v3/chat", s.createChat)
+	s.handle(http.MethodGet, "/v3/chat/retrieve", s.retrieveChat)
+	s.handle(http.MethodPost, "/v3/chat/cancel", s.cancelChat)
+	s.handle(http.MethodPost, "/v3/chat/submit_tool_outputs", s.submitToolOutputs)
+	s.handle(http.MethodGet, "/v3/chat/message/list", s.listChatMessages)
+	s.handle(http.MethodPost, "/v1/workflows/chat", s.workflowChat)
+}
+
+func (s *Server) createChat(c *call) {
+	req := &coze.CreateChatsReq{}
+	if !c.decode(req) {
+		return
+	}
+	if req.BotID == "" {
+		c.invalid("bot_id is required")
+		return
+	}
+	stream := req.Stream != nil && *req.Stream
+	saveHistory := req.AutoSaveHistory == nil || *req.AutoSaveHistory
+	s.startChat(c, req.BotID, req.BotID, c.query("conversation_id"), req.Messages, req.MetaData, stream, saveHistory)
+}
+
+func (s *Server) workflowChat(c *call) {
+	req := &coze.WorkflowsChatStreamReq{}
+	if !c.decode(req) {
+		return
+	}
+	if req.WorkflowID == "" {
+		c.invalid("workflow_id is required")
+		return
+	}
+	botID, conversationID := "", ""
+	if req.BotID != nil {
+		botID = *req.BotID
+	}
+	if req.ConversationID != nil {
+		conversationID = *req.ConversationID
+	}
+	s.startChat(c, req.WorkflowID, botID, conversationID, req.AdditionalMessages, nil, true, true)
+}
+
+// startChat creates a chat in the conversation, a new one when conversationID is empty, and runs
+// the next reply of key.
+func (s *Server) startChat(c *call, key, botID, conversationID string, messages []*coze.Message, metaData map[string]string, stream, saveHistory bool) {
+	s.mu.Lock()
+	var conv *conversation
+	if conversationID == "" {
+		conv = s.newConversationLocked(botID, nil)
+	} else if found, ok := s.conversationLocked(c, conversationID); ok {
+		conv = found
+	} else {
+		s.mu.Unlock()
+		return
+	}
+
+	chat := coze.Chat{
+		ID:             s.newIDLocked(),
+		ConversationID: conv.ID,
+		BotID:          botID,
+		CreatedAt:      int(now()),
+		MetaData:       metaData,
+		Status:         coze.ChatStatusCreated,
+	}
+	question := ""
+	for _, message := range messages {
+		if saveHistory {
+			s.addMessageLocked(conv, message, botID, chat.ID)
+		}
+		if message.Role == coze.MessageRoleUser || message.Role == "" {
+			question = message.Content
+		}
+	}
+	reply := s.nextChatReplyLocked(key, "You said: "+question)
+	st, events := s.runChatLocked(conv, chat, reply, utf8.RuneCountInString(question))
+	st.key = key
+	s.chats[chat.ID] = st
+	s.finishLocked(st)
+	s.mu.Unlock()
+
+	if stream {
+		c.stream(events, reply.DisconnectAfter)
+		return
+	}
+	c.data(chat)
+}
+
+// runChatLocked runs the reply, and returns the state of the chat once finished and the events
+// of its stream.
+func (s *Server) runChatLocked(conv *conversation, chat coze.Chat, reply *ChatReply, inputCount int) (*chatState, []*sseEvent) {
+	st := &chatState{conv: conv, final: chat, polls: reply.Polls}
+	if reply.Events != nil {
+		return st, s.scriptedChatEventsLocked(st, reply.Events)
+	}
+
+	var events []*sseEvent
+	emitChat := func(event coze.ChatEventType, chat coze.Chat) {
+		events = append(events, &sseEvent{event: string(event), data: chat})
+	}
+	chat.Status = coze.ChatStatusCreated
+	emitChat(coze.ChatEventConversationChatCreated, chat)
+	chat.Status = coze.ChatStatusInProgress
+	emitChat(coze.ChatEventConversationChatInProgress, chat)
+
+	if reply.StreamError != nil {
+		events = append(events, &sseEvent{event: string(coze.ChatEventError), data: reply.StreamError})
+		st.final = s.failedChat(chat, reply.StreamError)
+		return st, events
+	}
+
+	if reply.ReasoningContent != "" || reply.Answer != "" {
+		answer := &coze.Message{
+			ID:             s.newIDLocked(),
+			ConversationID: conv.ID,
+			SectionID:      conv.LastSectionID,
+			BotID:          chat.BotID,
+			ChatID:         chat.ID,
+			Role:           coze.MessageRoleAssistant,
+			Type:           coze.MessageTypeAnswer,
+			ContentType:    coze.MessageContentTypeText,
+		}
+		for _, delta := range splitWords(reply.ReasoningContent) {
+			message := *answer
+			message.ReasoningContent = delta
+			events = append(events, &sseEvent{event: string(coze.ChatEventConversationMessageDelta), data: &message})
+		}
+		for _, delta := range splitWords(reply.Answer) {
+			message := *answer
+			message.Content = delta
+			events = append(events, &sseEvent{event: string(coze.ChatEventConversationMessageDelta), data: &message})
+		}
+		answer.Content = reply.Answer
+		answer.ReasoningContent = reply.ReasoningContent
+		answer.CreatedAt = now()
+		answer.UpdatedAt = answer.CreatedAt
+		events = append(events, &sseEvent{event: string(coze.ChatEventConversationMessageCompleted), data: answer})
+		st.pending = append(st.pending, answer)
+	}
+
+	switch {
+	case len(reply.ToolCalls) > 0:
+		calls := make([]*coze.ChatToolCall, 0, len(reply.ToolCalls))
+		for _, call := range reply.ToolCalls {
+			copied := *call
+			if copied.ID == "" {
+				copied.ID = s.newIDLocked()
+			}
+			if copied.Type == "" {
+				copied.Type = "function"
+			}
+			calls = append(calls, &copied)
+			message := &coze.Message{
+				ID:             s.newIDLocked(),
+				ConversationID: conv.ID,
+				SectionID:      conv.LastSectionID,
+				BotID:          chat.BotID,
+				ChatID:         chat.ID,
+				Role:           coze.MessageRoleAssistant,
+				Type:           coze.MessageTypeFunctionCall,
+				Content:        mustJSON(&copied),
+				ContentType:    coze.MessageContentTypeText,
+			}
+			events = append(events, &sseEvent{event: string(coze.ChatEventConversationMessageCompleted), data: message})
+			st.pending = append(st.pending, message)
+		}
+		chat.Status = coze.ChatStatusRequiresAction
+		chat.RequiredAction = &coze.ChatRequiredAction{
+			Type:              "submit_tool_outputs",
+			SubmitToolOutputs: &coze.ChatSubmitToolOutputs{ToolCalls: calls},
+		}
+		emitChat(coze.ChatEventConversationChatRequiresAction, chat)
+	case reply.Error != nil:
+		chat = s.failedChat(chat, reply.Error)
+		emitChat(coze.ChatEventConversationChatFailed, chat)
+	default:
+		chat.Status = coze.ChatStatusCompleted
+		chat.CompletedAt = int(now())
+		chat.Usage = reply.Usage
+		if chat.Usage == nil {
+			output := utf8.RuneCountInString(reply.Answer)
+			chat.Usage = &coze.ChatUsage{InputCount: inputCount, OutputCount: output, TokenCount: inputCount + output}
+		}
+		emitChat(coze.ChatEventConversationChatCompleted, chat)
+	}
+	events = append(events, &sseEvent{event: string(coze.ChatEventDone), data: `"[DONE]"`})
+	st.final = chat
+	return st, events
+}
+
+func (s *Server) failedChat(chat coze.Chat, chatErr *coze.ChatError) coze.Chat {
+	chat.Status = coze.ChatStatusFailed
+	chat.FailedAt = int(now())
+	chat.LastError = chatErr
+	return chat
+}
+
+// scriptedChatEventsLocked fills the IDs of the scripted events, and finishes the chat in the state
+// of the last chat event.
+func (s *Server) scriptedChatEventsLocked(st *chatState, scripted []*coze.ChatEvent) []*sseEvent {
+	events := make([]*sseEvent, 0, len(scripted))
+	for _, event := range scripted {
+		var data any
+		switch {
+		case event.Chat != nil:
+			chat := *event.Chat
+			fillString(&chat.ID, st.final.ID)
+			fillString(&chat.ConversationID, st.final.ConversationID)
+			fillString(&chat.BotID, st.final.BotID)
+			st.final = chat
+			data = chat
+		case event.Message != nil:
+			message := *event.Message
+			fillString(&message.ConversationID, st.final.ConversationID)
+			fillString(&message.ChatID, st.final.ID)
+			fillString(&message.BotID, st.final.BotID)
+			if event.Event == coze.ChatEventConversationMessageCompleted {
+				fillString(&message.ID, s.newIDLocked())
+				completed := message
+				st.pending = append(st.pending, &completed)
+			}
+			data = &message
+		case event.Error != nil:
+			data = map[string]any{"code": event.Error.Code, "msg": event.Error.Message}
+		case event.WorkflowDebug != nil:
+			data = event.WorkflowDebug
+		case event.Event == coze.ChatEventDone:
+			data = `"[DONE]"`
+		default:
+			data = "{}"
+		}
+		events = append(events, &sseEvent{event: string(event.Event), data: data})
+	}
+	return events
+}
+
+func fillString(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+// stream writes the events, and drops the connection after disconnectAfter events when it is set.
+func (c *call) stream(events []*sseEvent, disconnectAfter int) {
+	w := c.sse()
+	for _, event := range events {
+		if disconnectAfter > 0 && w.written >= disconnectAfter {
+			w.disconnect()
+		}
+		if !w.event(event.id, event.event, event.data) {
+			return
+		}
+	}
+}
+
+// chatLocked returns the chat of the conversation_id and chat_id queries, or answers with a not
+// found error.
+func (s *Server) chatLocked(c *call, conversationID, chatID string) (*chatState, bool) {
+	st, ok := s.chats[chatID]
+	if !ok || st.final.ConversationID != conversationID {
+		c.notFound("chat", chatID)
+		return nil, false
+	}
+	return st, true
+}
+
+// retrieveChat returns the chat, which finishes once its polls are consumed.
+func (s *Server) retrieveChat(c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.chatLocked(c, c.query("conversation_id"), c.query("chat_id"))
+	if !ok {
+		return
+	}
+	chat := st.current()
+	if st.polls > 0 {
+		st.polls--
+		s.finishLocked(st)
+	}
+	c.data(chat)
+}
+
+func (s *Server) cancelChat(c *call) {
+	req := &coze.CancelChatsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.chatLocked(c, req.ConversationID, req.ChatID)
+	if !ok {
+		return
+	}
+	if st.polls == 0 {
+		c.invalid(fmt.Sprintf("chat %s is not in progress", req.ChatID))
+		return
+	}
+	st.polls = 0
+	st.pending = nil
+	chat := st.current()
+	chat.Status = coze.ChatStatusCancelled
+	chat.CompletedAt, chat.FailedAt = 0, 0
+	chat.RequiredAction, chat.Usage, chat.LastError = nil, nil, nil
+	st.final = chat
+	c.data(chat)
+}
+
+// submitToolOutputs continues a chat which requires action with the next reply.
+func (s *Server) submitToolOutputs(c *call) {
+	req := &coze.SubmitToolOutputsChatReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	st, ok := s.chatLocked(c, c.query("conversation_id"), c.query("chat_id"))
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	if st.current().Status != coze.ChatStatusRequiresAction {
+		s.mu.Unlock()
+		c.invalid(fmt.Sprintf("chat %s does not require action", st.final.ID))
+		return
+	}
+	chat := st.final
+	chat.Status = coze.ChatStatusInProgress
+	chat.RequiredAction = nil
+	outputs := make([]string, 0, len(req.ToolOutputs))
+	for _, output := range req.ToolOutputs {
+		outputs = append(outputs, output.Output)
+		s.addMessageLocked(st.conv, &coze.Message{
+			Role:        coze.MessageRoleAssistant,
+			Type:        coze.MessageTypeToolResponse,
+			Content:     output.Output,
+			ContentType: coze.MessageContentTypeText,
+		}, chat.BotID, chat.ID)
+	}
+	reply := s.nextChatReplyLocked(st.key, strings.Join(outputs, "\n"))
+	next, events := s.runChatLocked(st.conv, chat, reply, 0)
+	next.key = st.key
+	s.chats[chat.ID] = next
+	s.finishLocked(next)
+	s.mu.Unlock()
+
+	if req.Stream != nil && *req.Stream {
+		c.stream(events, reply.DisconnectAfter)
+		return
+	}
+	c.data(chat)
+}
+
+func (s *Server) listChatMessages(c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.chatLocked(c, c.query("conversation_id"), c.query("chat_id"))
+	if !ok {
+		return
+	}
+	messages := []*coze.Message{}
+	for _, message := range st.conv.messages {
+		if message.ChatID == st.final.ID && message.Role == coze.MessageRoleAssistant && message.Type != coze.MessageTypeToolResponse {
+			messages = append(messages, message)
+		}
+	}
+	c.data(messages)
+}
+
+// splitWords splits s in deltas of a word and its following spaces.
+func splitWords(s string) []string {
+	var words []string
+	for s != "" {
+		i := strings.IndexAny(s, " \n")
+		if i < 0 {
+			words = append(words, s)
+			break
+		}
+		end := i + 1
+		for end < len(s) && (s[end] == ' ' || s[end] == '\n') {
+			end++
+		}
+		words = append(words, s[:end])
+		s = s[end:]
+	}
+	return words
+}
+
+func mustJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
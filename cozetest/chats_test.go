@@ -0,0 +1,299 @@
+package cozetest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+)
+
+var fastPoll = coze.WithPollInterval(time.Millisecond, time.Millisecond)
+
+type eventStream[T any] interface {
+	Recv() (*T, error)
+	Close() error
+}
+
+func recvAll[T any](t *testing.T, stream eventStream[T]) ([]*T, error) {
+	t.Helper()
+	defer stream.Close()
+	var events []*T
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+}
+
+func chatEventTypes(events []*coze.ChatEvent) []coze.ChatEventType {
+	types := make([]coze.ChatEventType, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Event)
+	}
+	return types
+}
+
+func TestChatCreateAndPoll(t *testing.T) {
+	server, client := newClient(t, nil)
+	ctx := context.Background()
+	server.ReplyChat("bot", &ChatReply{Answer: "Hello there", Polls: 2})
+
+	result, err := client.Chat.CreateAndPoll(ctx, &coze.CreateChatsReq{
+		BotID:    "bot",
+		UserID:   "user",
+		Messages: []*coze.Message{coze.BuildUserQuestionText("Hi", nil)},
+	}, nil, fastPoll)
+	require.NoError(t, err)
+	assert.Equal(t, coze.ChatStatusCompleted, result.Chat.Status)
+	assert.Equal(t, &coze.ChatUsage{InputCount: 2, OutputCount: 11, TokenCount: 13}, result.Chat.Usage)
+	require.Len(t, result.Messages, 1)
+	assert.Equal(t, "Hello there", result.Messages[0].Content)
+	assert.Equal(t, coze.MessageTypeAnswer, result.Messages[0].Type)
+
+	// the question and the answer are saved in the conversation
+	messages := server.Messages(result.Chat.ConversationID)
+	require.Len(t, messages, 2)
+	assert.Equal(t, "Hi", messages[0].Content)
+	assert.Equal(t, result.Chat.ID, messages[0].ChatID)
+
+	// without a reply the question is echoed
+	result, err = client.Chat.CreateAndPoll(ctx, &coze.CreateChatsReq{
+		ConversationID: result.Chat.ConversationID,
+		BotID:          "bot",
+		Messages:       []*coze.Message{coze.BuildUserQuestionText("again", nil)},
+	}, nil, fastPoll)
+	require.NoError(t, err)
+	assert.Equal(t, "You said: again", result.Messages[0].Content)
+	assert.Len(t, server.Messages(result.Chat.ConversationID), 4)
+}
+
+func TestChatFailed(t *testing.T) {
+	server, client := newClient(t, nil)
+	server.ReplyChat("bot", &ChatReply{Error: &coze.ChatError{Code: 5000, Msg: "model overloaded"}})
+
+	_, err := client.Chat.CreateAndPoll(context.Background(), &coze.CreateChatsReq{
+		BotID:    "bot",
+		Messages: []*coze.Message{coze.BuildUserQuestionText("Hi", nil)},
+	}, nil, fastPoll)
+	failed, ok := coze.AsChatFailedError(err)
+	require.True(t, ok)
+	assert.Equal(t, "model overloaded", failed.Chat.LastError.Msg)
+}
+
+func TestChatCancel(t *testing.T) {
+	server, client := newClient(t, nil)
+	ctx := context.Background()
+	server.ReplyChat("bot", &ChatReply{Answer: "never", Polls: 1000})
+
+	timeout := 1
+	result, err := client.Chat.CreateAndPoll(ctx, &coze.CreateChatsReq{
+		BotID:    "bot",
+		Messages: []*coze.Message{coze.BuildUserQuestionText("Hi", nil)},
+	}, &timeout, coze.WithPollInterval(100*time.Millisecond, 100*time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, coze.ChatStatusCancelled, result.Chat.Status)
+	assert.Empty(t, result.Messages)
+
+	// a finished chat can not be canceled
+	chat, ok := server.Chat(result.Chat.ID)
+	require.True(t, ok)
+	assert.Equal(t, coze.ChatStatusCancelled, chat.Status)
+	_, err = client.Chat.Cancel(ctx, &coze.CancelChatsReq{ConversationID: chat.ConversationID, ChatID: chat.ID})
+	assert.True(t, errors.Is(err, coze.ErrInvalidParameter))
+}
+
+func TestChatStream(t *testing.T) {
+	server, client := newClient(t, nil)
+	server.ReplyChat("bot", &ChatReply{ReasoningContent: "thinking", Answer: "Hello there"})
+
+	stream, err := client.Chat.Stream(context.Background(), &coze.CreateChatsReq{
+		BotID:    "bot",
+		Messages: []*coze.Message{coze.BuildUserQuestionText("Hi", nil)},
+	})
+	require.NoError(t, err)
+	events, err := recvAll[coze.ChatEvent](t, stream)
+	require.NoError(t, err)
+	assert.Equal(t, []coze.ChatEventType{
+		coze.ChatEventConversationChatCreated,
+		coze.ChatEventConversationChatInProgress,
+		coze.ChatEventConversationMessageDelta,
+		coze.ChatEventConversationMessageDelta,
+		coze.ChatEventConversationMessageDelta,
+		coze.ChatEventConversationMessageCompleted,
+		coze.ChatEventConversationChatCompleted,
+		coze.ChatEventDone,
+	}, chatEventTypes(events))
+	assert.Equal(t, "thinking", events[2].Message.ReasoningContent)
+	assert.Equal(t, "Hello ", events[3].Message.Content)
+	assert.Equal(t, "there", events[4].Message.Content)
+	assert.Equal(t, events[3].Message.ID, events[5].Message.ID)
+	assert.Equal(t, "Hello there", events[5].Message.Content)
+}
+
+func TestChatStreamError(t *testing.T) {
+	server, client := newClient(t, nil)
+	server.ReplyChat("bot", &ChatReply{StreamError: &coze.ChatError{Code: 4013, Msg: "rate limited"}})
+
+	stream, err := client.Chat.Stream(context.Background(), &coze.CreateChatsReq{BotID: "bot"})
+	require.NoError(t, err)
+	events, err := recvAll[coze.ChatEvent](t, stream)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, coze.ChatEventError, events[2].Event)
+	assert.True(t, errors.Is(events[2].Error, coze.ErrRateLimited))
+	assert.NotEmpty(t, events[2].Error.LogID)
+
+	// returned from Recv with WithStreamErrorsReturned
+	server, client = newClient(t, nil, coze.WithStreamErrorsReturned(true))
+	server.ReplyChat("bot", &ChatReply{StreamError: &coze.ChatError{Code: 4013, Msg: "rate limited"}})
+
+	stream, err = client.Chat.Stream(context.Background(), &coze.CreateChatsReq{BotID: "bot"})
+	require.NoError(t, err)
+	events, err = recvAll[coze.ChatEvent](t, stream)
+	assert.Len(t, events, 2)
+	assert.True(t, errors.Is(err, coze.ErrRateLimited))
+	cozeErr, ok := coze.AsCozeError(err)
+	require.True(t, ok)
+	assert.NotEmpty(t, cozeErr.LogID)
+}
+
+func TestChatScriptedEvents(t *testing.T) {
+	server, client := newClient(t, nil)
+	server.ReplyChat("bot", &ChatReply{Events: []*coze.ChatEvent{
+		{Event: coze.ChatEventConversationChatCreated, Chat: &coze.Chat{Status: coze.ChatStatusCreated}},
+		{Event: coze.ChatEventConversationMessageCompleted, Message: &coze.Message{
+			Role: coze.MessageRoleAssistant, Type: coze.MessageTypeFollowUp, Content: "Anything else?",
+		}},
+		{Event: coze.ChatEventConversationChatCompleted, Chat: &coze.Chat{Status: coze.ChatStatusCompleted}},
+		{Event: coze.ChatEventDone},
+	}})
+
+	stream, err := client.Chat.Stream(context.Background(), &coze.CreateChatsReq{BotID: "bot"})
+	require.NoError(t, err)
+	events, err := recvAll[coze.ChatEvent](t, stream)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	chatID := events[0].Chat.ID
+	assert.NotEmpty(t, chatID)
+	assert.Equal(t, chatID, events[1].Message.ChatID)
+	assert.Equal(t, "bot", events[2].Chat.BotID)
+
+	chat, ok := server.Chat(chatID)
+	require.True(t, ok)
+	assert.Equal(t, coze.ChatStatusCompleted, chat.Status)
+	messages := server.Messages(chat.ConversationID)
+	require.Len(t, messages, 1)
+	assert.Equal(t, coze.MessageTypeFollowUp, messages[0].Type)
+}
+
+func TestChatStreamResumable(t *testing.T) {
+	server, client := newClient(t, nil)
+	server.ReplyChat("bot", &ChatReply{Answer: "one two three", DisconnectAfter: 4})
+
+	stream, err := client.Chat.StreamResumable(context.Background(), &coze.CreateChatsReq{BotID: "bot"})
+	require.NoError(t, err)
+	events, err := recvAll[coze.ChatEvent](t, stream)
+	require.NoError(t, err)
+	assert.Equal(t, []coze.ChatEventType{
+		coze.ChatEventConversationChatCreated,
+		coze.ChatEventConversationChatInProgress,
+		coze.ChatEventConversationMessageDelta,
+		coze.ChatEventConversationMessageDelta,
+		coze.ChatEventConversationMessageDelta,
+		coze.ChatEventConversationMessageCompleted,
+		coze.ChatEventConversationChatCompleted,
+		coze.ChatEventDone,
+	}, chatEventTypes(events))
+	assert.Equal(t, "three", events[4].Message.Content)
+}
+
+func TestChatTools(t *testing.T) {
+	weather := func() *coze.ChatToolCall {
+		return &coze.ChatToolCall{Function: &coze.ChatToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}}
+	}
+	registry := coze.NewToolRegistry()
+	coze.RegisterTool(registry, "get_weather", func(ctx context.Context, args struct{ City string }) (string, error) {
+		return "sunny in " + args.City, nil
+	})
+
+	t.Run("poll", func(t *testing.T) {
+		server, client := newClient(t, nil)
+		server.ReplyChat("bot", &ChatReply{ToolCalls: []*coze.ChatToolCall{weather()}})
+
+		result, err := client.Chat.RunWithTools(context.Background(), &coze.CreateChatsReq{
+			BotID:    "bot",
+			Messages: []*coze.Message{coze.BuildUserQuestionText("Weather?", nil)},
+		}, registry, fastPoll)
+		require.NoError(t, err)
+		assert.Equal(t, coze.ChatStatusCompleted, result.Chat.Status)
+		answers := result.MessagesOfType(coze.MessageTypeAnswer)
+		require.Len(t, answers, 1)
+		assert.Equal(t, "sunny in Paris", answers[0].Content)
+		assert.Len(t, result.MessagesOfType(coze.MessageTypeFunctionCall), 1)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		server, client := newClient(t, nil)
+		server.ReplyChat("bot",
+			&ChatReply{ToolCalls: []*coze.ChatToolCall{weather()}},
+			&ChatReply{Answer: "It is sunny"},
+		)
+
+		stream, err := client.Chat.StreamWithTools(context.Background(), &coze.CreateChatsReq{BotID: "bot"}, registry)
+		require.NoError(t, err)
+		events, err := recvAll[coze.ChatEvent](t, stream)
+		require.NoError(t, err)
+		accumulator := &coze.ChatStreamAccumulator{}
+		for _, event := range events {
+			accumulator.Add(event)
+		}
+		result := accumulator.Result()
+		assert.Equal(t, coze.ChatStatusCompleted, result.Chat.Status)
+		assert.Equal(t, "It is sunny", result.MessagesOfType(coze.MessageTypeAnswer)[0].Content)
+
+		requests := server.Requests()
+		assert.Equal(t, "/v3/chat/submit_tool_outputs", requests[len(requests)-1].Path)
+		assert.Contains(t, string(requests[len(requests)-1].Body), "sunny in Paris")
+	})
+
+	t.Run("not requiring action", func(t *testing.T) {
+		_, client := newClient(t, nil)
+		stream, err := client.Chat.Stream(context.Background(), &coze.CreateChatsReq{BotID: "bot"})
+		require.NoError(t, err)
+		events, err := recvAll[coze.ChatEvent](t, stream)
+		require.NoError(t, err)
+		chat := events[0].Chat
+		_, err = client.Chat.SubmitToolOutputs(context.Background(), &coze.SubmitToolOutputsChatReq{
+			ConversationID: chat.ConversationID,
+			ChatID:         chat.ID,
+			ToolOutputs:    []*coze.ToolOutput{{ToolCallID: "1", Output: "x"}},
+		})
+		assert.True(t, errors.Is(err, coze.ErrInvalidParameter))
+	})
+}
+
+func TestWorkflowChat(t *testing.T) {
+	server, client := newClient(t, nil)
+	server.ReplyChat("wf", &ChatReply{Answer: "from the workflow"})
+
+	stream, err := client.Workflows.Chat.Stream(context.Background(), &coze.WorkflowsChatStreamReq{
+		WorkflowID:         "wf",
+		AdditionalMessages: []*coze.Message{coze.BuildUserQuestionText("Hi", nil)},
+	})
+	require.NoError(t, err)
+	events, err := recvAll[coze.ChatEvent](t, stream)
+	require.NoError(t, err)
+	require.Len(t, events, 8)
+	assert.Equal(t, "from the workflow", events[5].Message.Content)
+}
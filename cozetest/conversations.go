@@ -0,0 +1,311 @@
+package cozetest
+
+import (
+	"net/http"
+
+	"github.com/coze-dev/coze-go"
+)
+
+type conversation struct {
+	coze.Conversation
+	botID    string
+	messages []*coze.Message
+}
+
+// Messages returns the messages of a conversation, in the order they were created.
+func (s *Server) Messages(conversationID string) []*coze.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conv, ok := s.conversations[conversationID]
+	if !ok {
+		return nil
+	}
+	messages := make([]*coze.Message, 0, len(conv.messages))
+	for _, message := range conv.messages {
+		copied := *message
+		messages = append(messages, &copied)
+	}
+	return messages
+}
+
+func (s *Server) registerConversations() {
+	s.handle(http.MethodGet, "/v1/conversations", s.listConversations)
+	s.handle(http.MethodPost, "/v1/conversation/create", s.createConversation)
+	s.handle(http.MethodGet, "/v1/conversation/retrieve", s.retrieveConversation)
+	s.handle(http.MethodPost, "/v1/conversations/:id/clear", s.clearConversation)
+	s.handle(http.MethodPost, "/v1/conversation/message/create", s.createMessage)
+	s.handle(http.MethodPost, "/v1/conversation/message/list", s.listMessages)
+	s.handle(http.MethodGet, "/v1/conversation/message/retrieve", s.retrieveMessage)
+	s.handle(http.MethodPost, "/v1/conversation/message/modify", s.updateMessage)
+	s.handle(http.MethodPost, "/v1/conversation/message/delete", s.deleteMessage)
+}
+
+func (s *Server) newConversationLocked(botID string, metaData map[string]string) *conversation {
+	conv := &conversation{
+		Conversation: coze.Conversation{
+			ID:            s.newIDLocked(),
+			CreatedAt:     int(now()),
+			MetaData:      metaData,
+			LastSectionID: s.newIDLocked(),
+		},
+		botID: botID,
+	}
+	s.conversations[conv.ID] = conv
+	s.convOrder = append(s.convOrder, conv.ID)
+	return conv
+}
+
+// addMessageLocked adds a copy of message to the conversation, with its IDs and times set.
+func (s *Server) addMessageLocked(conv *conversation, message *coze.Message, botID, chatID string) *coze.Message {
+	added := *message
+	fillString(&added.ID, s.newIDLocked())
+	added.ConversationID = conv.ID
+	added.SectionID = conv.LastSectionID
+	added.BotID = botID
+	added.ChatID = chatID
+	if added.CreatedAt == 0 {
+		added.CreatedAt = now()
+		added.UpdatedAt = added.CreatedAt
+	}
+	if added.Role == "" {
+		added.Role = coze.MessageRoleUser
+	}
+	if added.ContentType == "" {
+		added.ContentType = coze.MessageContentTypeText
+	}
+	conv.messages = append(conv.messages, &added)
+	return &added
+}
+
+func (s *Server) listConversations(c *call) {
+	botID := c.query("bot_id")
+	pageNum, pageSize := c.queryInt("page_num", 1), c.queryInt("page_size", 20)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var conversations []*coze.Conversation
+	// the most recent conversations first
+	for i := len(s.convOrder) - 1; i >= 0; i-- {
+		conv := s.conversations[s.convOrder[i]]
+		if conv.botID == botID {
+			copied := conv.Conversation
+			conversations = append(conversations, &copied)
+		}
+	}
+	c.data(map[string]any{
+		"conversations": page(conversations, pageNum, pageSize),
+		"has_more":      pageNum*pageSize < len(conversations),
+	})
+}
+
+func (s *Server) createConversation(c *call) {
+	req := &coze.CreateConversationsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conv := s.newConversationLocked(req.BotID, req.MetaData)
+	for _, message := range req.Messages {
+		s.addMessageLocked(conv, message, "", "")
+	}
+	c.data(conv.Conversation)
+}
+
+func (s *Server) retrieveConversation(c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conv, ok := s.conversationLocked(c, c.query("conversation_id"))
+	if !ok {
+		return
+	}
+	c.data(conv.Conversation)
+}
+
+// clearConversation starts a new section of the conversation, the messages are kept.
+func (s *Server) clearConversation(c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conv, ok := s.conversationLocked(c, c.params[0])
+	if !ok {
+		return
+	}
+	conv.LastSectionID = s.newIDLocked()
+	c.data(map[string]string{"id": conv.LastSectionID, "conversation_id": conv.ID})
+}
+
+// conversationLocked returns the conversation, or answers with a not found error.
+func (s *Server) conversationLocked(c *call, id string) (*conversation, bool) {
+	conv, ok := s.conversations[id]
+	if !ok {
+		c.notFound("conversation", id)
+	}
+	return conv, ok
+}
+
+// messageLocked returns the message of the conversation, or answers with a not found error.
+func (s *Server) messageLocked(c *call) (*conversation, int, bool) {
+	conv, ok := s.conversationLocked(c, c.query("conversation_id"))
+	if !ok {
+		return nil, 0, false
+	}
+	id := c.query("message_id")
+	for i, message := range conv.messages {
+		if message.ID == id {
+			return conv, i, true
+		}
+	}
+	c.notFound("message", id)
+	return nil, 0, false
+}
+
+func (s *Server) createMessage(c *call) {
+	req := &coze.CreateMessageReq{}
+	if !c.decode(req) {
+		return
+	}
+	if req.Role == "" || req.Content == "" {
+		c.invalid("role and content are required")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conv, ok := s.conversationLocked(c, c.query("conversation_id"))
+	if !ok {
+		return
+	}
+	message := &coze.Message{
+		Role:        req.Role,
+		Type:        coze.MessageTypeQuestion,
+		Content:     req.Content,
+		ContentType: req.ContentType,
+		MetaData:    req.MetaData,
+	}
+	if req.Role == coze.MessageRoleAssistant {
+		message.Type = coze.MessageTypeAnswer
+	}
+	c.data(s.addMessageLocked(conv, message, "", ""))
+}
+
+// listMessages lists the messages from the most recent by default, a page following the one ending
+// with after_id, or preceding the one starting with before_id.
+func (s *Server) listMessages(c *call) {
+	req := &coze.ListConversationsMessagesReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conv, ok := s.conversationLocked(c, c.query("conversation_id"))
+	if !ok {
+		return
+	}
+
+	var ordered []*coze.Message
+	for _, message := range conv.messages {
+		if req.ChatID != nil && message.ChatID != *req.ChatID {
+			continue
+		}
+		if req.BotID != nil && message.BotID != *req.BotID {
+			continue
+		}
+		ordered = append(ordered, message)
+	}
+	if req.Order == nil || *req.Order != "asc" {
+		for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		}
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	start, end := 0, len(ordered)
+	if req.AfterID != nil && *req.AfterID != "" {
+		start = indexOfMessage(ordered, *req.AfterID) + 1
+	}
+	if req.BeforeID != nil && *req.BeforeID != "" {
+		end = indexOfMessage(ordered, *req.BeforeID)
+		if end < 0 {
+			end = 0
+		}
+		if end-limit > start {
+			start = end - limit
+		}
+	}
+	if start+limit < end {
+		end = start + limit
+	}
+	if start > end {
+		start = end
+	}
+	messages := append([]*coze.Message{}, ordered[start:end]...)
+
+	firstID, lastID := "", ""
+	if len(messages) > 0 {
+		firstID, lastID = messages[0].ID, messages[len(messages)-1].ID
+	}
+	c.flat(map[string]any{
+		"data":     messages,
+		"first_id": firstID,
+		"last_id":  lastID,
+		"has_more": end < len(ordered),
+	})
+}
+
+func indexOfMessage(messages []*coze.Message, id string) int {
+	for i, message := range messages {
+		if message.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) retrieveMessage(c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conv, i, ok := s.messageLocked(c)
+	if !ok {
+		return
+	}
+	c.data(conv.messages[i])
+}
+
+func (s *Server) updateMessage(c *call) {
+	req := &coze.UpdateConversationMessagesReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conv, i, ok := s.messageLocked(c)
+	if !ok {
+		return
+	}
+	message := conv.messages[i]
+	if req.Content != "" {
+		message.Content = req.Content
+	}
+	if req.ContentType != "" {
+		message.ContentType = req.ContentType
+	}
+	if req.MetaData != nil {
+		message.MetaData = req.MetaData
+	}
+	message.UpdatedAt = now()
+	// the modified message is returned in the message field, not data
+	c.flat(map[string]any{"message": message})
+}
+
+func (s *Server) deleteMessage(c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conv, i, ok := s.messageLocked(c)
+	if !ok {
+		return
+	}
+	message := conv.messages[i]
+	conv.messages = append(conv.messages[:i], conv.messages[i+1:]...)
+	c.data(message)
+}
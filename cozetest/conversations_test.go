@@ -0,0 +1,129 @@
+package cozetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+)
+
+func TestConversations(t *testing.T) {
+	server, client := newClient(t, nil)
+	ctx := context.Background()
+
+	conv, err := client.Conversations.Create(ctx, &coze.CreateConversationsReq{
+		BotID:    "bot",
+		Messages: []*coze.Message{coze.BuildUserQuestionText("Hi", nil)},
+	})
+	require.NoError(t, err)
+	_, err = client.Conversations.Create(ctx, &coze.CreateConversationsReq{BotID: "other"})
+	require.NoError(t, err)
+
+	retrieved, err := client.Conversations.Retrieve(ctx, &coze.RetrieveConversationsReq{ConversationID: conv.ID})
+	require.NoError(t, err)
+	assert.Equal(t, conv.LastSectionID, retrieved.LastSectionID)
+
+	paged, err := client.Conversations.List(ctx, &coze.ListConversationsReq{BotID: "bot", PageSize: 10})
+	require.NoError(t, err)
+	var ids []string
+	for paged.Next() {
+		ids = append(ids, paged.Current().ID)
+	}
+	require.NoError(t, paged.Err())
+	assert.Equal(t, []string{conv.ID}, ids)
+
+	cleared, err := client.Conversations.Clear(ctx, &coze.ClearConversationsReq{ConversationID: conv.ID})
+	require.NoError(t, err)
+	assert.Equal(t, conv.ID, cleared.ConversationID)
+	retrieved, err = client.Conversations.Retrieve(ctx, &coze.RetrieveConversationsReq{ConversationID: conv.ID})
+	require.NoError(t, err)
+	assert.NotEqual(t, conv.LastSectionID, retrieved.LastSectionID)
+
+	_, err = client.Conversations.Retrieve(ctx, &coze.RetrieveConversationsReq{ConversationID: "1"})
+	assert.True(t, errors.Is(err, coze.ErrNotFound))
+
+	require.Len(t, server.Messages(conv.ID), 1)
+	assert.Equal(t, "Hi", server.Messages(conv.ID)[0].Content)
+}
+
+func TestMessages(t *testing.T) {
+	server, client := newClient(t, nil)
+	ctx := context.Background()
+	conv, err := client.Conversations.Create(ctx, &coze.CreateConversationsReq{})
+	require.NoError(t, err)
+
+	var created []string
+	for _, content := range []string{"one", "two", "three", "four", "five"} {
+		message, err := client.Conversations.Messages.Create(ctx, &coze.CreateMessageReq{
+			ConversationID: conv.ID,
+			Role:           coze.MessageRoleUser,
+			Content:        content,
+			ContentType:    coze.MessageContentTypeText,
+		})
+		require.NoError(t, err)
+		created = append(created, message.ID)
+	}
+
+	t.Run("list pages", func(t *testing.T) {
+		asc := "asc"
+		paged, err := client.Conversations.Messages.List(ctx, &coze.ListConversationsMessagesReq{
+			ConversationID: conv.ID,
+			Order:          &asc,
+			Limit:          2,
+		})
+		require.NoError(t, err)
+		var ids []string
+		for paged.Next() {
+			ids = append(ids, paged.Current().ID)
+		}
+		require.NoError(t, paged.Err())
+		assert.Equal(t, created, ids)
+		assert.Len(t, server.Requests(), 1+len(created)+3)
+	})
+
+	t.Run("list most recent first", func(t *testing.T) {
+		paged, err := client.Conversations.Messages.List(ctx, &coze.ListConversationsMessagesReq{
+			ConversationID: conv.ID,
+			Limit:          1,
+		})
+		require.NoError(t, err)
+		require.Len(t, paged.Items(), 1)
+		assert.Equal(t, "five", paged.Items()[0].Content)
+		assert.True(t, paged.HasMore())
+	})
+
+	t.Run("retrieve, update and delete", func(t *testing.T) {
+		message, err := client.Conversations.Messages.Retrieve(ctx, &coze.RetrieveConversationsMessagesReq{
+			ConversationID: conv.ID,
+			MessageID:      created[0],
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "one", message.Content)
+
+		updated, err := client.Conversations.Messages.Update(ctx, &coze.UpdateConversationMessagesReq{
+			ConversationID: conv.ID,
+			MessageID:      created[0],
+			Content:        "uno",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "uno", updated.Content)
+
+		deleted, err := client.Conversations.Messages.Delete(ctx, &coze.DeleteConversationsMessagesReq{
+			ConversationID: conv.ID,
+			MessageID:      created[0],
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "uno", deleted.Content)
+		assert.Len(t, server.Messages(conv.ID), len(created)-1)
+
+		_, err = client.Conversations.Messages.Retrieve(ctx, &coze.RetrieveConversationsMessagesReq{
+			ConversationID: conv.ID,
+			MessageID:      created[0],
+		})
+		assert.True(t, errors.Is(err, coze.ErrNotFound))
+	})
+}
@@ -0,0 +1,382 @@
+package cozetest
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/coze-dev/coze-go"
+)
+
+type dataset struct {
+	coze.Dataset
+	documentIDs []string
+}
+
+type document struct {
+	coze.Document
+	datasetID string
+	caption   string
+}
+
+func (s *Server) registerDatasets() {
+	s.handle(http.MethodPost, "/v1/datasets", s.createDataset)
+	s.handle(http.MethodGet, "/v1/datasets", s.listDatasets)
+	s.handle(http.MethodPut, "/v1/datasets/:id", s.updateDataset)
+	s.handle(http.MethodDelete, "/v1/datasets/:id", s.deleteDataset)
+	s.handle(http.MethodPost, "/v1/datasets/:id/process", s.processDocuments)
+	s.handle(http.MethodPost, "/open_api/knowledge/document/create", s.createDocuments)
+	s.handle(http.MethodPost, "/open_api/knowledge/document/update", s.updateDocument)
+	s.handle(http.MethodPost, "/open_api/knowledge/document/delete", s.deleteDocuments)
+	s.handle(http.MethodPost, "/open_api/knowledge/document/list", s.listDocuments)
+	s.handle(http.MethodGet, "/v1/datasets/:id/images", s.listImages)
+	s.handle(http.MethodPut, "/v1/datasets/:id/images/:document_id", s.updateImage)
+}
+
+// updateFiles updates the files of the dataset from its documents.
+func (ds *dataset) updateFiles(documents []*document) {
+	ds.FileList = []string{}
+	for _, doc := range documents {
+		ds.FileList = append(ds.FileList, doc.Name)
+	}
+	ds.DocCount = len(documents)
+	ds.UpdateTime = int(now())
+}
+
+// datasetLocked returns the dataset, or answers with a not found error.
+func (s *Server) datasetLocked(c *call, id string) (*dataset, bool) {
+	ds, ok := s.datasets[id]
+	if !ok {
+		c.notFound("dataset", id)
+	}
+	return ds, ok
+}
+
+// documentsLocked returns the documents of the dataset, in the order they were created.
+func (s *Server) documentsLocked(datasetID string) []*document {
+	var documents []*document
+	for _, id := range s.datasets[datasetID].documentIDs {
+		documents = append(documents, s.documents[id])
+	}
+	return documents
+}
+
+func (s *Server) createDataset(c *call) {
+	req := &coze.CreateDatasetsReq{}
+	if !c.decode(req) {
+		return
+	}
+	if req.Name == "" || req.SpaceID == "" {
+		c.invalid("name and space_id are required")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ds := &dataset{Dataset: coze.Dataset{
+		ID:          s.newIDLocked(),
+		Name:        req.Name,
+		Description: req.Description,
+		SpaceID:     req.SpaceID,
+		Status:      coze.DatasetStatusEnabled,
+		FormatType:  req.FormatType,
+		CanEdit:     true,
+		CreateTime:  int(now()),
+		UpdateTime:  int(now()),
+		FileList:    []string{},
+	}}
+	s.datasets[ds.ID] = ds
+	s.datasetOrder = append(s.datasetOrder, ds.ID)
+	c.data(map[string]string{"dataset_id": ds.ID})
+}
+
+func (s *Server) listDatasets(c *call) {
+	spaceID, name, formatType := c.query("space_id"), c.query("name"), c.query("format_type")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var datasets []*coze.Dataset
+	for _, id := range s.datasetOrder {
+		ds := s.datasets[id]
+		if ds.SpaceID != spaceID || !strings.Contains(ds.Name, name) {
+			continue
+		}
+		if formatType != "" && formatType != strconv.Itoa(int(ds.FormatType)) {
+			continue
+		}
+		copied := ds.Dataset
+		datasets = append(datasets, &copied)
+	}
+	c.data(map[string]any{
+		"total_count":  len(datasets),
+		"dataset_list": page(datasets, c.queryInt("page_num", 1), c.queryInt("page_size", 10)),
+	})
+}
+
+func (s *Server) updateDataset(c *call) {
+	req := &coze.UpdateDatasetsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ds, ok := s.datasetLocked(c, c.params[0])
+	if !ok {
+		return
+	}
+	if req.Name != "" {
+		ds.Name = req.Name
+	}
+	ds.Description = req.Description
+	ds.UpdateTime = int(now())
+	c.data(map[string]any{})
+}
+
+func (s *Server) deleteDataset(c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ds, ok := s.datasetLocked(c, c.params[0])
+	if !ok {
+		return
+	}
+	for _, id := range ds.documentIDs {
+		delete(s.documents, id)
+	}
+	delete(s.datasets, ds.ID)
+	for i, id := range s.datasetOrder {
+		if id == ds.ID {
+			s.datasetOrder = append(s.datasetOrder[:i], s.datasetOrder[i+1:]...)
+			break
+		}
+	}
+	c.data(map[string]any{})
+}
+
+// processDocuments reports the progress of the documents, which are processed once created.
+func (s *Server) processDocuments(c *call) {
+	req := &coze.ProcessDocumentsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.datasetLocked(c, c.params[0]); !ok {
+		return
+	}
+	progress := []*coze.DocumentProgress{}
+	for _, id := range req.DocumentIDs {
+		doc, ok := s.documents[id]
+		if !ok || doc.datasetID != c.params[0] {
+			c.notFound("document", id)
+			return
+		}
+		progress = append(progress, &coze.DocumentProgress{
+			DocumentID:   doc.DocumentID,
+			Size:         doc.Size,
+			Type:         doc.Type,
+			Status:       doc.Status,
+			Progress:     100,
+			UpdateType:   doc.UpdateType,
+			DocumentName: doc.Name,
+		})
+	}
+	c.data(map[string]any{"data": progress})
+}
+
+// createDocuments adds the documents to the dataset, they are processed at once.
+func (s *Server) createDocuments(c *call) {
+	req := &coze.CreateDatasetsDocumentsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ds, ok := s.datasetLocked(c, strconv.FormatInt(req.DatasetID, 10))
+	if !ok {
+		return
+	}
+	infos := []*coze.Document{}
+	for _, base := range req.DocumentBases {
+		doc := &document{
+			Document: coze.Document{
+				DocumentID:    s.newIDLocked(),
+				ChunkStrategy: req.ChunkStrategy,
+				CreateTime:    int(now()),
+				UpdateTime:    int(now()),
+				FormatType:    ds.FormatType,
+				Name:          base.Name,
+				Status:        coze.DocumentStatusCompleted,
+				SliceCount:    1,
+			},
+			datasetID: ds.ID,
+		}
+		if base.UpdateRule != nil {
+			doc.UpdateType = base.UpdateRule.UpdateType
+			doc.UpdateInterval = base.UpdateRule.UpdateInterval
+		}
+		if info := base.SourceInfo; info != nil {
+			switch {
+			case info.FileBase64 != nil:
+				content, err := base64.StdEncoding.DecodeString(*info.FileBase64)
+				if err != nil {
+					c.invalid("invalid file_base64: " + err.Error())
+					return
+				}
+				doc.Size = len(content)
+				doc.CharCount = len([]rune(string(content)))
+				if info.FileType != nil {
+					doc.Type = *info.FileType
+				}
+			case info.WebUrl != nil:
+				doc.SourceType = coze.DocumentSourceTypeOnlineWeb
+				doc.Type = "url"
+			case info.SourceFileID != nil:
+				doc.Type = "image"
+				if f, ok := s.files[strconv.FormatInt(*info.SourceFileID, 10)]; ok {
+					doc.Size = f.Bytes
+				}
+			}
+		}
+		s.documents[doc.DocumentID] = doc
+		ds.documentIDs = append(ds.documentIDs, doc.DocumentID)
+		copied := doc.Document
+		infos = append(infos, &copied)
+	}
+	ds.updateFiles(s.documentsLocked(ds.ID))
+	c.flat(map[string]any{"document_infos": infos})
+}
+
+func (s *Server) updateDocument(c *call) {
+	req := &coze.UpdateDatasetsDocumentsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := strconv.FormatInt(req.DocumentID, 10)
+	doc, ok := s.documents[id]
+	if !ok {
+		c.notFound("document", id)
+		return
+	}
+	if req.DocumentName != "" {
+		doc.Name = req.DocumentName
+	}
+	if req.UpdateRule != nil {
+		doc.UpdateType = req.UpdateRule.UpdateType
+		doc.UpdateInterval = req.UpdateRule.UpdateInterval
+	}
+	doc.UpdateTime = int(now())
+	c.flat(map[string]any{})
+}
+
+func (s *Server) deleteDocuments(c *call) {
+	req := &coze.DeleteDatasetsDocumentsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, documentID := range req.DocumentIDs {
+		id := strconv.FormatInt(documentID, 10)
+		doc, ok := s.documents[id]
+		if !ok {
+			continue
+		}
+		delete(s.documents, id)
+		ds := s.datasets[doc.datasetID]
+		for i, documentID := range ds.documentIDs {
+			if documentID == id {
+				ds.documentIDs = append(ds.documentIDs[:i], ds.documentIDs[i+1:]...)
+				break
+			}
+		}
+		ds.updateFiles(s.documentsLocked(ds.ID))
+	}
+	c.flat(map[string]any{})
+}
+
+func (s *Server) listDocuments(c *call) {
+	req := &coze.ListDatasetsDocumentsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ds, ok := s.datasetLocked(c, strconv.FormatInt(req.DatasetID, 10))
+	if !ok {
+		return
+	}
+	documents := []*coze.Document{}
+	for _, doc := range s.documentsLocked(ds.ID) {
+		copied := doc.Document
+		documents = append(documents, &copied)
+	}
+	pageNum, pageSize := req.Page, req.Size
+	if pageNum <= 0 {
+		pageNum = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	c.flat(map[string]any{
+		"document_infos": page(documents, pageNum, pageSize),
+		"total":          len(documents),
+	})
+}
+
+// listImages lists the documents of an image dataset.
+func (s *Server) listImages(c *call) {
+	keyword, hasCaption := c.query("keyword"), c.query("has_caption")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ds, ok := s.datasetLocked(c, c.params[0])
+	if !ok {
+		return
+	}
+	images := []*coze.Image{}
+	for _, doc := range s.documentsLocked(ds.ID) {
+		if keyword != "" && !strings.Contains(doc.caption, keyword) {
+			continue
+		}
+		if hasCaption != "" && hasCaption != strconv.FormatBool(doc.caption != "") {
+			continue
+		}
+		images = append(images, &coze.Image{
+			DocumentID:    doc.DocumentID,
+			CharCount:     doc.CharCount,
+			ChunkStrategy: doc.ChunkStrategy,
+			CreateTime:    doc.CreateTime,
+			UpdateTime:    doc.UpdateTime,
+			FormatType:    doc.FormatType,
+			Name:          doc.Name,
+			Size:          doc.Size,
+			SliceCount:    doc.SliceCount,
+			SourceType:    doc.SourceType,
+			Status:        coze.ImageStatusCompleted,
+			Caption:       doc.caption,
+		})
+	}
+	c.data(map[string]any{
+		"photo_infos": page(images, c.queryInt("page_num", 1), c.queryInt("page_size", 10)),
+		"total_count": len(images),
+	})
+}
+
+func (s *Server) updateImage(c *call) {
+	req := &coze.UpdateDatasetImageReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, ok := s.documents[c.params[1]]
+	if !ok || doc.datasetID != c.params[0] {
+		c.notFound("image", c.params[1])
+		return
+	}
+	if req.Caption != nil {
+		doc.caption = *req.Caption
+	}
+	doc.UpdateTime = int(now())
+	c.data(map[string]any{})
+}
@@ -0,0 +1,116 @@
+package cozetest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+)
+
+func TestDatasets(t *testing.T) {
+	_, client := newClient(t, nil)
+	ctx := context.Background()
+
+	created, err := client.Datasets.Create(ctx, &coze.CreateDatasetsReq{Name: "docs", SpaceID: "space"})
+	require.NoError(t, err)
+	_, err = client.Datasets.Create(ctx, &coze.CreateDatasetsReq{Name: "other", SpaceID: "space"})
+	require.NoError(t, err)
+	datasetID, err := strconv.ParseInt(created.DatasetID, 10, 64)
+	require.NoError(t, err)
+
+	documents, err := client.Datasets.Documents.Create(ctx, &coze.CreateDatasetsDocumentsReq{
+		DatasetID: datasetID,
+		DocumentBases: []*coze.DocumentBase{
+			coze.DocumentBaseBuildLocalFile("readme.txt", "hello", "txt"),
+			coze.DocumentBaseBuildWebPage("site", "https://example.com", nil),
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, documents.DocumentInfos, 2)
+	assert.Equal(t, 5, documents.DocumentInfos[0].Size)
+
+	progress, err := client.Datasets.Process(ctx, &coze.ProcessDocumentsReq{
+		DatasetID:   created.DatasetID,
+		DocumentIDs: []string{documents.DocumentInfos[0].DocumentID},
+	})
+	require.NoError(t, err)
+	require.Len(t, progress.Data, 1)
+	assert.Equal(t, 100, progress.Data[0].Progress)
+
+	_, err = client.Datasets.Update(ctx, &coze.UpdateDatasetsReq{DatasetID: created.DatasetID, Name: "renamed"})
+	require.NoError(t, err)
+
+	req := coze.NewListDatasetsReq("space")
+	req.Name = "renamed"
+	paged, err := client.Datasets.List(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, paged.Items(), 1)
+	assert.Equal(t, 1, paged.Total())
+	assert.Equal(t, []string{"readme.txt", "site"}, paged.Items()[0].FileList)
+
+	documentID, err := strconv.ParseInt(documents.DocumentInfos[1].DocumentID, 10, 64)
+	require.NoError(t, err)
+	_, err = client.Datasets.Documents.Delete(ctx, &coze.DeleteDatasetsDocumentsReq{DocumentIDs: []int64{documentID}})
+	require.NoError(t, err)
+	listed, err := client.Datasets.Documents.List(ctx, &coze.ListDatasetsDocumentsReq{DatasetID: datasetID})
+	require.NoError(t, err)
+	require.Len(t, listed.Items(), 1)
+	assert.Equal(t, "readme.txt", listed.Items()[0].Name)
+
+	_, err = client.Datasets.Delete(ctx, &coze.DeleteDatasetsReq{DatasetID: created.DatasetID})
+	require.NoError(t, err)
+	_, err = client.Datasets.Documents.List(ctx, &coze.ListDatasetsDocumentsReq{DatasetID: datasetID})
+	assert.True(t, errors.Is(err, coze.ErrNotFound))
+}
+
+func TestDatasetImages(t *testing.T) {
+	_, client := newClient(t, nil)
+	ctx := context.Background()
+
+	created, err := client.Datasets.Create(ctx, &coze.CreateDatasetsReq{
+		Name:       "images",
+		SpaceID:    "space",
+		FormatType: coze.DocumentFormatTypeImage,
+	})
+	require.NoError(t, err)
+	datasetID, err := strconv.ParseInt(created.DatasetID, 10, 64)
+	require.NoError(t, err)
+	uploaded, err := client.Files.Upload(ctx, &coze.UploadFilesReq{File: coze.NewUploadFile(strings.NewReader("png"), "cat.png")})
+	require.NoError(t, err)
+	fileID, err := strconv.ParseInt(uploaded.ID, 10, 64)
+	require.NoError(t, err)
+	documents, err := client.Datasets.Documents.Create(ctx, &coze.CreateDatasetsDocumentsReq{
+		DatasetID: datasetID,
+		DocumentBases: []*coze.DocumentBase{
+			coze.DocumentBaseBuildImage("cat.png", fileID),
+			coze.DocumentBaseBuildImage("dog.png", fileID),
+		},
+	})
+	require.NoError(t, err)
+
+	caption := "a cat"
+	_, err = client.Datasets.Images.Update(ctx, &coze.UpdateDatasetImageReq{
+		DatasetID:  created.DatasetID,
+		DocumentID: documents.DocumentInfos[0].DocumentID,
+		Caption:    &caption,
+	})
+	require.NoError(t, err)
+
+	hasCaption := true
+	paged, err := client.Datasets.Images.List(ctx, &coze.ListDatasetsImagesReq{
+		DatasetID:  created.DatasetID,
+		HasCaption: &hasCaption,
+		PageNum:    1,
+		PageSize:   10,
+	})
+	require.NoError(t, err)
+	require.Len(t, paged.Items(), 1)
+	assert.Equal(t, "a cat", paged.Items()[0].Caption)
+	assert.Equal(t, 3, paged.Items()[0].Size)
+}
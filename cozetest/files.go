@@ -0,0 +1,81 @@
+package cozetest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/coze-dev/coze-go"
+)
+
+type file struct {
+	coze.FileInfo
+	content []byte
+}
+
+// File returns the content of an uploaded file.
+func (s *Server) File(fileID string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[fileID]
+	if !ok {
+		return nil, false
+	}
+	return f.content, true
+}
+
+func (s *Server) registerFiles() {
+	s.handle(http.MethodPost, "/v1/files/upload", s.uploadFile)
+	s.handle(http.MethodPost, "/v1/files/retrieve", s.retrieveFile)
+}
+
+// uploadFile stores the file of a multipart upload.
+func (s *Server) uploadFile(c *call) {
+	content, name, ok := c.multipartFile()
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := &file{
+		FileInfo: coze.FileInfo{
+			ID:        s.newIDLocked(),
+			Bytes:     len(content),
+			CreatedAt: int(now()),
+			FileName:  name,
+		},
+		content: content,
+	}
+	s.files[f.ID] = f
+	c.data(f.FileInfo)
+}
+
+func (s *Server) retrieveFile(c *call) {
+	id := c.query("file_id")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[id]
+	if !ok {
+		c.notFound("file", id)
+		return
+	}
+	c.data(f.FileInfo)
+}
+
+// multipartFile reads the file part of a multipart upload, or answers with an invalid parameter
+// error.
+func (c *call) multipartFile() ([]byte, string, bool) {
+	c.r.Body = io.NopCloser(bytes.NewReader(c.body))
+	part, header, err := c.r.FormFile("file")
+	if err != nil {
+		c.invalid("invalid file: " + err.Error())
+		return nil, "", false
+	}
+	defer part.Close()
+	content, err := io.ReadAll(part)
+	if err != nil {
+		c.invalid("invalid file: " + err.Error())
+		return nil, "", false
+	}
+	return content, header.Filename, true
+}
@@ -0,0 +1,42 @@
+package cozetest
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+)
+
+func TestFiles(t *testing.T) {
+	server, client := newClient(t, nil)
+	ctx := context.Background()
+
+	uploaded, err := client.Files.Upload(ctx, &coze.UploadFilesReq{File: coze.NewUploadFile(strings.NewReader("hello"), "hello.txt")})
+	require.NoError(t, err)
+	assert.Equal(t, "hello.txt", uploaded.FileName)
+	assert.Equal(t, 5, uploaded.Bytes)
+
+	retrieved, err := client.Files.Retrieve(ctx, &coze.RetrieveFilesReq{FileID: uploaded.ID})
+	require.NoError(t, err)
+	assert.Equal(t, uploaded.FileInfo, retrieved.FileInfo)
+
+	content, ok := server.File(uploaded.ID)
+	require.True(t, ok)
+	assert.Equal(t, "hello", string(content))
+}
+
+func TestAudioSpeech(t *testing.T) {
+	_, client := newClient(t, nil)
+
+	resp, err := client.Audio.Speech.Create(context.Background(), &coze.CreateAudioSpeechReq{Input: "Hello", VoiceID: "voice"})
+	require.NoError(t, err)
+	defer resp.Data.Close()
+	content, err := io.ReadAll(resp.Data)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", string(content))
+}
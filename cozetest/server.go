@@ -0,0 +1,463 @@
+// Package cozetest is an in-process emulation of the Coze API for end-to-end tests, which run the
+// SDK against it without network:
+//
+//	server := cozetest.NewServer()
+//	defer server.Close()
+//	cozeCli := coze.NewCozeAPI(coze.NewTokenAuth("token"), coze.WithBaseURL(server.URL))
+//
+// The server keeps bots, conversations, messages, chats, workflow runs, datasets, documents, images
+// and files in memory. Chats and workflows answer with scripted replies, see ReplyChat and
+// ReplyWorkflow, and failures are injected per endpoint with Fail.
+//
+// Sessions against the real API are recorded once and replayed in CI with a Cassette, see
+// UseCassette.
+package cozetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// LogIDHeader is the header of the log ID the server sets on every response.
+const LogIDHeader = "X-Tt-Logid"
+
+// Option configures a Server.
+type Option func(*serverOption)
+
+type serverOption struct {
+	latency     time.Duration
+	streamDelay time.Duration
+	token       string
+}
+
+// WithLatency delays every response by d.
+func WithLatency(d time.Duration) Option {
+	return func(o *serverOption) {
+		o.latency = d
+	}
+}
+
+// WithStreamDelay delays every event of the streamed responses by d.
+func WithStreamDelay(d time.Duration) Option {
+	return func(o *serverOption) {
+		o.streamDelay = d
+	}
+}
+
+// WithToken makes the server reject the requests which are not authorized with the access token,
+// any token is accepted by default.
+func WithToken(token string) Option {
+	return func(o *serverOption) {
+		o.token = token
+	}
+}
+
+// Fault is a failure injected in the responses of an endpoint.
+type Fault struct {
+	// Status is the HTTP status of the response, 200 by default when Code is set, 500 otherwise.
+	Status int
+	// Code and Msg are the Coze error of the response.
+	Code int
+	Msg  string
+	// Header is added to the response, e.g. Retry-After.
+	Header http.Header
+	// Body replaces the JSON body of the response.
+	Body string
+	// Delay delays the response.
+	Delay time.Duration
+	// Disconnect closes the connection without a response.
+	Disconnect bool
+	// Times is the number of requests which fail, every request fails when it is zero.
+	Times int
+}
+
+// Request is a request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// Server is an in-process Coze API. It is safe for concurrent use.
+type Server struct {
+	// URL is the base URL of the server, to be passed to coze.WithBaseURL.
+	URL string
+
+	opt    *serverOption
+	server *httptest.Server
+	routes []*route
+
+	mu       sync.Mutex
+	nextID   int64
+	faults   map[string][]*Fault
+	requests []*Request
+
+	bots          map[string]*bot
+	botOrder      []string
+	conversations map[string]*conversation
+	convOrder     []string
+	chats         map[string]*chatState
+	chatReplies   map[string][]*ChatReply
+	workflowRuns  map[string][]*WorkflowRun
+	interrupts    map[string]*interrupt
+	histories     map[string]*coze.WorkflowRunHistory
+	datasets      map[string]*dataset
+	datasetOrder  []string
+	documents     map[string]*document
+	files         map[string]*file
+}
+
+// NewServer starts a Server, which is stopped with Close.
+func NewServer(opts ...Option) *Server {
+	opt := &serverOption{}
+	for _, option := range opts {
+		option(opt)
+	}
+	s := &Server{
+		opt:           opt,
+		nextID:        7400000000000000000,
+		faults:        map[string][]*Fault{},
+		bots:          map[string]*bot{},
+		conversations: map[string]*conversation{},
+		chats:         map[string]*chatState{},
+		chatReplies:   map[string][]*ChatReply{},
+		workflowRuns:  map[string][]*WorkflowRun{},
+		interrupts:    map[string]*interrupt{},
+		histories:     map[string]*coze.WorkflowRunHistory{},
+		datasets:      map[string]*dataset{},
+		documents:     map[string]*document{},
+		files:         map[string]*file{},
+	}
+	s.registerRoutes()
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	return s
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Fail injects a fault in the responses of the endpoint path, e.g. "/v3/chat". Faults of the same
+// path apply in the order they were added.
+func (s *Server) Fail(path string, fault *Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *fault
+	s.faults[path] = append(s.faults[path], &copied)
+}
+
+// ClearFaults removes the faults of every endpoint.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = map[string][]*Fault{}
+}
+
+// Requests returns the requests received by the server, in order.
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request(nil), s.requests...)
+}
+
+// route maps a method and a path pattern to a handler. A pattern segment starting with ":" matches
+// any segment, which is passed to the handler as a parameter.
+type route struct {
+	method  string
+	pattern []string
+	handler func(c *call)
+}
+
+func (s *Server) handle(method, pattern string, handler func(c *call)) {
+	s.routes = append(s.routes, &route{
+		method:  method,
+		pattern: strings.Split(strings.Trim(pattern, "/"), "/"),
+		handler: handler,
+	})
+}
+
+func (r *route) match(method, path string) ([]string, bool) {
+	if r.method != method {
+		return nil, false
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) != len(r.pattern) {
+		return nil, false
+	}
+	var params []string
+	for i, segment := range r.pattern {
+		if strings.HasPrefix(segment, ":") {
+			params = append(params, segments[i])
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func (s *Server) registerRoutes() {
+	s.registerBots()
+	s.registerConversations()
+	s.registerChats()
+	s.registerWorkflows()
+	s.registerDatasets()
+	s.registerFiles()
+	s.registerAudio()
+}
+
+// call is a request being served.
+type call struct {
+	server *Server
+	w      http.ResponseWriter
+	r      *http.Request
+	body   []byte
+	params []string
+	logID  string
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c := &call{server: s, w: w, r: r, body: body, logID: s.newLogID()}
+	w.Header().Set(LogIDHeader, c.logID)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, &Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	fault := s.takeFault(r.URL.Path)
+	s.mu.Unlock()
+
+	if s.opt.latency > 0 {
+		sleep(r, s.opt.latency)
+	}
+	if fault != nil {
+		c.fault(fault)
+		return
+	}
+	if s.opt.token != "" && r.Header.Get("Authorization") != "Bearer "+s.opt.token {
+		c.error(http.StatusUnauthorized, 4100, "authentication is invalid")
+		return
+	}
+	for _, route := range s.routes {
+		if params, ok := route.match(r.Method, r.URL.Path); ok {
+			c.params = params
+			route.handler(c)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// takeFault returns the fault of the next request to path, if any.
+func (s *Server) takeFault(path string) *Fault {
+	faults := s.faults[path]
+	if len(faults) == 0 {
+		return nil
+	}
+	fault := faults[0]
+	if fault.Times > 0 {
+		fault.Times--
+		if fault.Times == 0 {
+			s.faults[path] = faults[1:]
+		}
+	}
+	return fault
+}
+
+func (c *call) fault(fault *Fault) {
+	if fault.Delay > 0 {
+		sleep(c.r, fault.Delay)
+	}
+	if fault.Disconnect {
+		panic(http.ErrAbortHandler)
+	}
+	for key, values := range fault.Header {
+		for _, value := range values {
+			c.w.Header().Add(key, value)
+		}
+	}
+	status := fault.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+		if fault.Code != 0 {
+			status = http.StatusOK
+		}
+	}
+	if fault.Body != "" {
+		c.w.WriteHeader(status)
+		_, _ = io.WriteString(c.w, fault.Body)
+		return
+	}
+	msg := fault.Msg
+	if msg == "" {
+		msg = http.StatusText(status)
+	}
+	c.error(status, fault.Code, msg)
+}
+
+// newID returns a new numeric ID, as the IDs of the Coze API are.
+func (s *Server) newID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.newIDLocked()
+}
+
+func (s *Server) newIDLocked() string {
+	s.nextID++
+	return strconv.FormatInt(s.nextID, 10)
+}
+
+func (s *Server) newLogID() string {
+	return time.Now().UTC().Format("20060102150405") + s.newID()
+}
+
+func sleep(r *http.Request, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+}
+
+// decode decodes the JSON body of the request into v, and answers with an invalid parameter error
+// when it fails.
+func (c *call) decode(v any) bool {
+	if len(c.body) == 0 {
+		return true
+	}
+	if err := json.Unmarshal(c.body, v); err != nil {
+		c.invalid(fmt.Sprintf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+func (c *call) query(key string) string {
+	return c.r.URL.Query().Get(key)
+}
+
+func (c *call) queryInt(key string, def int) int {
+	value, err := strconv.Atoi(c.query(key))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
+// data answers with the Coze envelope, the value being set as its data field.
+func (c *call) data(data any) {
+	c.flat(map[string]any{"data": data})
+}
+
+// flat answers with the fields at the top level of the Coze envelope, as some endpoints do.
+func (c *call) flat(fields map[string]any) {
+	fields["code"] = 0
+	fields["msg"] = ""
+	c.write(http.StatusOK, fields)
+}
+
+func (c *call) error(status, code int, msg string) {
+	c.write(status, map[string]any{"code": code, "msg": msg})
+}
+
+func (c *call) write(status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		status, body = http.StatusInternalServerError, []byte(`{"code":5000,"msg":"encode response"}`)
+	}
+	c.w.Header().Set("Content-Type", "application/json")
+	c.w.WriteHeader(status)
+	_, _ = c.w.Write(body)
+}
+
+func (c *call) invalid(msg string) {
+	c.error(http.StatusOK, 4000, msg)
+}
+
+func (c *call) notFound(kind, id string) {
+	c.error(http.StatusOK, 4200, fmt.Sprintf("%s %s not found", kind, id))
+}
+
+// sse starts a server-sent events response.
+func (c *call) sse() *sseWriter {
+	c.w.Header().Set("Content-Type", "text/event-stream")
+	c.w.Header().Set("Cache-Control", "no-cache")
+	c.w.WriteHeader(http.StatusOK)
+	flusher, _ := c.w.(http.Flusher)
+	return &sseWriter{w: c.w, flusher: flusher, r: c.r, delay: c.server.opt.streamDelay}
+}
+
+type sseWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+	r       *http.Request
+	delay   time.Duration
+	written int
+}
+
+// event writes an event, data being written as is when it is a string, or encoded as JSON. It
+// returns false once the client is gone.
+func (w *sseWriter) event(id, event string, data any) bool {
+	if w.delay > 0 {
+		sleep(w.r, w.delay)
+	}
+	if w.r.Context().Err() != nil {
+		return false
+	}
+	payload, ok := data.(string)
+	if !ok {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return false
+		}
+		payload = string(encoded)
+	}
+	var b strings.Builder
+	if id != "" {
+		b.WriteString("id: " + id + "\n")
+	}
+	b.WriteString("event: " + event + "\n")
+	for _, line := range strings.Split(payload, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	if _, err := io.WriteString(w.w, b.String()); err != nil {
+		return false
+	}
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+	w.written++
+	return true
+}
+
+// disconnect drops the connection in the middle of the stream.
+func (w *sseWriter) disconnect() {
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+	panic(http.ErrAbortHandler)
+}
+
+func now() int64 {
+	return time.Now().Unix()
+}
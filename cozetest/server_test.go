@@ -0,0 +1,139 @@
+package cozetest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// newClient starts a server, closed with the test, and returns a client of it.
+func newClient(t *testing.T, serverOpts []Option, opts ...coze.CozeAPIOption) (*Server, coze.CozeAPI) {
+	server := NewServer(serverOpts...)
+	t.Cleanup(server.Close)
+	opts = append([]coze.CozeAPIOption{coze.WithBaseURL(server.URL), coze.WithLogLevel(coze.LogLevelError)}, opts...)
+	return server, coze.NewCozeAPI(coze.NewTokenAuth("token"), opts...)
+}
+
+func TestServerFaults(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("coze error", func(t *testing.T) {
+		server, client := newClient(t, nil)
+		server.Fail("/v1/files/retrieve", &Fault{Code: 4000, Msg: "invalid file_id", Times: 1})
+
+		_, err := client.Files.Retrieve(ctx, &coze.RetrieveFilesReq{FileID: "1"})
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, coze.ErrInvalidParameter))
+		cozeErr, ok := coze.AsCozeError(err)
+		require.True(t, ok)
+		assert.Equal(t, "invalid file_id", cozeErr.Message)
+		assert.NotEmpty(t, cozeErr.LogID)
+
+		// the fault is consumed, the file is then not found
+		_, err = client.Files.Retrieve(ctx, &coze.RetrieveFilesReq{FileID: "1"})
+		assert.True(t, errors.Is(err, coze.ErrNotFound))
+	})
+
+	t.Run("rate limited and retried", func(t *testing.T) {
+		server, client := newClient(t, nil, coze.WithRetryPolicy(&coze.RetryPolicy{
+			MaxRetries:     2,
+			InitialBackoff: time.Millisecond,
+		}))
+		server.Fail("/v1/workflow/run", &Fault{
+			Status: http.StatusTooManyRequests,
+			Header: http.Header{"Retry-After": []string{"0"}},
+			Times:  2,
+		})
+
+		resp, err := client.Workflows.Runs.Create(ctx, &coze.RunWorkflowsReq{WorkflowID: "wf"})
+		require.NoError(t, err)
+		assert.Equal(t, "{}", resp.Data)
+		assert.Len(t, server.Requests(), 3)
+	})
+
+	t.Run("every request until cleared", func(t *testing.T) {
+		server, client := newClient(t, nil)
+		server.Fail("/v1/files/retrieve", &Fault{Status: http.StatusBadGateway, Body: "bad gateway"})
+		for i := 0; i < 2; i++ {
+			_, err := client.Files.Retrieve(ctx, &coze.RetrieveFilesReq{FileID: "1"})
+			httpErr, ok := coze.AsHTTPError(err)
+			require.True(t, ok)
+			assert.Equal(t, http.StatusBadGateway, httpErr.StatusCode)
+			assert.Equal(t, "bad gateway", httpErr.Body)
+		}
+		server.ClearFaults()
+		_, err := client.Files.Retrieve(ctx, &coze.RetrieveFilesReq{FileID: "1"})
+		assert.True(t, errors.Is(err, coze.ErrNotFound))
+	})
+
+	t.Run("disconnect", func(t *testing.T) {
+		server, client := newClient(t, nil)
+		server.Fail("/v1/files/retrieve", &Fault{Disconnect: true})
+		_, err := client.Files.Retrieve(ctx, &coze.RetrieveFilesReq{FileID: "1"})
+		require.Error(t, err)
+		_, isCozeErr := coze.AsCozeError(err)
+		assert.False(t, isCozeErr)
+		assert.True(t, coze.IsRetryable(err))
+	})
+
+	t.Run("delay", func(t *testing.T) {
+		server, client := newClient(t, nil)
+		server.Fail("/v1/files/retrieve", &Fault{Code: 4000, Delay: time.Second})
+		timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, err := client.Files.Retrieve(timeoutCtx, &coze.RetrieveFilesReq{FileID: "1"})
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+}
+
+func TestServerLatency(t *testing.T) {
+	_, client := newClient(t, []Option{WithLatency(30 * time.Millisecond)})
+	start := time.Now()
+	_, err := client.Conversations.Create(context.Background(), &coze.CreateConversationsReq{})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+}
+
+func TestServerToken(t *testing.T) {
+	server := NewServer(WithToken("secret"))
+	defer server.Close()
+	ctx := context.Background()
+
+	client := coze.NewCozeAPI(coze.NewTokenAuth("wrong"), coze.WithBaseURL(server.URL), coze.WithLogLevel(coze.LogLevelError))
+	_, err := client.Conversations.Create(ctx, &coze.CreateConversationsReq{})
+	assert.True(t, errors.Is(err, coze.ErrAuthFailed))
+
+	client = coze.NewCozeAPI(coze.NewTokenAuth("secret"), coze.WithBaseURL(server.URL))
+	_, err = client.Conversations.Create(ctx, &coze.CreateConversationsReq{})
+	assert.NoError(t, err)
+}
+
+func TestServerRequests(t *testing.T) {
+	server, client := newClient(t, nil)
+	conv, err := client.Conversations.Create(context.Background(), &coze.CreateConversationsReq{BotID: "bot"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, conv.LogID())
+
+	requests := server.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, http.MethodPost, requests[0].Method)
+	assert.Equal(t, "/v1/conversation/create", requests[0].Path)
+	assert.Equal(t, "Bearer token", requests[0].Header.Get("Authorization"))
+	assert.JSONEq(t, `{"bot_id":"bot","connector_id":""}`, string(requests[0].Body))
+}
+
+func TestServerNotFound(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/v1/unknown")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
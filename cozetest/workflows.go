@@ -0,0 +1,241 @@
+package cozetest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// WorkflowRun scripts a run of a workflow, see Server.ReplyWorkflow.
+type WorkflowRun struct {
+	// Output is the output of the workflow, streamed as the message of its End node.
+	Output string
+	// Interrupt ends the stream with an interrupt after the output, if any. Resuming the interrupt
+	// runs the next reply of the workflow. An event ID is generated when it has none. Runs which
+	// are not streamed ignore it.
+	Interrupt *coze.WorkflowEventInterrupt
+	// Error fails the run.
+	Error *coze.WorkflowEventError
+	// DisconnectAfter drops the connection of the stream after that number of events, the run
+	// still finishes on the server.
+	DisconnectAfter int
+	// Events are streamed instead of the events generated from the run, their IDs are renumbered.
+	Events []*coze.WorkflowEvent
+}
+
+// ReplyWorkflow queues the replies of the next runs or resumes of the workflow.
+//
+// Without a queued reply, a run outputs its parameters encoded as JSON, and a resume outputs the
+// resume data.
+func (s *Server) ReplyWorkflow(workflowID string, runs ...*WorkflowRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workflowRuns[workflowID] = append(s.workflowRuns[workflowID], runs...)
+}
+
+func (s *Server) nextWorkflowRunLocked(workflowID, output string) *WorkflowRun {
+	runs := s.workflowRuns[workflowID]
+	if len(runs) == 0 {
+		return &WorkflowRun{Output: output}
+	}
+	s.workflowRuns[workflowID] = runs[1:]
+	return runs[0]
+}
+
+// parametersOutput is the default output of a run, its parameters encoded as JSON.
+func parametersOutput(parameters map[string]any) string {
+	if parameters == nil {
+		return "{}"
+	}
+	return mustJSON(parameters)
+}
+
+type interrupt struct {
+	workflowID string
+	executeID  string
+}
+
+func (s *Server) registerWorkflows() {
+	s.handle(http.MethodPost, "/v1/workflow/run", s.runWorkflow)
+	s.handle(http.MethodPost, "/v1/workflow/stream_run", s.streamWorkflow)
+	s.handle(http.MethodPost, "/v1/workflow/stream_resume", s.resumeWorkflow)
+	s.handle(http.MethodGet, "/v1/workflows/:workflow_id/run_histories/:execute_id", s.retrieveWorkflowHistory)
+}
+
+// runWorkflow runs a workflow synchronously, or asynchronously in which case the result is only
+// available from its run history.
+func (s *Server) runWorkflow(c *call) {
+	req := &coze.RunWorkflowsReq{}
+	if !c.decode(req) {
+		return
+	}
+	if req.WorkflowID == "" {
+		c.invalid("workflow_id is required")
+		return
+	}
+	s.mu.Lock()
+	run := s.nextWorkflowRunLocked(req.WorkflowID, parametersOutput(req.Parameters))
+	mode := coze.WorkflowRunModeSynchronous
+	if req.IsAsync {
+		mode = coze.WorkflowRunModeAsynchronous
+	}
+	history := s.recordRunLocked(req.WorkflowID, req.BotID, mode, run)
+	s.mu.Unlock()
+
+	if req.IsAsync {
+		c.flat(map[string]any{"execute_id": history.ExecuteID, "debug_url": history.DebugURL})
+		return
+	}
+	if run.Error != nil {
+		c.error(http.StatusOK, run.Error.ErrorCode, run.Error.ErrorMessage)
+		return
+	}
+	c.flat(map[string]any{
+		"data":       run.Output,
+		"execute_id": history.ExecuteID,
+		"debug_url":  history.DebugURL,
+		"token":      len(run.Output),
+		"cost":       "0",
+	})
+}
+
+func (s *Server) streamWorkflow(c *call) {
+	req := &coze.RunWorkflowsReq{}
+	if !c.decode(req) {
+		return
+	}
+	if req.WorkflowID == "" {
+		c.invalid("workflow_id is required")
+		return
+	}
+	s.mu.Lock()
+	run := s.nextWorkflowRunLocked(req.WorkflowID, parametersOutput(req.Parameters))
+	history := s.recordRunLocked(req.WorkflowID, req.BotID, coze.WorkflowRunModeStreaming, run)
+	events := s.workflowEventsLocked(req.WorkflowID, history, run)
+	s.mu.Unlock()
+	c.stream(events, run.DisconnectAfter)
+}
+
+// resumeWorkflow continues an interrupted stream with the next reply of the workflow.
+func (s *Server) resumeWorkflow(c *call) {
+	req := &coze.ResumeRunWorkflowsReq{}
+	if !c.decode(req) {
+		return
+	}
+	s.mu.Lock()
+	interrupted, ok := s.interrupts[req.EventID]
+	if !ok || interrupted.workflowID != req.WorkflowID {
+		s.mu.Unlock()
+		c.notFound("interrupt event", req.EventID)
+		return
+	}
+	delete(s.interrupts, req.EventID)
+	run := s.nextWorkflowRunLocked(req.WorkflowID, req.ResumeData)
+	history := s.histories[interrupted.executeID]
+	applyRun(history, run)
+	events := s.workflowEventsLocked(req.WorkflowID, history, run)
+	s.mu.Unlock()
+	c.stream(events, run.DisconnectAfter)
+}
+
+// recordRunLocked records the run history of a run.
+func (s *Server) recordRunLocked(workflowID, botID string, mode coze.WorkflowRunMode, run *WorkflowRun) *coze.WorkflowRunHistory {
+	executeID := s.newIDLocked()
+	history := &coze.WorkflowRunHistory{
+		ExecuteID:  executeID,
+		BotID:      botID,
+		RunMode:    mode,
+		CreateTime: int(now()),
+		DebugURL:   fmt.Sprintf("https://www.coze.com/work_flow?execute_id=%s&workflow_id=%s", executeID, workflowID),
+	}
+	applyRun(history, run)
+	s.histories[executeID] = history
+	return history
+}
+
+func applyRun(history *coze.WorkflowRunHistory, run *WorkflowRun) {
+	history.UpdateTime = int(now())
+	switch {
+	case run.Error != nil:
+		history.ExecuteStatus = coze.WorkflowExecuteStatusFail
+		history.ErrorCode = strconv.Itoa(run.Error.ErrorCode)
+		history.ErrorMessage = run.Error.ErrorMessage
+	case run.Interrupt != nil && history.RunMode == coze.WorkflowRunModeStreaming:
+		history.ExecuteStatus = coze.WorkflowExecuteStatusRunning
+	default:
+		history.ExecuteStatus = coze.WorkflowExecuteStatusSuccess
+		history.Output = run.Output
+	}
+}
+
+// workflowEventsLocked returns the events of the stream of a run, and registers its interrupt.
+func (s *Server) workflowEventsLocked(workflowID string, history *coze.WorkflowRunHistory, run *WorkflowRun) []*sseEvent {
+	var events []*sseEvent
+	emit := func(event coze.WorkflowEventType, data any) {
+		events = append(events, &sseEvent{id: strconv.Itoa(len(events)), event: string(event), data: data})
+	}
+	if run.Events != nil {
+		for _, event := range run.Events {
+			switch {
+			case event.Message != nil:
+				emit(event.Event, event.Message)
+			case event.Interrupt != nil:
+				emit(event.Event, s.registerInterruptLocked(workflowID, history, event.Interrupt))
+			case event.Error != nil:
+				emit(event.Event, event.Error)
+			case event.DebugURL != nil:
+				emit(event.Event, event.DebugURL)
+			default:
+				emit(event.Event, "{}")
+			}
+		}
+		return events
+	}
+
+	if run.Output != "" || (run.Interrupt == nil && run.Error == nil) {
+		emit(coze.WorkflowEventTypeMessage, &coze.WorkflowEventMessage{
+			Content:      run.Output,
+			NodeTitle:    "End",
+			NodeSeqID:    "0",
+			NodeIsFinish: true,
+			Ext:          map[string]any{"execute_id": history.ExecuteID},
+		})
+	}
+	switch {
+	case run.Error != nil:
+		emit(coze.WorkflowEventTypeError, run.Error)
+	case run.Interrupt != nil:
+		emit(coze.WorkflowEventTypeInterrupt, s.registerInterruptLocked(workflowID, history, run.Interrupt))
+	default:
+		emit(coze.WorkflowEventTypeDone, &coze.WorkflowEventDebugURL{URL: history.DebugURL})
+	}
+	return events
+}
+
+// registerInterruptLocked registers the interrupt so that it can be resumed, and returns it with
+// its event ID set.
+func (s *Server) registerInterruptLocked(workflowID string, history *coze.WorkflowRunHistory, scripted *coze.WorkflowEventInterrupt) *coze.WorkflowEventInterrupt {
+	interrupted := *scripted
+	data := &coze.WorkflowEventInterruptData{}
+	if interrupted.InterruptData != nil {
+		*data = *interrupted.InterruptData
+	}
+	fillString(&data.EventID, s.newIDLocked())
+	interrupted.InterruptData = data
+	s.interrupts[data.EventID] = &interrupt{workflowID: workflowID, executeID: history.ExecuteID}
+	return &interrupted
+}
+
+func (s *Server) retrieveWorkflowHistory(c *call) {
+	executeID := c.params[1]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history, ok := s.histories[executeID]
+	if !ok {
+		c.notFound("workflow run", executeID)
+		return
+	}
+	c.data([]*coze.WorkflowRunHistory{history})
+}
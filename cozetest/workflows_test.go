@@ -0,0 +1,162 @@
+package cozetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+)
+
+func workflowEventTypes(events []*coze.WorkflowEvent) []coze.WorkflowEventType {
+	types := make([]coze.WorkflowEventType, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Event)
+	}
+	return types
+}
+
+func TestWorkflowRun(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sync", func(t *testing.T) {
+		server, client := newClient(t, nil)
+		server.ReplyWorkflow("wf", &WorkflowRun{Output: `{"output":"done"}`})
+
+		resp, err := client.Workflows.Runs.Create(ctx, &coze.RunWorkflowsReq{WorkflowID: "wf"})
+		require.NoError(t, err)
+		assert.Equal(t, `{"output":"done"}`, resp.Data)
+		assert.NotEmpty(t, resp.ExecuteID)
+
+		// without a reply, the parameters are the output
+		resp, err = client.Workflows.Runs.Create(ctx, &coze.RunWorkflowsReq{
+			WorkflowID: "wf",
+			Parameters: map[string]any{"city": "Paris"},
+		})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"city":"Paris"}`, resp.Data)
+	})
+
+	t.Run("async", func(t *testing.T) {
+		server, client := newClient(t, nil)
+		server.ReplyWorkflow("wf", &WorkflowRun{Output: "later"})
+
+		resp, err := client.Workflows.Runs.Create(ctx, &coze.RunWorkflowsReq{WorkflowID: "wf", IsAsync: true})
+		require.NoError(t, err)
+		assert.Empty(t, resp.Data)
+
+		histories, err := client.Workflows.Runs.Histories.Retrieve(ctx, &coze.RetrieveWorkflowsRunsHistoriesReq{
+			WorkflowID: "wf",
+			ExecuteID:  resp.ExecuteID,
+		})
+		require.NoError(t, err)
+		require.Len(t, histories.Histories, 1)
+		assert.Equal(t, coze.WorkflowExecuteStatusSuccess, histories.Histories[0].ExecuteStatus)
+		assert.Equal(t, coze.WorkflowRunModeAsynchronous, histories.Histories[0].RunMode)
+		assert.Equal(t, "later", histories.Histories[0].Output)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		server, client := newClient(t, nil)
+		server.ReplyWorkflow("wf", &WorkflowRun{Error: &coze.WorkflowEventError{ErrorCode: 5000, ErrorMessage: "node failed"}})
+
+		_, err := client.Workflows.Runs.Create(ctx, &coze.RunWorkflowsReq{WorkflowID: "wf"})
+		assert.True(t, errors.Is(err, coze.ErrServerError))
+	})
+
+	t.Run("history not found", func(t *testing.T) {
+		_, client := newClient(t, nil)
+		_, err := client.Workflows.Runs.Histories.Retrieve(ctx, &coze.RetrieveWorkflowsRunsHistoriesReq{
+			WorkflowID: "wf",
+			ExecuteID:  "1",
+		})
+		assert.True(t, errors.Is(err, coze.ErrNotFound))
+	})
+}
+
+func TestWorkflowStream(t *testing.T) {
+	server, client := newClient(t, nil)
+	ctx := context.Background()
+	server.ReplyWorkflow("wf", &WorkflowRun{Output: "done"})
+
+	stream, err := client.Workflows.Runs.Stream(ctx, &coze.RunWorkflowsReq{WorkflowID: "wf"})
+	require.NoError(t, err)
+	events, err := recvAll[coze.WorkflowEvent](t, stream)
+	require.NoError(t, err)
+	assert.Equal(t, []coze.WorkflowEventType{coze.WorkflowEventTypeMessage, coze.WorkflowEventTypeDone}, workflowEventTypes(events))
+	assert.Equal(t, "done", events[0].Message.Content)
+	assert.Equal(t, "End", events[0].Message.NodeTitle)
+}
+
+func TestWorkflowInterruptAndResume(t *testing.T) {
+	server, client := newClient(t, nil)
+	ctx := context.Background()
+	server.ReplyWorkflow("wf", &WorkflowRun{Interrupt: &coze.WorkflowEventInterrupt{NodeTitle: "Question"}})
+
+	stream, err := client.Workflows.Runs.Stream(ctx, &coze.RunWorkflowsReq{WorkflowID: "wf"})
+	require.NoError(t, err)
+	events, err := recvAll[coze.WorkflowEvent](t, stream)
+	require.NoError(t, err)
+	require.Equal(t, []coze.WorkflowEventType{coze.WorkflowEventTypeInterrupt}, workflowEventTypes(events))
+	interruptData := events[0].Interrupt.InterruptData
+	require.NotEmpty(t, interruptData.EventID)
+
+	stream, err = client.Workflows.Runs.Resume(ctx, &coze.ResumeRunWorkflowsReq{
+		WorkflowID: "wf",
+		EventID:    interruptData.EventID,
+		ResumeData: "Paris",
+	})
+	require.NoError(t, err)
+	events, err = recvAll[coze.WorkflowEvent](t, stream)
+	require.NoError(t, err)
+	assert.Equal(t, []coze.WorkflowEventType{coze.WorkflowEventTypeMessage, coze.WorkflowEventTypeDone}, workflowEventTypes(events))
+	assert.Equal(t, "Paris", events[0].Message.Content)
+
+	// an interrupt is resumed once
+	_, err = client.Workflows.Runs.Resume(ctx, &coze.ResumeRunWorkflowsReq{
+		WorkflowID: "wf",
+		EventID:    interruptData.EventID,
+		ResumeData: "Paris",
+	})
+	assert.True(t, errors.Is(err, coze.ErrNotFound))
+}
+
+func TestWorkflowStreamError(t *testing.T) {
+	server, client := newClient(t, nil)
+	server.ReplyWorkflow("wf", &WorkflowRun{Error: &coze.WorkflowEventError{ErrorCode: 4000, ErrorMessage: "bad input"}})
+
+	stream, err := client.Workflows.Runs.Stream(context.Background(), &coze.RunWorkflowsReq{WorkflowID: "wf"})
+	require.NoError(t, err)
+	events, err := recvAll[coze.WorkflowEvent](t, stream)
+	require.NoError(t, err)
+	require.Equal(t, []coze.WorkflowEventType{coze.WorkflowEventTypeError}, workflowEventTypes(events))
+	assert.Equal(t, 4000, events[0].Error.ErrorCode)
+
+	// returned from Recv with WithStreamErrorsReturned
+	server, client = newClient(t, nil, coze.WithStreamErrorsReturned(true))
+	server.ReplyWorkflow("wf", &WorkflowRun{Error: &coze.WorkflowEventError{ErrorCode: 4000, ErrorMessage: "bad input"}})
+
+	stream, err = client.Workflows.Runs.Stream(context.Background(), &coze.RunWorkflowsReq{WorkflowID: "wf"})
+	require.NoError(t, err)
+	events, err = recvAll[coze.WorkflowEvent](t, stream)
+	assert.Empty(t, events)
+	cozeErr, ok := coze.AsCozeError(err)
+	require.True(t, ok)
+	assert.Equal(t, 4000, cozeErr.Code)
+	assert.Equal(t, "bad input", cozeErr.Message)
+}
+
+func TestWorkflowStreamResumable(t *testing.T) {
+	server, client := newClient(t, nil)
+	server.ReplyWorkflow("wf", &WorkflowRun{Output: "done", DisconnectAfter: 1})
+
+	stream, err := client.Workflows.Runs.StreamResumable(context.Background(), &coze.RunWorkflowsReq{WorkflowID: "wf"})
+	require.NoError(t, err)
+	events, err := recvAll[coze.WorkflowEvent](t, stream)
+	require.NoError(t, err)
+	assert.Equal(t, []coze.WorkflowEventType{coze.WorkflowEventTypeMessage, coze.WorkflowEventTypeDone}, workflowEventTypes(events))
+	assert.Equal(t, "done", events[0].Message.Content)
+}
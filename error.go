@@ -1,14 +1,189 @@
 package coze
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
 )
 
+// The kinds of errors returned by the client, to be checked with errors.Is:
+//
+//	if errors.Is(err, coze.ErrRateLimited) { ... }
+var (
+	ErrRateLimited      = errors.New("coze: rate limited")
+	ErrAuthFailed       = errors.New("coze: authentication failed")
+	ErrNotFound         = errors.New("coze: not found")
+	ErrInvalidParameter = errors.New("coze: invalid parameter")
+	ErrServerError      = errors.New("coze: server error")
+	ErrStreamProtocol   = errors.New("coze: stream protocol error")
+	ErrTimeout          = errors.New("coze: timeout")
+)
+
+// statusKind returns the kind of error of an HTTP status, or nil.
+func statusKind(status int) error {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuthFailed
+	case status == http.StatusNotFound:
+		return ErrNotFound
+	case status == http.StatusBadRequest:
+		return ErrInvalidParameter
+	case status >= http.StatusInternalServerError:
+		return ErrServerError
+	default:
+		return nil
+	}
+}
+
+// codeKind returns the kind of error of a Coze error code, or nil.
+func codeKind(code int) error {
+	switch code {
+	case 4000:
+		return ErrInvalidParameter
+	case 4013:
+		return ErrRateLimited
+	case 4100, 4101:
+		return ErrAuthFailed
+	case 4200:
+		return ErrNotFound
+	case 5000:
+		return ErrServerError
+	default:
+		return nil
+	}
+}
+
+// IsRetryable reports whether the request which failed with err may succeed if sent again: when it
+// was rate limited, timed out, failed in transport or on the server. A canceled or expired context
+// of the caller is never retryable, nor are the certificate and TLS failures, an unknown host, and
+// the errors of a request which could not be built, such as an invalid URL.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var reqErr *requestError
+	if errors.As(err, &reqErr) && reqErr.canceled {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, ErrTimeout) {
+		return false
+	}
+	if httpErr, ok := AsHTTPError(err); ok && httpErr.StatusCode == http.StatusNotImplemented {
+		return false
+	}
+	if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTimeout) || errors.Is(err, ErrServerError) {
+		return true
+	}
+	var netErr net.Error
+	if !errors.As(err, &reqErr) && !errors.As(err, &netErr) {
+		return false
+	}
+	return !isPermanentTransportError(err)
+}
+
+// isPermanentTransportError reports whether a transport error fails the same way every time the
+// request is sent.
+func isPermanentTransportError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsNotFound
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if urlErr.Op == "parse" {
+			return true
+		}
+		// the client does not send the requests of an URL without host or of another scheme
+		u, parseErr := url.Parse(urlErr.URL)
+		if urlErr.URL != "" && parseErr == nil && (u.Host == "" || (u.Scheme != "http" && u.Scheme != "https")) {
+			return true
+		}
+	}
+	var (
+		unknownAuthorityErr x509.UnknownAuthorityError
+		hostnameErr         x509.HostnameError
+		invalidErr          x509.CertificateInvalidError
+		systemRootsErr      x509.SystemRootsError
+		recordHeaderErr     tls.RecordHeaderError
+	)
+	return errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr) || errors.As(err, &systemRootsErr) || errors.As(err, &recordHeaderErr)
+}
+
+// HTTPError is the HTTP response of a failed request. It is returned when the body is not a Coze
+// error, and wrapped by Error and AuthError otherwise, so that the status and the raw body are
+// available for every error.
+type HTTPError struct {
+	StatusCode int
+	// Body is the raw body of the response, it is redacted in the error string.
+	Body     string
+	LogID    string
+	redactor *Redactor
+}
+
+// Error implements the error interface
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HttpCode: %d, Body: %s, LogID: %s", e.StatusCode, e.redactor.body([]byte(e.Body)), e.LogID)
+}
+
+// Is reports whether the status of the response is of the target kind
+func (e *HTTPError) Is(target error) bool {
+	kind := statusKind(e.StatusCode)
+	return kind != nil && kind == target
+}
+
+// AsHTTPError checks if the error is of type HTTPError
+func AsHTTPError(err error) (*HTTPError, bool) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr, true
+	}
+	return nil, false
+}
+
+// requestError is an error of the client while sending a request, it matches ErrTimeout when the
+// request timed out in transport, e.g. after the timeout of the http.Client.
+type requestError struct {
+	parent   error
+	timeout  bool
+	canceled bool // the context of the caller is done
+	redactor *Redactor
+}
+
+func newRequestError(ctx context.Context, err error, redactor *Redactor) error {
+	if ctx.Err() != nil {
+		return &requestError{parent: err, canceled: true, redactor: redactor}
+	}
+	var netErr net.Error
+	timeout := errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+	return &requestError{parent: err, timeout: timeout, redactor: redactor}
+}
+
+func (e *requestError) Error() string {
+	return e.redactor.Redact(e.parent.Error())
+}
+
+func (e *requestError) Unwrap() error {
+	return e.parent
+}
+
+func (e *requestError) Is(target error) bool {
+	return e.timeout && target == ErrTimeout
+}
+
+// Error is a Coze API error, its code tells the reason of the failure.
 type Error struct {
 	Code    int
 	Message string
 	LogID   string
+	parent  error
 }
 
 func NewError(code int, msg, logID string) *Error {
@@ -27,6 +202,17 @@ func (e *Error) Error() string {
 		e.LogID)
 }
 
+// Unwrap returns the HTTPError of the response
+func (e *Error) Unwrap() error {
+	return e.parent
+}
+
+// Is reports whether the code of the error is of the target kind
+func (e *Error) Is(target error) bool {
+	kind := codeKind(e.Code)
+	return kind != nil && kind == target
+}
+
 // AsCozeError checks if the error is of type Error
 func AsCozeError(err error) (*Error, bool) {
 	var cozeErr *Error
@@ -99,11 +285,17 @@ func (e *AuthError) Error() string {
 		e.LogID)
 }
 
-// Unwrap returns the parent error
+// Unwrap returns the HTTPError of the response
 func (e *AuthError) Unwrap() error {
 	return e.parent
 }
 
+// Is reports whether the status of the error is of the target kind
+func (e *AuthError) Is(target error) bool {
+	kind := statusKind(e.HttpCode)
+	return kind != nil && kind == target
+}
+
 // AsAuthError 判断错误是否为 CozeAuthError 类型
 func AsAuthError(err error) (*AuthError, bool) {
 	var authErr *AuthError
@@ -112,3 +304,63 @@ func AsAuthError(err error) (*AuthError, bool) {
 	}
 	return nil, false
 }
+
+// DeviceAuthorizeReason tells why the device authorization failed
+type DeviceAuthorizeReason string
+
+const (
+	// DeviceAuthorizeDenied The user has denied the authorization
+	DeviceAuthorizeDenied DeviceAuthorizeReason = "denied"
+	// DeviceAuthorizeExpired The device code expired before the user authorized it
+	DeviceAuthorizeExpired DeviceAuthorizeReason = "expired"
+	// DeviceAuthorizeCanceled The context was canceled while waiting for the user
+	DeviceAuthorizeCanceled DeviceAuthorizeReason = "canceled"
+)
+
+// DeviceAuthorizeError is returned by DeviceOAuthClient.Authorize when the authorization did not
+// complete
+type DeviceAuthorizeError struct {
+	Reason DeviceAuthorizeReason
+	parent error
+}
+
+// Error implements the error interface
+func (e *DeviceAuthorizeError) Error() string {
+	return fmt.Sprintf("device authorization %s: %v", e.Reason, e.parent)
+}
+
+// Unwrap returns the parent error
+func (e *DeviceAuthorizeError) Unwrap() error {
+	return e.parent
+}
+
+// AsDeviceAuthorizeError checks if the error is of type DeviceAuthorizeError
+func AsDeviceAuthorizeError(err error) (*DeviceAuthorizeError, bool) {
+	var authorizeErr *DeviceAuthorizeError
+	if errors.As(err, &authorizeErr) {
+		return authorizeErr, true
+	}
+	return nil, false
+}
+
+// ChatFailedError is returned when a polled chat failed, the reason is in Chat.LastError
+type ChatFailedError struct {
+	Chat *Chat
+}
+
+// Error implements the error interface
+func (e *ChatFailedError) Error() string {
+	if e.Chat.LastError == nil {
+		return fmt.Sprintf("chat %s failed", e.Chat.ID)
+	}
+	return fmt.Sprintf("chat %s failed: code=%d, message=%s", e.Chat.ID, e.Chat.LastError.Code, e.Chat.LastError.Msg)
+}
+
+// AsChatFailedError checks if the error is of type ChatFailedError
+func AsChatFailedError(err error) (*ChatFailedError, bool) {
+	var failedErr *ChatFailedError
+	if errors.As(err, &failedErr) {
+		return failedErr, true
+	}
+	return nil, false
+}
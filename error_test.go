@@ -1,11 +1,20 @@
 package coze
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewCozeError(t *testing.T) {
@@ -230,3 +239,137 @@ func TestAsAuthError(t *testing.T) {
 		})
 	}
 }
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{"rate limited status", &HTTPError{StatusCode: http.StatusTooManyRequests}, ErrRateLimited},
+		{"unauthorized status", &AuthError{HttpCode: http.StatusUnauthorized}, ErrAuthFailed},
+		{"forbidden status", &HTTPError{StatusCode: http.StatusForbidden}, ErrAuthFailed},
+		{"not found status", &HTTPError{StatusCode: http.StatusNotFound}, ErrNotFound},
+		{"bad request status", &HTTPError{StatusCode: http.StatusBadRequest}, ErrInvalidParameter},
+		{"server error status", &HTTPError{StatusCode: http.StatusBadGateway}, ErrServerError},
+		{"invalid parameter code", NewError(4000, "invalid", "log"), ErrInvalidParameter},
+		{"auth code", NewError(4100, "invalid token", "log"), ErrAuthFailed},
+		{"not found code", NewError(4200, "not found", "log"), ErrNotFound},
+		{"rate limited code", NewError(4013, "too many requests", "log"), ErrRateLimited},
+		{"wrapped", fmt.Errorf("wrapped: %w", NewError(5000, "internal", "log")), ErrServerError},
+		{"stream protocol", newStreamDecodeError(context.Background(), &SSEEvent{Event: "x"}, errors.New("bad json")), ErrStreamProtocol},
+		{"timeout", newRequestError(context.Background(), context.DeadlineExceeded, nil), ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.err, tt.kind)
+			for _, other := range []error{ErrRateLimited, ErrAuthFailed, ErrNotFound, ErrInvalidParameter, ErrServerError, ErrStreamProtocol, ErrTimeout} {
+				if other != tt.kind {
+					assert.NotErrorIs(t, tt.err, other)
+				}
+			}
+		})
+	}
+
+	t.Run("code and status", func(t *testing.T) {
+		err := NewError(4000, "invalid", "log")
+		err.parent = &HTTPError{StatusCode: http.StatusTooManyRequests}
+		assert.ErrorIs(t, err, ErrInvalidParameter)
+		assert.ErrorIs(t, err, ErrRateLimited)
+	})
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.False(t, IsRetryable(nil))
+	assert.True(t, IsRetryable(&HTTPError{StatusCode: http.StatusTooManyRequests}))
+	assert.True(t, IsRetryable(&HTTPError{StatusCode: http.StatusServiceUnavailable}))
+	assert.False(t, IsRetryable(&HTTPError{StatusCode: http.StatusNotImplemented}))
+	assert.False(t, IsRetryable(&HTTPError{StatusCode: http.StatusBadRequest}))
+	assert.False(t, IsRetryable(NewError(4000, "invalid", "log")))
+	assert.True(t, IsRetryable(newRequestError(context.Background(), errors.New("connection reset"), nil)))
+	assert.True(t, IsRetryable(newRequestError(context.Background(), context.DeadlineExceeded, nil)))
+	assert.False(t, IsRetryable(newRequestError(context.Background(), context.Canceled, nil)))
+
+	// the deadline of the caller has passed, unlike the timeout of the transport above
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	deadlineErr := &url.Error{Op: "Post", URL: "https://api.coze.com/v3/chat", Err: context.DeadlineExceeded}
+	assert.False(t, IsRetryable(newRequestError(expired, deadlineErr, nil)))
+	assert.False(t, errors.Is(newRequestError(expired, deadlineErr, nil), ErrTimeout))
+	assert.False(t, IsRetryable(context.DeadlineExceeded))
+	assert.False(t, IsRetryable(fmt.Errorf("wait: %w", context.DeadlineExceeded)))
+	assert.False(t, IsRetryable(errors.New("other")))
+}
+
+func TestIsRetryableTransportErrors(t *testing.T) {
+	connErr := &url.Error{Op: "Get", URL: "https://api.coze.com/v1/files", Err: &net.OpError{
+		Op: "read", Net: "tcp", Err: errors.New("connection reset by peer"),
+	}}
+	parseErr := &url.Error{Op: "parse", URL: "://api.coze.com", Err: errors.New("missing protocol scheme")}
+	_, schemeErr := http.DefaultClient.Get("ftp://api.coze.com/v1/files")
+	require.Error(t, schemeErr)
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "connection reset", err: newRequestError(context.Background(), connErr, nil), want: true},
+		{name: "temporary dns failure", err: newRequestError(context.Background(), &url.Error{Op: "Get", Err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}}, nil), want: true},
+		{name: "host not found", err: newRequestError(context.Background(), &url.Error{Op: "Get", Err: &net.DNSError{Err: "no such host", Name: "api.coze.test", IsNotFound: true}}, nil)},
+		{name: "unknown authority", err: newRequestError(context.Background(), &url.Error{Op: "Get", Err: x509.UnknownAuthorityError{}}, nil)},
+		{name: "hostname mismatch", err: newRequestError(context.Background(), &url.Error{Op: "Get", Err: x509.HostnameError{Host: "api.coze.com"}}, nil)},
+		{name: "expired certificate", err: newRequestError(context.Background(), &url.Error{Op: "Get", Err: x509.CertificateInvalidError{Reason: x509.Expired}}, nil)},
+		{name: "not a tls server", err: newRequestError(context.Background(), &url.Error{Op: "Get", Err: tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}}, nil)},
+		{name: "invalid url", err: fmt.Errorf("create request: %w", parseErr)},
+		{name: "unsupported scheme", err: newRequestError(context.Background(), schemeErr, nil)},
+		{name: "option error", err: fmt.Errorf("apply option: %w", parseErr)},
+		{name: "file error of an option", err: fmt.Errorf("apply option: %w", &os.PathError{Op: "open", Path: "file", Err: os.ErrNotExist})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsRetryable(tt.err))
+		})
+	}
+}
+
+func TestCheckHttpRespErrors(t *testing.T) {
+	newResp := func(status int, body string) *http.Response {
+		resp, _ := statusResp(status, body, nil)(nil)
+		return resp
+	}
+
+	t.Run("auth error", func(t *testing.T) {
+		err := checkHttpResp(context.Background(), newResp(http.StatusUnauthorized, `{"error_code":"invalid_token","error_message":"bad token"}`))
+		authErr, ok := AsAuthError(err)
+		require.True(t, ok)
+		assert.Equal(t, AuthErrorCode("invalid_token"), authErr.Code)
+		assert.ErrorIs(t, err, ErrAuthFailed)
+		httpErr, ok := AsHTTPError(err)
+		require.True(t, ok)
+		assert.Equal(t, `{"error_code":"invalid_token","error_message":"bad token"}`, httpErr.Body)
+	})
+
+	t.Run("coze error", func(t *testing.T) {
+		err := checkHttpResp(context.Background(), newResp(http.StatusNotFound, `{"code":4200,"msg":"bot not found"}`))
+		cozeErr, ok := AsCozeError(err)
+		require.True(t, ok)
+		assert.Equal(t, 4200, cozeErr.Code)
+		assert.Equal(t, "test-log-id", cozeErr.LogID)
+		assert.ErrorIs(t, err, ErrNotFound)
+		httpErr, ok := AsHTTPError(err)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+	})
+
+	t.Run("not json", func(t *testing.T) {
+		err := checkHttpResp(context.Background(), newResp(http.StatusBadGateway, "<html>bad gateway</html>"))
+		httpErr, ok := AsHTTPError(err)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusBadGateway, httpErr.StatusCode)
+		assert.Equal(t, "<html>bad gateway</html>", httpErr.Body)
+		assert.Equal(t, "test-log-id", httpErr.LogID)
+		assert.ErrorIs(t, err, ErrServerError)
+		assert.True(t, IsRetryable(err))
+	})
+}
@@ -0,0 +1,95 @@
+package coze
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// CallKind identifies which kind of API call an interceptor is wrapping.
+type CallKind string
+
+const (
+	// CallKindRequest A JSON request whose response is decoded into Call.Instance.
+	CallKindRequest CallKind = "request"
+	// CallKindRawRequest A request whose raw response body is handed to the caller, e.g. audio speech.
+	CallKindRawRequest CallKind = "raw_request"
+	// CallKindStreamRequest A streaming request, the response body is read as server-sent events.
+	CallKindStreamRequest CallKind = "stream_request"
+	// CallKindUploadFile A multipart file upload whose response is decoded into Call.Instance.
+	CallKindUploadFile CallKind = "upload_file"
+)
+
+// Call describes an API call passing through the interceptor chain.
+//
+// Interceptors may change the request fields before calling next, e.g. to mutate the body or
+// append RequestOption values, and can read the response fields after next returns.
+type Call struct {
+	Kind   CallKind
+	Method string
+	Path   string
+
+	// Body is the request body, encoded as JSON. It is nil for uploads.
+	Body any
+
+	// File, FileName and Fields are the multipart content of an upload.
+	File     io.Reader
+	FileName string
+	Fields   map[string]string
+
+	// Options are applied to the underlying http.Request.
+	Options []RequestOption
+
+	// Instance is the typed response the body is decoded into, for Request and UploadFile calls.
+	// When it embeds baseResponse, the Coze code and msg are available through CozeCode.
+	Instance any
+
+	// HTTPResponse is the raw response, set once the server has answered. Its body has already been
+	// consumed for Request and UploadFile calls.
+	HTTPResponse *http.Response
+}
+
+// LogID returns the log ID of the response, or an empty string if no response was received.
+func (c *Call) LogID() string {
+	if c.HTTPResponse == nil {
+		return ""
+	}
+	return c.HTTPResponse.Header.Get(httpLogIDKey)
+}
+
+// CozeCode returns the code and msg of the Coze response envelope, if the response has been decoded.
+func (c *Call) CozeCode() (int, string, bool) {
+	resp, ok := c.Instance.(baseRespInterface)
+	if !ok || c.HTTPResponse == nil {
+		return 0, "", false
+	}
+	return resp.GetCode(), resp.GetMsg(), true
+}
+
+// CallHandler performs the call, or the rest of the interceptor chain.
+type CallHandler func(ctx context.Context, call *Call) error
+
+// Interceptor wraps every API call made by the client. It must call next to proceed with the
+// call, or may return without calling it to short-circuit, e.g. when serving from a cache; in that
+// case it is responsible for filling Instance or HTTPResponse.
+type Interceptor func(ctx context.Context, call *Call, next CallHandler) error
+
+// invoke runs the call through the interceptors, the first interceptor being the outermost one.
+// When a tracer or metrics are set, the call is traced and measured outside all interceptors.
+func (c *core) invoke(ctx context.Context, call *Call, handler CallHandler) error {
+	ctx = withLogger(ctx, c.log)
+	interceptors := c.interceptors
+	if c.metrics != nil {
+		interceptors = append([]Interceptor{c.metricsCall}, interceptors...)
+	}
+	if c.tracer != nil {
+		interceptors = append([]Interceptor{c.traceCall}, interceptors...)
+	}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, call *Call) error {
+			return interceptor(ctx, call, next)
+		}
+	}
+	return handler(ctx, call)
+}
@@ -0,0 +1,104 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("chain order and call details", func(t *testing.T) {
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			statusResp(http.StatusOK, `{"code":4000,"msg":"invalid param"}`, nil),
+		}}
+		var order []string
+		var seen *Call
+		var seenErr error
+		core := newCore(&clientOption{baseURL: "https://api.test.com", client: mock, interceptors: []Interceptor{
+			func(ctx context.Context, call *Call, next CallHandler) error {
+				order = append(order, "outer")
+				seenErr = next(ctx, call)
+				seen = call
+				return seenErr
+			},
+			func(ctx context.Context, call *Call, next CallHandler) error {
+				order = append(order, "inner")
+				return next(ctx, call)
+			},
+		}})
+
+		var resp TestResponse
+		err := core.Request(ctx, http.MethodPost, "/v3/chat", &TestReq{Test: "test"}, &resp)
+		require.Error(t, err)
+		assert.Equal(t, []string{"outer", "inner"}, order)
+		assert.Equal(t, CallKindRequest, seen.Kind)
+		assert.Equal(t, http.MethodPost, seen.Method)
+		assert.Equal(t, "/v3/chat", seen.Path)
+		assert.Equal(t, "test-log-id", seen.LogID())
+		code, msg, ok := seen.CozeCode()
+		assert.True(t, ok)
+		assert.Equal(t, 4000, code)
+		assert.Equal(t, "invalid param", msg)
+		assert.Equal(t, err, seenErr)
+	})
+
+	t.Run("mutate request", func(t *testing.T) {
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			statusResp(http.StatusOK, `{"code":0,"msg":""}`, nil),
+		}}
+		core := newCore(&clientOption{baseURL: "https://api.test.com", client: mock, interceptors: []Interceptor{
+			func(ctx context.Context, call *Call, next CallHandler) error {
+				call.Body = &TestReq{Test: "mutated"}
+				call.Options = append(call.Options, withHTTPHeader("X-Audit", "1"))
+				return next(ctx, call)
+			},
+		}})
+
+		var resp TestResponse
+		require.NoError(t, core.Request(ctx, http.MethodPost, "/test", &TestReq{Test: "test"}, &resp))
+		assert.Contains(t, mock.bodies[0], "mutated")
+		assert.Equal(t, "1", mock.requests[0].Header.Get("X-Audit"))
+	})
+
+	t.Run("short-circuit from cache", func(t *testing.T) {
+		mock := &sequenceHTTP{}
+		core := newCore(&clientOption{baseURL: "https://api.test.com", client: mock, interceptors: []Interceptor{
+			func(ctx context.Context, call *Call, next CallHandler) error {
+				return json.Unmarshal([]byte(`{"data":{"name":"cached"}}`), call.Instance)
+			},
+		}})
+
+		var resp TestResponse
+		require.NoError(t, core.Request(ctx, http.MethodGet, "/test", nil, &resp))
+		assert.Equal(t, "cached", resp.Data.Name)
+		assert.Empty(t, mock.requests)
+	})
+
+	t.Run("raw, stream and upload calls", func(t *testing.T) {
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			statusResp(http.StatusOK, `{"code":0,"msg":""}`, nil),
+		}}
+		var kinds []CallKind
+		core := newCore(&clientOption{baseURL: "https://api.test.com", client: mock, interceptors: []Interceptor{
+			func(ctx context.Context, call *Call, next CallHandler) error {
+				kinds = append(kinds, call.Kind)
+				return next(ctx, call)
+			},
+		}})
+
+		_, err := core.RawRequest(ctx, http.MethodPost, "/v1/audio/speech", nil)
+		require.NoError(t, err)
+		_, err = core.StreamRequest(ctx, http.MethodPost, "/v3/chat", nil)
+		require.NoError(t, err)
+		var resp TestResponse
+		require.NoError(t, core.UploadFile(ctx, "/v1/files/upload", strings.NewReader("content"), "a.txt", nil, &resp))
+		assert.Equal(t, []CallKind{CallKindRawRequest, CallKindStreamRequest, CallKindUploadFile}, kinds)
+	})
+}
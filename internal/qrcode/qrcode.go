@@ -0,0 +1,497 @@
+// Package qrcode renders text as a QR code in a terminal, e.g. the verification URL of the device
+// flow. It only implements what a URL needs: the byte mode, the error correction level M and the
+// versions 1 to 10, which hold up to 213 bytes.
+package qrcode
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// versionInfo is the layout of a QR code version at error correction level M.
+type versionInfo struct {
+	// ecPerBlock is the number of error correction codewords of every block.
+	ecPerBlock int
+	// blocks is the number of data codewords of each block.
+	blocks []int
+	// alignment is the coordinates of the centers of the alignment patterns.
+	alignment []int
+}
+
+var versions = [...]versionInfo{
+	{ecPerBlock: 10, blocks: []int{16}},
+	{ecPerBlock: 16, blocks: []int{28}, alignment: []int{6, 18}},
+	{ecPerBlock: 26, blocks: []int{44}, alignment: []int{6, 22}},
+	{ecPerBlock: 18, blocks: []int{32, 32}, alignment: []int{6, 26}},
+	{ecPerBlock: 24, blocks: []int{43, 43}, alignment: []int{6, 30}},
+	{ecPerBlock: 16, blocks: []int{27, 27, 27, 27}, alignment: []int{6, 34}},
+	{ecPerBlock: 18, blocks: []int{31, 31, 31, 31}, alignment: []int{6, 22, 38}},
+	{ecPerBlock: 22, blocks: []int{38, 38, 39, 39}, alignment: []int{6, 24, 42}},
+	{ecPerBlock: 22, blocks: []int{36, 36, 36, 37, 37}, alignment: []int{6, 26, 46}},
+	{ecPerBlock: 26, blocks: []int{43, 43, 43, 43, 44}, alignment: []int{6, 28, 50}},
+}
+
+func (v *versionInfo) dataCodewords() int {
+	n := 0
+	for _, size := range v.blocks {
+		n += size
+	}
+	return n
+}
+
+// ErrTooLong is returned by Write for a text longer than 213 bytes.
+var ErrTooLong = errors.New("text too long for a QR code")
+
+// symbol is the modules of a QR code, true for the dark ones.
+type symbol struct {
+	version  int
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// encode encodes text in the smallest version which holds it.
+func encode(text string) (*symbol, error) {
+	for version := 1; version <= len(versions); version++ {
+		data, ok := encodeData(text, version)
+		if !ok {
+			continue
+		}
+		q := newSymbol(version)
+		q.drawFunctionPatterns()
+		q.drawCodewords(addErrorCorrection(data, &versions[version-1]))
+		q.applyBestMask()
+		return q, nil
+	}
+	return nil, ErrTooLong
+}
+
+func newSymbol(version int) *symbol {
+	size := 17 + 4*version
+	q := &symbol{version: version, size: size}
+	q.modules = make([][]bool, size)
+	q.function = make([][]bool, size)
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.function[i] = make([]bool, size)
+	}
+	return q
+}
+
+// encodeData returns the data codewords of text in byte mode, or false if it does not fit.
+func encodeData(text string, version int) ([]byte, bool) {
+	capacity := versions[version-1].dataCodewords() * 8
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	if 4+countBits+8*len(text) > capacity {
+		return nil, false
+	}
+
+	bits := &bitBuffer{}
+	bits.append(0x4, 4)
+	bits.append(len(text), countBits)
+	for i := 0; i < len(text); i++ {
+		bits.append(int(text[i]), 8)
+	}
+	terminator := capacity - bits.len
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	bits.append(0, (8-bits.len%8)%8)
+	for pad := 0xEC; bits.len < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	return bits.bytes, true
+}
+
+type bitBuffer struct {
+	bytes []byte
+	len   int
+}
+
+func (b *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if b.len%8 == 0 {
+			b.bytes = append(b.bytes, 0)
+		}
+		if value>>uint(i)&1 != 0 {
+			b.bytes[b.len/8] |= 0x80 >> uint(b.len%8)
+		}
+		b.len++
+	}
+}
+
+// addErrorCorrection splits data into the blocks of the version, and returns their codewords
+// interleaved followed by their interleaved error correction codewords.
+func addErrorCorrection(data []byte, v *versionInfo) []byte {
+	divisor := reedSolomonDivisor(v.ecPerBlock)
+	blocks := make([][]byte, len(v.blocks))
+	ecBlocks := make([][]byte, len(v.blocks))
+	maxLen := 0
+	for i, size := range v.blocks {
+		blocks[i], data = data[:size], data[size:]
+		ecBlocks[i] = reedSolomonRemainder(blocks[i], divisor)
+		if size > maxLen {
+			maxLen = size
+		}
+	}
+
+	var result []byte
+	for i := 0; i < maxLen; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < v.ecPerBlock; i++ {
+		for _, ec := range ecBlocks {
+			result = append(result, ec[i])
+		}
+	}
+	return result
+}
+
+// reedSolomonDivisor returns the coefficients of the generator polynomial of degree n, without
+// its leading 1.
+func reedSolomonDivisor(n int) []byte {
+	result := make([]byte, n)
+	result[n-1] = 1
+	root := byte(1)
+	for i := 0; i < n; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < n {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int(y>>uint(i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func (q *symbol) setFunction(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+func (q *symbol) drawFunctionPatterns() {
+	for i := 0; i < q.size; i++ {
+		q.setFunction(6, i, i%2 == 0)
+		q.setFunction(i, 6, i%2 == 0)
+	}
+
+	q.drawFinderPattern(3, 3)
+	q.drawFinderPattern(q.size-4, 3)
+	q.drawFinderPattern(3, q.size-4)
+
+	alignment := versions[q.version-1].alignment
+	last := len(alignment) - 1
+	for i, x := range alignment {
+		for j, y := range alignment {
+			// the corners of the finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.setFunction(x+dx, y+dy, maxAbs(dx, dy) != 1)
+				}
+			}
+		}
+	}
+
+	// reserve the format areas, drawn once the mask is chosen
+	q.drawFormatBits(0)
+	q.drawVersion()
+}
+
+// drawFinderPattern draws the finder pattern centered on x, y, and its separator.
+func (q *symbol) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= q.size || yy < 0 || yy >= q.size {
+				continue
+			}
+			dist := maxAbs(dx, dy)
+			q.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func maxAbs(a, b int) int {
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// formatBits returns the format information of level M and mask.
+func formatBits(mask int) int {
+	// the level M is 00
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (q *symbol) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return bits>>uint(i)&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		q.setFunction(8, i, bit(i))
+	}
+	q.setFunction(8, 7, bit(6))
+	q.setFunction(8, 8, bit(7))
+	q.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.setFunction(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.setFunction(8, q.size-15+i, bit(i))
+	}
+	q.setFunction(8, q.size-8, true)
+}
+
+// versionBits returns the version information, drawn from version 7.
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return version<<12 | rem
+}
+
+func (q *symbol) drawVersion() {
+	if q.version < 7 {
+		return
+	}
+	bits := versionBits(q.version)
+	for i := 0; i < 18; i++ {
+		dark := bits>>uint(i)&1 != 0
+		a, b := q.size-11+i%3, i/3
+		q.setFunction(a, b, dark)
+		q.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords places the codewords in the zigzag order, two columns at a time from the bottom
+// right corner.
+func (q *symbol) drawCodewords(data []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if upward {
+					y = q.size - 1 - vert
+				}
+				if q.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				q.modules[y][x] = data[i/8]>>uint(7-i%8)&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+func masked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// applyMask flips the data modules of mask, applying it twice restores them.
+func (q *symbol) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if !q.function[y][x] && masked(mask, x, y) {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+func (q *symbol) applyBestMask() {
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormatBits(mask)
+		if penalty := q.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormatBits(best)
+}
+
+// penalty scores the patterns which make a QR code hard to read, the lower the better.
+func (q *symbol) penalty() int {
+	penalty := 0
+	line := make([]bool, q.size)
+	for _, vertical := range []bool{false, true} {
+		for i := 0; i < q.size; i++ {
+			for j := 0; j < q.size; j++ {
+				if vertical {
+					line[j] = q.modules[j][i]
+				} else {
+					line[j] = q.modules[i][j]
+				}
+			}
+			penalty += linePenalty(line)
+		}
+	}
+
+	dark := 0
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < q.size && y+1 < q.size {
+				c := q.modules[y][x]
+				if c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+	deviation := dark*100/(q.size*q.size) - 50
+	if deviation < 0 {
+		deviation = -deviation
+	}
+	return penalty + deviation/5*10
+}
+
+var (
+	finderLike        = []bool{true, false, true, true, true, false, true, false, false, false, false}
+	finderLikeReverse = []bool{false, false, false, false, true, false, true, true, true, false, true}
+)
+
+func linePenalty(line []bool) int {
+	penalty := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			penalty += run - 2
+		}
+		run = 1
+	}
+	for i := 0; i+len(finderLike) <= len(line); i++ {
+		if lineHas(line[i:], finderLike) || lineHas(line[i:], finderLikeReverse) {
+			penalty += 40
+		}
+	}
+	return penalty
+}
+
+func lineHas(line, pattern []bool) bool {
+	for i, dark := range pattern {
+		if line[i] != dark {
+			return false
+		}
+	}
+	return true
+}
+
+// Write writes text as a QR code for a terminal, two modules per character. The colors are
+// set with ANSI escapes so that the code scans whatever the theme of the terminal.
+func Write(w io.Writer, text string) error {
+	q, err := encode(text)
+	if err != nil {
+		return err
+	}
+	const quiet = 4
+	dark := func(x, y int) bool {
+		x, y = x-quiet, y-quiet
+		return x >= 0 && x < q.size && y >= 0 && y < q.size && q.modules[y][x]
+	}
+
+	var sb strings.Builder
+	size := q.size + 2*quiet
+	for y := 0; y < size; y += 2 {
+		sb.WriteString("\x1b[30;47m")
+		for x := 0; x < size; x++ {
+			switch top, bottom := dark(x, y), dark(x, y+1); {
+			case top && bottom:
+				sb.WriteString("█")
+			case top:
+				sb.WriteString("▀")
+			case bottom:
+				sb.WriteString("▄")
+			default:
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString("\x1b[0m\n")
+	}
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
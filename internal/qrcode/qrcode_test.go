@@ -0,0 +1,198 @@
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// the codewords of HELLO WORLD in version 1-M
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	ec := reedSolomonRemainder(data, reedSolomonDivisor(10))
+	assert.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, ec)
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	assert.Equal(t, 0x5412, formatBits(0))
+	assert.Equal(t, 0x5125, formatBits(1))
+	assert.Equal(t, 0x07C94, versionBits(7))
+	assert.Equal(t, 0x0A4D3, versionBits(10))
+}
+
+func TestVersions(t *testing.T) {
+	// the codewords of each version fill all its data modules
+	for version := 1; version <= len(versions); version++ {
+		v := &versions[version-1]
+		q := newSymbol(version)
+		q.drawFunctionPatterns()
+		modules := 0
+		for y := range q.function {
+			for _, function := range q.function[y] {
+				if !function {
+					modules++
+				}
+			}
+		}
+		assert.Equal(t, modules/8, v.dataCodewords()+v.ecPerBlock*len(v.blocks), "version %d", version)
+	}
+}
+
+// decode reads back the text of a code drawn by encode.
+func decode(t *testing.T, q *symbol) string {
+	bit := func(x, y int) int {
+		if q.modules[y][x] {
+			return 1
+		}
+		return 0
+	}
+	var format, format2 int
+	for i := 0; i <= 5; i++ {
+		format |= bit(8, i) << uint(i)
+	}
+	format |= bit(8, 7)<<6 | bit(8, 8)<<7 | bit(7, 8)<<8
+	for i := 9; i < 15; i++ {
+		format |= bit(14-i, 8) << uint(i)
+	}
+	for i := 0; i < 8; i++ {
+		format2 |= bit(q.size-1-i, 8) << uint(i)
+	}
+	for i := 8; i < 15; i++ {
+		format2 |= bit(8, q.size-15+i) << uint(i)
+	}
+	require.Equal(t, format, format2)
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == format {
+			mask = m
+		}
+	}
+	require.NotEqual(t, -1, mask, "format bits %015b", format)
+
+	layout := newSymbol(q.version)
+	layout.drawFunctionPatterns()
+	layout.drawFormatBits(mask)
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if layout.function[y][x] {
+				require.Equal(t, layout.modules[y][x], q.modules[y][x], "function module %d,%d", x, y)
+				continue
+			}
+			layout.modules[y][x] = q.modules[y][x] != masked(mask, x, y)
+		}
+	}
+
+	var raw []byte
+	bits := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert
+				}
+				if layout.function[y][x] {
+					continue
+				}
+				if bits%8 == 0 {
+					raw = append(raw, 0)
+				}
+				if layout.modules[y][x] {
+					raw[bits/8] |= 0x80 >> uint(bits%8)
+				}
+				bits++
+			}
+		}
+	}
+
+	v := &versions[q.version-1]
+	blocks := make([][]byte, len(v.blocks))
+	pos := 0
+	for i := 0; pos < v.dataCodewords(); i++ {
+		for b, size := range v.blocks {
+			if i < size {
+				blocks[b] = append(blocks[b], raw[pos])
+				pos++
+			}
+		}
+	}
+	var data []byte
+	for b, block := range blocks {
+		var ec []byte
+		for i := 0; i < v.ecPerBlock; i++ {
+			ec = append(ec, raw[pos+i*len(v.blocks)+b])
+		}
+		require.Equal(t, reedSolomonRemainder(block, reedSolomonDivisor(v.ecPerBlock)), ec, "block %d", b)
+		data = append(data, block...)
+	}
+
+	reader := &bitReader{data: data}
+	require.Equal(t, 0x4, reader.read(4))
+	countBits := 8
+	if q.version >= 10 {
+		countBits = 16
+	}
+	text := make([]byte, reader.read(countBits))
+	for i := range text {
+		text[i] = byte(reader.read(8))
+	}
+	return string(text)
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(n int) int {
+	value := 0
+	for i := 0; i < n; i++ {
+		value = value<<1 | int(r.data[r.pos/8]>>uint(7-r.pos%8)&1)
+		r.pos++
+	}
+	return value
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		version int
+	}{
+		{name: "short", text: "HELLO WORLD", version: 1},
+		{name: "verification url", text: "https://www.coze.com/open/oauth/device?user_code=ABCD-EFGH", version: 4},
+		{name: "version information", text: strings.Repeat("coze", 30), version: 7},
+		{name: "longest", text: strings.Repeat("x", 213), version: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := encode(tt.text)
+			require.NoError(t, err)
+			assert.Equal(t, tt.version, q.version)
+			assert.Equal(t, tt.text, decode(t, q))
+		})
+	}
+
+	_, err := encode(strings.Repeat("x", 214))
+	assert.Equal(t, ErrTooLong, err)
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, "HELLO WORLD"))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	// 21 modules and the quiet zones, two rows per line
+	require.Len(t, lines, 15)
+	for _, line := range lines {
+		assert.True(t, strings.HasPrefix(line, "\x1b[30;47m"))
+		assert.Equal(t, 29, len([]rune(strings.TrimSuffix(strings.TrimPrefix(line, "\x1b[30;47m"), "\x1b[0m"))))
+	}
+	// the top left finder pattern, below the quiet zone
+	assert.Contains(t, lines[2], "    █▀▀▀▀▀█ ")
+}
@@ -0,0 +1,75 @@
+//go:build go1.23
+
+package coze
+
+import (
+	"io"
+	"iter"
+)
+
+// All returns an iterator over the events of the stream. The streams of the client are returned
+// as Stream, which does not have All so that other implementations keep compiling, StreamAll
+// iterates over them.
+func (s *streamReader[T]) All() iter.Seq2[*T, error] {
+	return StreamAll[T](s)
+}
+
+func (s *resumableStream[T]) All() iter.Seq2[*T, error] {
+	return StreamAll[T](s)
+}
+
+func (s *toolStream) All() iter.Seq2[*ChatEvent, error] {
+	return StreamAll[ChatEvent](s)
+}
+
+func (p *implNumberPaged[T]) All() iter.Seq2[*T, error] {
+	return PagedAll[T](p)
+}
+
+func (p *implLastIDPaged[T]) All() iter.Seq2[*T, error] {
+	return PagedAll[T](p)
+}
+
+// StreamAll returns an iterator over the events of the stream, to be used with range:
+//
+//	for event, err := range stream.All() {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// The iteration stops after the first error, and the stream is closed when it ends.
+func StreamAll[T streamable](stream Stream[T]) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		defer stream.Close()
+		for {
+			event, err := stream.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(event, nil) {
+				return
+			}
+		}
+	}
+}
+
+// PagedAll returns an iterator over the items of all the pages, fetching the next pages as needed.
+// The iteration stops after the first error.
+func PagedAll[T any](pager BasePaged[T]) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		for pager.Next() {
+			if !yield(pager.Current(), nil) {
+				return
+			}
+		}
+		if err := pager.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
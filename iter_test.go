@@ -0,0 +1,99 @@
+//go:build go1.23
+
+package coze
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamAll(t *testing.T) {
+	resp := createMockResponse([]string{"first", "second", "done"})
+	stream := newStreamReader[WorkflowEvent](context.Background(), resp, mockEventProcessor)
+
+	var contents []string
+	for event, err := range StreamAll[WorkflowEvent](stream) {
+		require.NoError(t, err)
+		contents = append(contents, event.Message.Content)
+	}
+	assert.Equal(t, []string{"first", "second", "done"}, contents)
+
+	t.Run("stops after an error", func(t *testing.T) {
+		resp, _ := mockDroppedStreamResponse("data: first\n\n")
+		stream := newStreamReader[WorkflowEvent](context.Background(), resp, mockEventProcessor)
+
+		var errs []error
+		for _, err := range StreamAll[WorkflowEvent](stream) {
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		require.Len(t, errs, 1)
+		assert.EqualError(t, errs[0], "connection reset by peer")
+	})
+}
+
+func TestPagedAll(t *testing.T) {
+	pager, err := NewNumberPaged[TestData](newMockDataSource(25).getNumberPageData, 10, 1)
+	require.NoError(t, err)
+
+	count := 0
+	for item, err := range PagedAll[TestData](pager) {
+		require.NoError(t, err)
+		count++
+		assert.Equal(t, count, item.ID)
+		if count == 15 {
+			break
+		}
+	}
+	assert.Equal(t, 15, count)
+
+	t.Run("yields the page error", func(t *testing.T) {
+		calls := 0
+		pager, err := NewNumberPaged[TestData](func(request *pageRequest) (*pageResponse[TestData], error) {
+			calls++
+			if calls > 1 {
+				return nil, errors.New("mock error")
+			}
+			return &pageResponse[TestData]{HasMore: true, Data: []*TestData{{ID: 1}}}, nil
+		}, 1, 1)
+		require.NoError(t, err)
+
+		var items []*TestData
+		var lastErr error
+		for item, err := range PagedAll[TestData](pager) {
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			items = append(items, item)
+		}
+		assert.Len(t, items, 1)
+		assert.EqualError(t, lastErr, "mock error")
+	})
+}
+
+func TestAllMethods(t *testing.T) {
+	stream := newStreamReader[WorkflowEvent](context.Background(),
+		createMockResponse([]string{"first", "done"}), mockEventProcessor)
+	var contents []string
+	for event, err := range stream.All() {
+		require.NoError(t, err)
+		contents = append(contents, event.Message.Content)
+	}
+	assert.Equal(t, []string{"first", "done"}, contents)
+
+	pager, err := NewNumberPaged[TestData](newMockDataSource(25).getNumberPageData, 10, 1)
+	require.NoError(t, err)
+	count := 0
+	for item, err := range pager.(*implNumberPaged[TestData]).All() {
+		require.NoError(t, err)
+		count++
+		assert.Equal(t, count, item.ID)
+	}
+	assert.Equal(t, 25, count)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 )
 
 // Logger ...
@@ -12,6 +13,20 @@ type Logger interface {
 	Log(ctx context.Context, level LogLevel, message string, args ...interface{})
 }
 
+// LogField is a structured attribute of a log line.
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+// FieldLogger is implemented by the loggers which accept structured fields, such as the one
+// returned by NewSlogLogger. The fields are appended to the message as key=value pairs for the
+// other loggers.
+type FieldLogger interface {
+	Logger
+	LogFields(ctx context.Context, level LogLevel, message string, fields ...LogField)
+}
+
 type LevelLogger interface {
 	Logger
 	SetLevel(level LogLevel)
@@ -69,6 +84,8 @@ func (l *stdLogger) Log(ctx context.Context, level LogLevel, message string, arg
 type levelLogger struct {
 	Logger
 	level LogLevel
+	// redactor redacts the lines and fields before they reach Logger, nil for no redaction.
+	redactor *Redactor
 }
 
 // NewLevelLogger ...
@@ -86,9 +103,46 @@ func (l *levelLogger) SetLevel(level LogLevel) {
 
 // Log ...
 func (l *levelLogger) Log(ctx context.Context, level LogLevel, message string, args ...interface{}) {
-	if level >= l.level {
+	if level < l.level {
+		return
+	}
+	if l.redactor == nil {
 		l.Logger.Log(ctx, level, message, args...)
+		return
+	}
+	if len(args) > 0 {
+		message = fmt.Sprintf(message, args...)
+	}
+	l.Logger.Log(ctx, level, "%s", l.redactor.Redact(message))
+}
+
+// enabled reports whether the lines of level are logged.
+func (l *levelLogger) enabled(level LogLevel) bool {
+	return level >= l.level
+}
+
+// logFields logs the message with structured fields.
+func (l *levelLogger) logFields(ctx context.Context, level LogLevel, message string, fields ...LogField) {
+	if !l.enabled(level) {
+		return
+	}
+	if l.redactor != nil {
+		redacted := make([]LogField, len(fields))
+		for i, field := range fields {
+			redacted[i] = l.redactor.redactField(field)
+		}
+		fields = redacted
+	}
+	if fieldLogger, ok := l.Logger.(FieldLogger); ok {
+		fieldLogger.LogFields(ctx, level, message, fields...)
+		return
 	}
+	var sb strings.Builder
+	sb.WriteString(message)
+	for _, field := range fields {
+		fmt.Fprintf(&sb, " %s=%v", field.Key, field.Value)
+	}
+	l.Logger.Log(ctx, level, "%s", sb.String())
 }
 
 func (l *levelLogger) Debugf(ctx context.Context, message string, args ...interface{}) {
@@ -107,15 +161,43 @@ func (l *levelLogger) Errorf(ctx context.Context, message string, args ...interf
 	l.Log(ctx, LogLevelError, message, args...)
 }
 
-var logger = levelLogger{
-	Logger: newStdLogger(),
-	level:  LogLevelInfo,
+// defaultLogger is the logger of the clients which do not set one, and of the code which is not
+// bound to a client.
+var defaultLogger = &levelLogger{
+	Logger:   newStdLogger(),
+	level:    LogLevelInfo,
+	redactor: defaultRedactor,
 }
 
-func setLogger(l Logger) {
-	logger.Logger = l
+// newClientLogger creates the logger of a client, the logger, level and redactor which are not set
+// default to the ones of defaultLogger.
+func newClientLogger(logger Logger, level LogLevel, redactor *Redactor) *levelLogger {
+	if logger == nil {
+		logger = defaultLogger.Logger
+	}
+	if level == 0 {
+		level = defaultLogger.level
+	}
+	if redactor == nil {
+		redactor = defaultLogger.redactor
+	}
+	return &levelLogger{Logger: logger, level: level, redactor: redactor}
 }
 
-func setLevel(level LogLevel) {
-	logger.level = level
+type loggerContextKey struct{}
+
+// withLogger returns a copy of ctx carrying the logger of a client, for the code called with
+// the context of a request, such as Auth implementations.
+func withLogger(ctx context.Context, l *levelLogger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, l)
+}
+
+// loggerFromContext returns the logger of the client carried by ctx, or defaultLogger.
+func loggerFromContext(ctx context.Context) *levelLogger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerContextKey{}).(*levelLogger); ok {
+			return l
+		}
+	}
+	return defaultLogger
 }
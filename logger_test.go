@@ -0,0 +1,131 @@
+package coze
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordLogger) Log(ctx context.Context, level LogLevel, message string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, "["+level.String()+"] "+fmt.Sprintf(message, args...))
+}
+
+func (l *recordLogger) Lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+func TestClientLogger(t *testing.T) {
+	failing := &http.Client{Transport: &mockTransport{
+		roundTripFunc: func(req *http.Request) (*http.Response, error) {
+			return mockResponse(http.StatusOK, &baseResponse{Code: 4000, Msg: "invalid"})
+		},
+	}}
+
+	comLogger, cnLogger := &recordLogger{}, &recordLogger{}
+	com := NewCozeAPI(NewTokenAuth("token"), WithBaseURL(ComBaseURL), WithHttpClient(failing), WithLogger(comLogger))
+	cn := NewCozeAPI(NewTokenAuth("token"), WithBaseURL(CnBaseURL), WithHttpClient(failing), WithLogger(cnLogger), WithLogLevel(LogLevelError))
+
+	_, err := com.Bots.Retrieve(context.Background(), &RetrieveBotsReq{BotID: "bot1"})
+	require.Error(t, err)
+	_, err = cn.Bots.Retrieve(context.Background(), &RetrieveBotsReq{BotID: "bot1"})
+	require.Error(t, err)
+
+	require.Len(t, comLogger.Lines(), 1)
+	assert.Contains(t, comLogger.Lines()[0], "[WARN] request failed")
+	// the warning is below the level of the second client
+	assert.Empty(t, cnLogger.Lines())
+	assert.Equal(t, LogLevelInfo, defaultLogger.level)
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	assert.Same(t, defaultLogger, loggerFromContext(context.Background()))
+
+	l := newClientLogger(&recordLogger{}, 0, nil)
+	assert.Equal(t, defaultLogger.level, l.level)
+	assert.Same(t, l, loggerFromContext(withLogger(context.Background(), l)))
+}
+
+func TestLogFieldsFallback(t *testing.T) {
+	record := &recordLogger{}
+	l := newClientLogger(record, LogLevelDebug, nil)
+	l.logFields(context.Background(), LogLevelDebug, "coze response", LogField{Key: "status", Value: 200}, LogField{Key: "log_id", Value: "abc"})
+	l.logFields(context.Background(), LogLevelTrace, "coze response body", LogField{Key: "body", Value: "{}"})
+	assert.Equal(t, []string{"[DEBUG] coze response status=200 log_id=abc"}, record.Lines())
+}
+
+func TestRequestLogging(t *testing.T) {
+	record := &recordLogger{}
+	core := newCore(&clientOption{
+		baseURL:  ComBaseURL,
+		logger:   record,
+		logLevel: LogLevelTrace,
+		client: &http.Client{Transport: &mockTransport{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				return mockResponse(http.StatusOK, &baseResponse{})
+			},
+		}},
+	})
+	resp := &baseResponse{}
+	err := core.Request(context.Background(), http.MethodGet, "/v1/bot/get_online_info", nil, resp, withHTTPQuery("bot_id", "bot1"))
+	require.NoError(t, err)
+
+	lines := record.Lines()
+	require.Len(t, lines, 3)
+	assert.Equal(t, "[DEBUG] coze request endpoint=/v1/bot/get_online_info method=GET attempt=1 bot_id=bot1", lines[0])
+	assert.Contains(t, lines[1], "[DEBUG] coze response endpoint=/v1/bot/get_online_info method=GET attempt=1 duration=")
+	assert.Contains(t, lines[1], "status=200 log_id=test_log_id")
+	assert.Equal(t, `[TRACE] coze response body endpoint=/v1/bot/get_online_info log_id=test_log_id body={"code":0,"msg":"","http_response":null}`, lines[2])
+}
+
+func TestResponseBodyLogging(t *testing.T) {
+	record := &recordLogger{}
+	audio := []byte{0xff, 0xfb, 0x90, 0x00}
+	core := newCore(&clientOption{
+		baseURL:  ComBaseURL,
+		logger:   record,
+		logLevel: LogLevelTrace,
+		client: &http.Client{Transport: &mockTransport{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				resp := &http.Response{
+					StatusCode:    http.StatusOK,
+					Header:        make(http.Header),
+					Body:          io.NopCloser(bytes.NewReader(audio)),
+					ContentLength: int64(len(audio)),
+				}
+				resp.Header.Set("Content-Type", "audio/mpeg")
+				resp.Header.Set(httpLogIDKey, "test_log_id")
+				return resp, nil
+			},
+		}},
+	})
+	resp, err := core.RawRequest(context.Background(), http.MethodPost, "/v1/audio/speech", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, audio, body)
+
+	lines := record.Lines()
+	require.Len(t, lines, 3)
+	assert.Equal(t, "[TRACE] coze response body endpoint=/v1/audio/speech log_id=test_log_id content_type=audio/mpeg size=4", lines[2])
+
+	assert.True(t, isTextContentType("text/plain; charset=utf-8"))
+	assert.False(t, isTextContentType(""))
+	assert.False(t, isTextContentType("application/octet-stream"))
+}
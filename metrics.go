@@ -0,0 +1,166 @@
+package coze
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Metrics receives structured observations about the API calls made by the client.
+// Implementations must be safe for concurrent use; see the prommetrics package for a
+// Prometheus-compatible implementation.
+type Metrics interface {
+	// ObserveRequest is called once per API call, when the response headers are received or the
+	// call failed.
+	ObserveRequest(ctx context.Context, metric *RequestMetric)
+
+	// ObserveRetry is called before every retry attempt.
+	ObserveRetry(ctx context.Context, metric *RetryMetric)
+
+	// ObserveStreamEvent is called for every event received from a stream.
+	ObserveStreamEvent(ctx context.Context, metric *StreamEventMetric)
+
+	// ObserveChatUsage is called with the token usage of every completed chat.
+	ObserveChatUsage(ctx context.Context, metric *ChatUsageMetric)
+}
+
+// RequestMetric describes a finished API call.
+type RequestMetric struct {
+	Endpoint string
+	Method   string
+	Kind     CallKind
+	// StatusCode is the HTTP status, zero if no response was received.
+	StatusCode int
+	// ErrorCode is the Coze error code, either the numeric code of Error or the code of AuthError.
+	ErrorCode string
+	Latency   time.Duration
+	Err       error
+}
+
+// RetryMetric describes a retry attempt.
+type RetryMetric struct {
+	Endpoint string
+	Method   string
+	// Attempt is the retry number, starting at 1.
+	Attempt int
+	// StatusCode is the HTTP status of the failed attempt, zero for transport errors.
+	StatusCode int
+	Wait       time.Duration
+}
+
+// StreamEventMetric describes an event received from a stream.
+type StreamEventMetric struct {
+	Endpoint string
+	// Event is the event type, e.g. conversation.message.delta.
+	Event string
+	// Index is the position of the event in the stream, starting at 0.
+	Index int
+	// SinceStart is the time elapsed since the request was sent. For the first event it is the
+	// time to first token.
+	SinceStart time.Duration
+	// Gap is the time elapsed since the previous event, zero for the first event.
+	Gap time.Duration
+}
+
+// ChatUsageMetric is the token usage of a completed chat.
+type ChatUsageMetric struct {
+	BotID        string
+	InputTokens  int
+	OutputTokens int
+	TotalTokens  int
+}
+
+// metricsCall observes the call, it runs inside the tracer and outside the user interceptors.
+func (c *core) metricsCall(ctx context.Context, call *Call, next CallHandler) error {
+	start := time.Now()
+	err := next(ctx, call)
+	metric := &RequestMetric{
+		Endpoint:  call.Path,
+		Method:    call.Method,
+		Kind:      call.Kind,
+		ErrorCode: errorCodeOf(err),
+		Latency:   time.Since(start),
+		Err:       err,
+	}
+	if call.HTTPResponse != nil {
+		metric.StatusCode = call.HTTPResponse.StatusCode
+	} else if authErr, ok := AsAuthError(err); ok {
+		metric.StatusCode = authErr.HttpCode
+	}
+	c.metrics.ObserveRequest(ctx, metric)
+
+	if err == nil && call.Kind == CallKindStreamRequest {
+		addStreamObserver(call.HTTPResponse, &streamMetrics{
+			metrics:  c.metrics,
+			endpoint: call.Path,
+			start:    start,
+			last:     start,
+		})
+	}
+	return err
+}
+
+func (c *core) observeChatUsage(ctx context.Context, chat *Chat) {
+	if c.metrics == nil || chat == nil || chat.Usage == nil || chat.Status != ChatStatusCompleted {
+		return
+	}
+	c.metrics.ObserveChatUsage(ctx, newChatUsageMetric(chat))
+}
+
+func newChatUsageMetric(chat *Chat) *ChatUsageMetric {
+	return &ChatUsageMetric{
+		BotID:        chat.BotID,
+		InputTokens:  chat.Usage.InputCount,
+		OutputTokens: chat.Usage.OutputCount,
+		TotalTokens:  chat.Usage.TokenCount,
+	}
+}
+
+func errorCodeOf(err error) string {
+	if err == nil {
+		return ""
+	}
+	if cozeErr, ok := AsCozeError(err); ok {
+		return strconv.Itoa(cozeErr.Code)
+	}
+	if authErr, ok := AsAuthError(err); ok {
+		return authErr.Code.String()
+	}
+	return ""
+}
+
+// streamMetrics reports the events of a stream, and the token usage of a completed chat.
+type streamMetrics struct {
+	metrics  Metrics
+	endpoint string
+	start    time.Time
+	last     time.Time
+	index    int
+}
+
+func (s *streamMetrics) onStreamEvent(ctx context.Context, event any) {
+	now := time.Now()
+	metric := &StreamEventMetric{
+		Endpoint:   s.endpoint,
+		Index:      s.index,
+		SinceStart: now.Sub(s.start),
+	}
+	if s.index > 0 {
+		metric.Gap = now.Sub(s.last)
+	}
+	s.index++
+	s.last = now
+
+	switch e := event.(type) {
+	case *ChatEvent:
+		metric.Event = string(e.Event)
+		if e.Event == ChatEventConversationChatCompleted && e.Chat != nil && e.Chat.Usage != nil {
+			s.metrics.ObserveChatUsage(ctx, newChatUsageMetric(e.Chat))
+		}
+	case *WorkflowEvent:
+		metric.Event = string(e.Event)
+	}
+	s.metrics.ObserveStreamEvent(ctx, metric)
+}
+
+func (s *streamMetrics) onStreamEnd(ctx context.Context, err error) {}
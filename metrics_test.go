@@ -0,0 +1,102 @@
+package coze
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockMetrics struct {
+	mu       sync.Mutex
+	requests []*RequestMetric
+	retries  []*RetryMetric
+	events   []*StreamEventMetric
+	usages   []*ChatUsageMetric
+}
+
+func (m *mockMetrics) ObserveRequest(ctx context.Context, metric *RequestMetric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, metric)
+}
+
+func (m *mockMetrics) ObserveRetry(ctx context.Context, metric *RetryMetric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries = append(m.retries, metric)
+}
+
+func (m *mockMetrics) ObserveStreamEvent(ctx context.Context, metric *StreamEventMetric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, metric)
+}
+
+func (m *mockMetrics) ObserveChatUsage(ctx context.Context, metric *ChatUsageMetric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usages = append(m.usages, metric)
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("request and retry", func(t *testing.T) {
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			statusResp(http.StatusTooManyRequests, `{"error_code":"rate_limit"}`, nil),
+			statusResp(http.StatusOK, `{"code":4100,"msg":"not found"}`, nil),
+		}}
+		metrics := &mockMetrics{}
+		core := newCore(&clientOption{baseURL: "https://api.test.com", client: mock, metrics: metrics, retryPolicy: fastRetryPolicy(1)})
+
+		var resp TestResponse
+		require.Error(t, core.Request(ctx, http.MethodPost, "/v1/bot/get_online_info", nil, &resp))
+		require.Len(t, metrics.retries, 1)
+		assert.Equal(t, http.StatusTooManyRequests, metrics.retries[0].StatusCode)
+		assert.Equal(t, 1, metrics.retries[0].Attempt)
+		require.Len(t, metrics.requests, 1)
+		assert.Equal(t, "/v1/bot/get_online_info", metrics.requests[0].Endpoint)
+		assert.Equal(t, http.StatusOK, metrics.requests[0].StatusCode)
+		assert.Equal(t, "4100", metrics.requests[0].ErrorCode)
+		assert.Error(t, metrics.requests[0].Err)
+	})
+
+	t.Run("stream events and chat usage", func(t *testing.T) {
+		body := "event:conversation.chat.created\ndata:{\"id\":\"chat1\"}\n\n" +
+			"event:conversation.chat.completed\ndata:{\"id\":\"chat1\",\"bot_id\":\"bot1\",\"status\":\"completed\",\"usage\":{\"token_count\":30,\"input_count\":10,\"output_count\":20}}\n\n" +
+			"event:done\ndata:\"[DONE]\"\n\n"
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+					Body:       io.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}}
+		metrics := &mockMetrics{}
+		chats := newChats(newCore(&clientOption{baseURL: "https://api.test.com", client: mock, metrics: metrics}))
+
+		stream, err := chats.Stream(ctx, &CreateChatsReq{BotID: "bot1"})
+		require.NoError(t, err)
+		defer stream.Close()
+		for {
+			if _, err := stream.Recv(); err != nil {
+				break
+			}
+		}
+
+		require.Len(t, metrics.events, 3)
+		assert.Equal(t, "conversation.chat.created", metrics.events[0].Event)
+		assert.Equal(t, 0, metrics.events[0].Index)
+		assert.Equal(t, 2, metrics.events[2].Index)
+		require.Len(t, metrics.usages, 1)
+		assert.Equal(t, &ChatUsageMetric{BotID: "bot1", InputTokens: 10, OutputTokens: 20, TotalTokens: 30}, metrics.usages[0])
+	})
+}
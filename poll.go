@@ -0,0 +1,78 @@
+package coze
+
+import (
+	"context"
+	"time"
+)
+
+// chatPollInterval is the default initial interval of the polling of a chat.
+var chatPollInterval = time.Second
+
+// PollOption configures the polling of an asynchronous call, such as Chat.CreateAndPoll.
+type PollOption func(*pollOption)
+
+type pollOption struct {
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	multiplier      float64
+}
+
+func newPollOption(opts []PollOption) *pollOption {
+	opt := &pollOption{
+		initialInterval: chatPollInterval,
+		maxInterval:     5 * chatPollInterval,
+		multiplier:      1.5,
+	}
+	for _, option := range opts {
+		option(opt)
+	}
+	// a zero interval would stay zero whatever the backoff, and poll the API in a tight loop
+	if opt.initialInterval <= 0 {
+		opt.initialInterval = chatPollInterval
+	}
+	if opt.maxInterval <= 0 {
+		opt.maxInterval = 5 * chatPollInterval
+	}
+	if opt.maxInterval < opt.initialInterval {
+		opt.maxInterval = opt.initialInterval
+	}
+	if opt.multiplier < 1 {
+		opt.multiplier = 1
+	}
+	return opt
+}
+
+// WithPollInterval sets the interval before the first poll, and the maximum interval it grows to,
+// 1 and 5 seconds by default. A non-positive interval is replaced by its default.
+func WithPollInterval(initial, max time.Duration) PollOption {
+	return func(o *pollOption) {
+		o.initialInterval = initial
+		o.maxInterval = max
+	}
+}
+
+// WithPollBackoff sets the factor the interval grows by after every poll, 1.5 by default. A
+// factor of 1 polls at a fixed interval.
+func WithPollBackoff(multiplier float64) PollOption {
+	return func(o *pollOption) {
+		o.multiplier = multiplier
+	}
+}
+
+// poll calls check after every interval until it is done, or ctx is done.
+func poll[T any](ctx context.Context, opt *pollOption, check func(ctx context.Context) (*T, bool, error)) (*T, error) {
+	interval := opt.initialInterval
+	for {
+		if err := sleepContext(ctx, interval); err != nil {
+			return nil, err
+		}
+		result, done, err := check(ctx)
+		if err != nil || done {
+			return result, err
+		}
+		interval = time.Duration(float64(interval) * opt.multiplier)
+		if interval > opt.maxInterval {
+			interval = opt.maxInterval
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package coze
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoll(t *testing.T) {
+	t.Run("backoff", func(t *testing.T) {
+		opt := newPollOption([]PollOption{WithPollInterval(10*time.Millisecond, 40*time.Millisecond), WithPollBackoff(2)})
+		var times []time.Time
+		start := time.Now()
+		result, err := poll(context.Background(), opt, func(ctx context.Context) (*int, bool, error) {
+			times = append(times, time.Now())
+			n := len(times)
+			return &n, n == 4, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 4, *result)
+		// 10, 20, 40 and 40 milliseconds
+		assert.GreaterOrEqual(t, times[3].Sub(start), 110*time.Millisecond)
+		assert.GreaterOrEqual(t, times[3].Sub(times[2]), 40*time.Millisecond)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		opt := newPollOption([]PollOption{WithPollInterval(time.Millisecond, time.Millisecond)})
+		_, err := poll(context.Background(), opt, func(ctx context.Context) (*int, bool, error) {
+			return nil, false, errors.New("test error")
+		})
+		assert.EqualError(t, err, "test error")
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := poll(ctx, newPollOption(nil), func(ctx context.Context) (*int, bool, error) {
+			t.Fatal("unexpected check")
+			return nil, false, nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		opt := newPollOption([]PollOption{WithPollInterval(time.Second, time.Millisecond), WithPollBackoff(0.5)})
+		assert.Equal(t, time.Second, opt.maxInterval)
+		assert.Equal(t, float64(1), opt.multiplier)
+
+		opt = newPollOption([]PollOption{WithPollInterval(0, -time.Second)})
+		assert.Equal(t, chatPollInterval, opt.initialInterval)
+		assert.Equal(t, 5*chatPollInterval, opt.maxInterval)
+
+		opt = newPollOption([]PollOption{WithPollInterval(-time.Second, 0)})
+		assert.Equal(t, chatPollInterval, opt.initialInterval)
+		assert.Equal(t, 5*chatPollInterval, opt.maxInterval)
+	})
+
+	t.Run("zero interval does not poll in a tight loop", func(t *testing.T) {
+		defer func(interval time.Duration) { chatPollInterval = interval }(chatPollInterval)
+		chatPollInterval = 10 * time.Millisecond
+
+		opt := newPollOption([]PollOption{WithPollInterval(0, 0)})
+		ctx, cancel := context.WithTimeout(context.Background(), 55*time.Millisecond)
+		defer cancel()
+		checks := 0
+		_, err := poll(ctx, opt, func(ctx context.Context) (*int, bool, error) {
+			checks++
+			return nil, false, nil
+		})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.LessOrEqual(t, checks, 5)
+	})
+}
+
+func TestChatCreateAndPollStatus(t *testing.T) {
+	fast := WithPollInterval(time.Millisecond, time.Millisecond)
+	newTestChats := func(status ChatStatus, canceled *int32) *chat {
+		return newChats(newCore(&clientOption{baseURL: ComBaseURL, client: &http.Client{Transport: &mockTransport{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				chat := Chat{ID: "chat1", ConversationID: "conv1", Status: ChatStatusInProgress}
+				switch req.URL.Path {
+				case "/v3/chat":
+					return mockResponse(http.StatusOK, &createChatsResp{Chat: &CreateChatsResp{Chat: chat}})
+				case "/v3/chat/retrieve":
+					chat.Status = status
+					if status == ChatStatusFailed {
+						chat.LastError = &ChatError{Code: 4000, Msg: "bot failed"}
+					}
+					if status == ChatStatusRequiresAction {
+						chat = requiresActionChat(toolCall("call1", "get_weather", "{}"))
+					}
+					return mockResponse(http.StatusOK, &retrieveChatsResp{Chat: &RetrieveChatsResp{Chat: chat}})
+				case "/v3/chat/cancel":
+					atomic.AddInt32(canceled, 1)
+					chat.Status = ChatStatusCancelled
+					return mockResponse(http.StatusOK, &cancelChatsResp{Chat: &CancelChatsResp{Chat: chat}})
+				case "/v3/chat/message/list":
+					return mockResponse(http.StatusOK, &listChatsMessagesResp{ListChatsMessagesResp: &ListChatsMessagesResp{}})
+				}
+				return nil, nil
+			},
+		}}}))
+	}
+
+	t.Run("failed", func(t *testing.T) {
+		var canceled int32
+		_, err := newTestChats(ChatStatusFailed, &canceled).CreateAndPoll(context.Background(), &CreateChatsReq{BotID: "bot1"}, nil, fast)
+		failedErr, ok := AsChatFailedError(err)
+		require.True(t, ok)
+		assert.Equal(t, 4000, failedErr.Chat.LastError.Code)
+		assert.Equal(t, "chat chat1 failed: code=4000, message=bot failed", err.Error())
+		assert.Equal(t, int32(0), canceled)
+	})
+
+	t.Run("requires action", func(t *testing.T) {
+		var canceled int32
+		resp, err := newTestChats(ChatStatusRequiresAction, &canceled).CreateAndPoll(context.Background(), &CreateChatsReq{BotID: "bot1"}, nil, fast)
+		require.NoError(t, err)
+		assert.Equal(t, ChatStatusRequiresAction, resp.Chat.Status)
+		assert.Equal(t, "call1", resp.Chat.RequiredAction.SubmitToolOutputs.ToolCalls[0].ID)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		var canceled int32
+		resp, err := newTestChats(ChatStatusCancelled, &canceled).CreateAndPoll(context.Background(), &CreateChatsReq{BotID: "bot1"}, nil, fast)
+		require.NoError(t, err)
+		assert.Equal(t, ChatStatusCancelled, resp.Chat.Status)
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		var canceled int32
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := newTestChats(ChatStatusInProgress, &canceled).CreateAndPoll(ctx, &CreateChatsReq{BotID: "bot1"}, nil, fast)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		// the chat is canceled server-side
+		assert.Equal(t, int32(1), atomic.LoadInt32(&canceled))
+	})
+}
@@ -0,0 +1,271 @@
+// Package prommetrics implements coze.Metrics and exposes the collected metrics in the
+// Prometheus text exposition format, without depending on the Prometheus client library.
+//
+//	metrics := prommetrics.New()
+//	cozeCli := coze.NewCozeAPI(auth, coze.WithMetrics(metrics))
+//	http.Handle("/metrics", metrics)
+package prommetrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// DefaultBuckets are the histogram buckets, in seconds, used for latencies.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Option configures the Metrics.
+type Option func(*Metrics)
+
+// WithNamespace sets the prefix of every metric name. Defaults to "coze".
+func WithNamespace(namespace string) Option {
+	return func(m *Metrics) {
+		m.namespace = namespace
+	}
+}
+
+// WithBuckets sets the histogram buckets, in seconds.
+func WithBuckets(buckets []float64) Option {
+	return func(m *Metrics) {
+		m.buckets = append([]float64(nil), buckets...)
+		sort.Float64s(m.buckets)
+	}
+}
+
+// WithEndpointNormalizer sets the function used to turn a request path into the endpoint label.
+// The default replaces numeric path segments with ":id" to keep the label cardinality bounded.
+func WithEndpointNormalizer(normalize func(path string) string) Option {
+	return func(m *Metrics) {
+		m.normalize = normalize
+	}
+}
+
+// Metrics collects the observations of a coze client. It implements coze.Metrics and
+// http.Handler, serving the metrics in the Prometheus text format.
+type Metrics struct {
+	namespace string
+	buckets   []float64
+	normalize func(path string) string
+
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+var _ coze.Metrics = &Metrics{}
+
+// New creates an empty Metrics.
+func New(opts ...Option) *Metrics {
+	m := &Metrics{
+		namespace: "coze",
+		buckets:   DefaultBuckets,
+		normalize: NormalizeEndpoint,
+		families:  map[string]*family{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+var idSegment = regexp.MustCompile(`/[0-9]+(/|$)`)
+
+// NormalizeEndpoint replaces the numeric segments of path with ":id".
+func NormalizeEndpoint(path string) string {
+	for idSegment.MatchString(path) {
+		path = idSegment.ReplaceAllString(path, "/:id$1")
+	}
+	return path
+}
+
+func (m *Metrics) ObserveRequest(ctx context.Context, metric *coze.RequestMetric) {
+	endpoint := m.normalize(metric.Endpoint)
+	status := ""
+	if metric.StatusCode > 0 {
+		status = strconv.Itoa(metric.StatusCode)
+	}
+	m.counter("requests_total", "Total number of Coze API calls.",
+		labels{"endpoint", endpoint, "method", metric.Method, "status", status, "code", metric.ErrorCode}, 1)
+	if metric.Err != nil {
+		m.counter("request_errors_total", "Total number of failed Coze API calls.",
+			labels{"endpoint", endpoint, "method", metric.Method, "code", metric.ErrorCode}, 1)
+	}
+	m.histogram("request_duration_seconds", "Latency of Coze API calls until the response headers are received.",
+		labels{"endpoint", endpoint, "method", metric.Method}, metric.Latency)
+}
+
+func (m *Metrics) ObserveRetry(ctx context.Context, metric *coze.RetryMetric) {
+	status := ""
+	if metric.StatusCode > 0 {
+		status = strconv.Itoa(metric.StatusCode)
+	}
+	m.counter("retries_total", "Total number of retried Coze API calls.",
+		labels{"endpoint", m.normalize(metric.Endpoint), "method", metric.Method, "status", status}, 1)
+}
+
+func (m *Metrics) ObserveStreamEvent(ctx context.Context, metric *coze.StreamEventMetric) {
+	endpoint := m.normalize(metric.Endpoint)
+	m.counter("stream_events_total", "Total number of events received from Coze streams.",
+		labels{"endpoint", endpoint, "event", metric.Event}, 1)
+	if metric.Index == 0 {
+		m.histogram("stream_time_to_first_event_seconds", "Time from the stream request to its first event.",
+			labels{"endpoint", endpoint}, metric.SinceStart)
+		return
+	}
+	m.histogram("stream_event_gap_seconds", "Time between two consecutive events of a stream.",
+		labels{"endpoint", endpoint}, metric.Gap)
+}
+
+func (m *Metrics) ObserveChatUsage(ctx context.Context, metric *coze.ChatUsageMetric) {
+	const help = "Total number of tokens consumed by completed chats."
+	m.counter("chat_tokens_total", help, labels{"bot_id", metric.BotID, "type", "input"}, float64(metric.InputTokens))
+	m.counter("chat_tokens_total", help, labels{"bot_id", metric.BotID, "type", "output"}, float64(metric.OutputTokens))
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		f := m.families[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, f.help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, f.kind)
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := f.series[key]
+			if f.kind == "counter" {
+				fmt.Fprintf(&b, "%s%s %s\n", name, s.labels.format(), formatFloat(s.value))
+				continue
+			}
+			for i, le := range m.buckets {
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", name, s.labels.with("le", formatFloat(le)).format(), s.buckets[i])
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", name, s.labels.with("le", "+Inf").format(), s.count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", name, s.labels.format(), formatFloat(s.value))
+			fmt.Fprintf(&b, "%s_count%s %d\n", name, s.labels.format(), s.count)
+		}
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// labels is a list of label name and value pairs.
+type labels []string
+
+func (l labels) with(name, value string) labels {
+	return append(append(labels{}, l...), name, value)
+}
+
+func (l labels) format() string {
+	if len(l) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i < len(l); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(l[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(value)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+type family struct {
+	help   string
+	kind   string
+	series map[string]*series
+}
+
+type series struct {
+	labels  labels
+	value   float64 // counter value, or histogram sum
+	count   uint64
+	buckets []uint64
+}
+
+func (m *Metrics) getSeries(name, help, kind string, l labels) *series {
+	name = m.namespace + "_" + name
+	f, ok := m.families[name]
+	if !ok {
+		f = &family{help: help, kind: kind, series: map[string]*series{}}
+		m.families[name] = f
+	}
+	key := l.format()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: l}
+		if kind == "histogram" {
+			s.buckets = make([]uint64, len(m.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (m *Metrics) counter(name, help string, l labels, delta float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.getSeries(name, help, "counter", l).value += delta
+}
+
+func (m *Metrics) histogram(name, help string, l labels, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.getSeries(name, help, "histogram", l)
+	v := d.Seconds()
+	s.value += v
+	s.count++
+	for i, le := range m.buckets {
+		if v <= le {
+			s.buckets[i]++
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package prommetrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/coze-dev/coze-go"
+)
+
+func TestNormalizeEndpoint(t *testing.T) {
+	assert.Equal(t, "/v3/chat", NormalizeEndpoint("/v3/chat"))
+	assert.Equal(t, "/v1/datasets/:id/images/:id", NormalizeEndpoint("/v1/datasets/123/images/456"))
+	assert.Equal(t, "/v1/workflows/:id/run_histories/:id", NormalizeEndpoint("/v1/workflows/1/run_histories/2"))
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	m := New(WithBuckets([]float64{0.1, 1}))
+
+	m.ObserveRequest(ctx, &coze.RequestMetric{Endpoint: "/v3/chat", Method: http.MethodPost, StatusCode: 200, Latency: 50 * time.Millisecond})
+	m.ObserveRequest(ctx, &coze.RequestMetric{
+		Endpoint: "/v1/datasets/123", Method: http.MethodGet, StatusCode: 200, ErrorCode: "4100",
+		Latency: 2 * time.Second, Err: errors.New("failed"),
+	})
+	m.ObserveRetry(ctx, &coze.RetryMetric{Endpoint: "/v3/chat", Method: http.MethodPost, Attempt: 1, StatusCode: 429})
+	m.ObserveStreamEvent(ctx, &coze.StreamEventMetric{Endpoint: "/v3/chat", Event: "conversation.chat.created", SinceStart: 500 * time.Millisecond})
+	m.ObserveStreamEvent(ctx, &coze.StreamEventMetric{Endpoint: "/v3/chat", Event: "conversation.message.delta", Index: 1, Gap: 20 * time.Millisecond})
+	m.ObserveChatUsage(ctx, &coze.ChatUsageMetric{BotID: "bot1", InputTokens: 10, OutputTokens: 20, TotalTokens: 30})
+	m.ObserveChatUsage(ctx, &coze.ChatUsageMetric{BotID: "bot1", InputTokens: 5, OutputTokens: 5, TotalTokens: 10})
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+
+	out := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE coze_requests_total counter",
+		`coze_requests_total{endpoint="/v3/chat",method="POST",status="200",code=""} 1`,
+		`coze_request_errors_total{endpoint="/v1/datasets/:id",method="GET",code="4100"} 1`,
+		"# TYPE coze_request_duration_seconds histogram",
+		`coze_request_duration_seconds_bucket{endpoint="/v3/chat",method="POST",le="0.1"} 1`,
+		`coze_request_duration_seconds_bucket{endpoint="/v1/datasets/:id",method="GET",le="1"} 0`,
+		`coze_request_duration_seconds_bucket{endpoint="/v1/datasets/:id",method="GET",le="+Inf"} 1`,
+		`coze_request_duration_seconds_sum{endpoint="/v1/datasets/:id",method="GET"} 2`,
+		`coze_request_duration_seconds_count{endpoint="/v1/datasets/:id",method="GET"} 1`,
+		`coze_retries_total{endpoint="/v3/chat",method="POST",status="429"} 1`,
+		`coze_stream_time_to_first_event_seconds_count{endpoint="/v3/chat"} 1`,
+		`coze_stream_event_gap_seconds_bucket{endpoint="/v3/chat",le="0.1"} 1`,
+		`coze_stream_events_total{endpoint="/v3/chat",event="conversation.message.delta"} 1`,
+		`coze_chat_tokens_total{bot_id="bot1",type="input"} 15`,
+		`coze_chat_tokens_total{bot_id="bot1",type="output"} 25`,
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	assert.Equal(t, `{a="x\"y\\z\n"}`, labels{"a", "x\"y\\z\n"}.format())
+}
@@ -0,0 +1,286 @@
+package coze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the requests sent by the client. It is called before every attempt,
+// including retries, and is notified about every response so it can adapt to the server.
+type RateLimiter interface {
+	// Wait blocks until a request to path may be sent, or returns an error if ctx is done first.
+	Wait(ctx context.Context, path string) error
+
+	// Observe reports the response received for a request to path. err is the Coze error of its
+	// body, e.g. the code 4013 of a rate limited request answered with 200, or nil.
+	Observe(path string, resp *http.Response, err error)
+}
+
+// RateLimit configures a token bucket.
+type RateLimit struct {
+	// Rate is the number of requests allowed per second.
+	Rate float64
+
+	// Burst is the maximum number of requests that can be sent at once. Defaults to 1.
+	Burst int
+}
+
+// RateLimitConfig configures the limiter created by NewRateLimiter.
+type RateLimitConfig struct {
+	// Global applies to every request sent by the client.
+	Global *RateLimit
+
+	// Paths applies a limit per endpoint, keyed by path prefix such as "/v3/chat" or
+	// "/v1/workflow/run". A request uses the limit with the longest matching prefix.
+	Paths map[string]*RateLimit
+
+	// MinRateRatio is the lowest fraction of the configured rate the limiter slows down to when
+	// the server keeps answering 429. Defaults to 0.1.
+	MinRateRatio float64
+
+	// DefaultPause is how long requests to a bucket are paused after a 429 without Retry-After.
+	// Defaults to 1s.
+	DefaultPause time.Duration
+}
+
+var _ RateLimiter = &rateLimiter{}
+
+// NewRateLimiter creates a token bucket RateLimiter with a global and per path limit.
+//
+// The limiter adapts to the server: when a request is answered with 429 Too Many Requests or the
+// Coze code 4013, the rate of the matching bucket is halved and requests to that bucket are paused until Retry-After.
+// Every successful response slowly restores the rate towards the configured value.
+func NewRateLimiter(config *RateLimitConfig) RateLimiter {
+	if config == nil {
+		config = &RateLimitConfig{}
+	}
+	minRatio := config.MinRateRatio
+	if minRatio <= 0 || minRatio > 1 {
+		minRatio = 0.1
+	}
+	defaultPause := config.DefaultPause
+	if defaultPause <= 0 {
+		defaultPause = time.Second
+	}
+	limiter := &rateLimiter{
+		paths:        make(map[string]*tokenBucket, len(config.Paths)),
+		pauses:       make(map[string]time.Time),
+		minRatio:     minRatio,
+		defaultPause: defaultPause,
+	}
+	if config.Global != nil && config.Global.Rate > 0 {
+		limiter.global = newTokenBucket(config.Global)
+	}
+	for path, limit := range config.Paths {
+		if limit != nil && limit.Rate > 0 {
+			limiter.paths[path] = newTokenBucket(limit)
+		}
+	}
+	return limiter
+}
+
+type rateLimiter struct {
+	global       *tokenBucket
+	paths        map[string]*tokenBucket
+	minRatio     float64
+	defaultPause time.Duration
+
+	mu     sync.Mutex
+	pauses map[string]time.Time // bucket prefix, or "" for the global bucket -> paused until
+}
+
+func (r *rateLimiter) Wait(ctx context.Context, path string) error {
+	now := time.Now()
+	wait := r.pauseRemaining(path, now)
+
+	buckets := make([]*tokenBucket, 0, 2)
+	if r.global != nil {
+		buckets = append(buckets, r.global)
+	}
+	if bucket := r.pathBucket(path); bucket != nil {
+		buckets = append(buckets, bucket)
+	}
+	for _, bucket := range buckets {
+		if d := bucket.reserve(now); d > wait {
+			wait = d
+		}
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	cancel := func() {
+		for _, bucket := range buckets {
+			bucket.cancel()
+		}
+	}
+	if exceedsDeadline(ctx, wait) {
+		cancel()
+		return fmt.Errorf("rate limit: waiting %v for %s would exceed context deadline: %w", wait, path, context.DeadlineExceeded)
+	}
+	if err := sleepContext(ctx, wait); err != nil {
+		cancel()
+		return err
+	}
+	return nil
+}
+
+func (r *rateLimiter) Observe(path string, resp *http.Response, err error) {
+	if resp == nil {
+		return
+	}
+	key, bucket := r.match(path)
+	if bucket == nil {
+		bucket = r.global
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests && !errors.Is(err, ErrRateLimited) {
+		if bucket != nil {
+			bucket.speedUp()
+		}
+		return
+	}
+
+	pause, ok := parseRetryAfter(resp)
+	if !ok {
+		pause = r.defaultPause
+	}
+	r.mu.Lock()
+	now := time.Now()
+	for k, until := range r.pauses {
+		if !until.After(now) {
+			delete(r.pauses, k)
+		}
+	}
+	until := now.Add(pause)
+	if until.After(r.pauses[key]) {
+		r.pauses[key] = until
+	}
+	r.mu.Unlock()
+	if bucket != nil {
+		bucket.slowDown(r.minRatio)
+	}
+}
+
+// pauseRemaining returns how long requests to path are paused. A pause applies to every path
+// of the bucket it was observed on.
+func (r *rateLimiter) pauseRemaining(path string, now time.Time) time.Duration {
+	key, _ := r.match(path)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	until, ok := r.pauses[key]
+	if !ok {
+		return 0
+	}
+	if !until.After(now) {
+		delete(r.pauses, key)
+		return 0
+	}
+	return until.Sub(now)
+}
+
+// pathBucket returns the bucket with the longest prefix matching path.
+func (r *rateLimiter) pathBucket(path string) *tokenBucket {
+	_, bucket := r.match(path)
+	return bucket
+}
+
+// match returns the longest prefix matching path and its bucket, or "" and nil if none matches.
+func (r *rateLimiter) match(path string) (string, *tokenBucket) {
+	var (
+		matched string
+		bucket  *tokenBucket
+	)
+	for prefix, b := range r.paths {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(matched) {
+			matched, bucket = prefix, b
+		}
+	}
+	return matched, bucket
+}
+
+// tokenBucket is a token bucket whose rate can be lowered and restored at runtime.
+type tokenBucket struct {
+	mu     sync.Mutex
+	limit  float64 // configured rate
+	rate   float64 // current rate
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit *RateLimit) *tokenBucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		limit:  limit.Rate,
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) advance(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+// reserve takes a token and returns how long the caller must wait before using it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel gives back a token taken by reserve.
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// slowDown halves the current rate, down to minRatio of the configured rate.
+func (b *tokenBucket) slowDown(minRatio float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	b.rate /= 2
+	if floor := b.limit * minRatio; b.rate < floor {
+		b.rate = floor
+	}
+}
+
+// speedUp increases the current rate by 5% of the configured rate, up to the configured rate.
+func (b *tokenBucket) speedUp() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate >= b.limit {
+		return
+	}
+	b.advance(time.Now())
+	b.rate += b.limit * 0.05
+	if b.rate > b.limit {
+		b.rate = b.limit
+	}
+}
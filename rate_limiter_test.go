@@ -0,0 +1,126 @@
+package coze
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("burst then wait", func(t *testing.T) {
+		limiter := NewRateLimiter(&RateLimitConfig{Global: &RateLimit{Rate: 20, Burst: 2}})
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			require.NoError(t, limiter.Wait(ctx, "/v3/chat"))
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	})
+
+	t.Run("per path limit uses longest prefix", func(t *testing.T) {
+		limiter := NewRateLimiter(&RateLimitConfig{Paths: map[string]*RateLimit{
+			"/v3/chat":        {Rate: 1000, Burst: 10},
+			"/v3/chat/cancel": {Rate: 1, Burst: 1},
+		}}).(*rateLimiter)
+		assert.Equal(t, float64(1), limiter.pathBucket("/v3/chat/cancel").limit)
+		assert.Equal(t, float64(1000), limiter.pathBucket("/v3/chat/retrieve").limit)
+		assert.Nil(t, limiter.pathBucket("/v1/workflow/run"))
+	})
+
+	t.Run("wait is cancelled by context", func(t *testing.T) {
+		limiter := NewRateLimiter(&RateLimitConfig{Global: &RateLimit{Rate: 0.1, Burst: 1}})
+		require.NoError(t, limiter.Wait(ctx, "/v1/workflow/run"))
+
+		cancelCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		assert.Error(t, limiter.Wait(cancelCtx, "/v1/workflow/run"))
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, limiter.Wait(timeoutCtx, "/v1/workflow/run"), context.DeadlineExceeded)
+	})
+
+	t.Run("pauses the matched bucket", func(t *testing.T) {
+		limiter := NewRateLimiter(&RateLimitConfig{
+			Paths:        map[string]*RateLimit{"/v1/workflow": {Rate: 100, Burst: 1}},
+			DefaultPause: time.Second,
+		}).(*rateLimiter)
+		resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: make(http.Header)}
+
+		limiter.Observe("/v1/workflow/run", resp, nil)
+		now := time.Now()
+		assert.Greater(t, limiter.pauseRemaining("/v1/workflow/stream_run", now), 500*time.Millisecond)
+		assert.Zero(t, limiter.pauseRemaining("/v3/chat", now))
+
+		limiter.Observe("/v3/chat", resp, nil)
+		assert.Greater(t, limiter.pauseRemaining("/v1/files/upload", now), 500*time.Millisecond)
+		assert.Len(t, limiter.pauses, 2)
+
+		// expired pauses are removed
+		limiter.mu.Lock()
+		for key := range limiter.pauses {
+			limiter.pauses[key] = now.Add(-time.Second)
+		}
+		limiter.mu.Unlock()
+		limiter.Observe("/v1/workflow/run", resp, nil)
+		assert.Len(t, limiter.pauses, 1)
+	})
+
+	t.Run("adapts to rate limited responses", func(t *testing.T) {
+		limiter := NewRateLimiter(&RateLimitConfig{
+			Paths: map[string]*RateLimit{"/v1/workflow/run": {Rate: 100, Burst: 1}},
+		}).(*rateLimiter)
+		bucket := limiter.pathBucket("/v1/workflow/run")
+
+		resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: make(http.Header)}
+		resp.Header.Set("Retry-After", "1")
+		limiter.Observe("/v1/workflow/run", resp, nil)
+		assert.Equal(t, float64(50), bucket.rate)
+		assert.Greater(t, limiter.pauseRemaining("/v1/workflow/run", time.Now()), 500*time.Millisecond)
+
+		for i := 0; i < 20; i++ {
+			limiter.Observe("/v1/workflow/run", &http.Response{StatusCode: http.StatusOK}, nil)
+		}
+		assert.Equal(t, float64(100), bucket.rate)
+	})
+
+	t.Run("adapts to rate limited codes of 200 responses", func(t *testing.T) {
+		limiter := NewRateLimiter(&RateLimitConfig{
+			Paths:        map[string]*RateLimit{"/v1/workflow/run": {Rate: 100, Burst: 1}},
+			DefaultPause: time.Second,
+		}).(*rateLimiter)
+		bucket := limiter.pathBucket("/v1/workflow/run")
+
+		limiter.Observe("/v1/workflow/run", &http.Response{StatusCode: http.StatusOK, Header: make(http.Header)},
+			NewError(4013, "rate limited", "log-id"))
+		assert.Equal(t, float64(50), bucket.rate)
+		assert.Greater(t, limiter.pauseRemaining("/v1/workflow/run", time.Now()), 500*time.Millisecond)
+
+		limiter.Observe("/v1/workflow/run", &http.Response{StatusCode: http.StatusOK}, NewError(4000, "invalid", "log-id"))
+		assert.Greater(t, bucket.rate, float64(50))
+	})
+
+	t.Run("applied by core before every attempt", func(t *testing.T) {
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			statusResp(http.StatusTooManyRequests, `{"error_code":"rate_limit"}`, map[string]string{"Retry-After": "0"}),
+			statusResp(http.StatusOK, `{"code":0,"msg":""}`, nil),
+		}}
+		limiter := NewRateLimiter(&RateLimitConfig{Global: &RateLimit{Rate: 1000, Burst: 1}}).(*rateLimiter)
+		core := newCore(&clientOption{
+			baseURL:     "https://api.test.com",
+			client:      mock,
+			retryPolicy: fastRetryPolicy(1),
+			rateLimiter: limiter,
+		})
+
+		var resp TestResponse
+		require.NoError(t, core.Request(ctx, http.MethodPost, "/v3/chat", nil, &resp))
+		assert.Len(t, mock.requests, 2)
+		assert.Less(t, limiter.global.rate, float64(1000))
+	})
+}
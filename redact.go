@@ -0,0 +1,158 @@
+package coze
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const redactedValue = "[REDACTED]"
+
+// defaultRedactKeys are the keys whose values are redacted by default: credentials, and the content
+// of the messages.
+var defaultRedactKeys = []string{
+	"access_token",
+	"refresh_token",
+	"authorization",
+	"client_secret",
+	"private_key",
+	"content",
+	"reasoning_content",
+}
+
+// defaultRedactPatterns are the values redacted by default wherever they appear.
+var defaultRedactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/=-]+`),
+}
+
+// defaultRedactor redacts the log lines and errors which are not bound to a client.
+var defaultRedactor = NewRedactor()
+
+// RedactOption configures a Redactor.
+type RedactOption func(*redactOption)
+
+type redactOption struct {
+	keys        []string
+	patterns    []*regexp.Regexp
+	maxBodySize int
+}
+
+// WithRedactKeys adds keys whose values are redacted, in JSON objects, key=value pairs and log
+// fields. Keys are case-insensitive.
+func WithRedactKeys(keys ...string) RedactOption {
+	return func(o *redactOption) {
+		o.keys = append(o.keys, keys...)
+	}
+}
+
+// WithRedactPatterns adds patterns whose matches are redacted.
+func WithRedactPatterns(patterns ...*regexp.Regexp) RedactOption {
+	return func(o *redactOption) {
+		o.patterns = append(o.patterns, patterns...)
+	}
+}
+
+// WithoutDefaultRedactRules removes the default keys and patterns, only the ones added by the
+// other options are redacted.
+func WithoutDefaultRedactRules() RedactOption {
+	return func(o *redactOption) {
+		o.keys = nil
+		o.patterns = nil
+	}
+}
+
+// WithMaxLogBodySize caps the size of the request and response bodies written to the logs, zero
+// means no limit.
+func WithMaxLogBodySize(size int) RedactOption {
+	return func(o *redactOption) {
+		o.maxBodySize = size
+	}
+}
+
+// Redactor removes sensitive data from the log lines and error strings of a client. By default it
+// redacts the access and refresh tokens, the Authorization header, client secrets, private keys
+// and the content of the messages.
+type Redactor struct {
+	keys        map[string]bool
+	patterns    []*regexp.Regexp
+	maxBodySize int
+
+	// jsonPair matches "key": "value" and pair matches key=value, for the redacted keys.
+	jsonPair *regexp.Regexp
+	pair     *regexp.Regexp
+}
+
+// NewRedactor creates a Redactor with the default rules and the given options.
+func NewRedactor(opts ...RedactOption) *Redactor {
+	opt := &redactOption{
+		keys:     append([]string(nil), defaultRedactKeys...),
+		patterns: append([]*regexp.Regexp(nil), defaultRedactPatterns...),
+	}
+	for _, option := range opts {
+		option(opt)
+	}
+
+	r := &Redactor{keys: map[string]bool{}, patterns: opt.patterns, maxBodySize: opt.maxBodySize}
+	quoted := make([]string, 0, len(opt.keys))
+	for _, key := range opt.keys {
+		r.keys[strings.ToLower(key)] = true
+		quoted = append(quoted, regexp.QuoteMeta(key))
+	}
+	if len(quoted) > 0 {
+		keys := strings.Join(quoted, "|")
+		r.jsonPair = regexp.MustCompile(`("(?i:` + keys + `)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+		r.pair = regexp.MustCompile(`\b((?i:` + keys + `)=)[^&\s"]+`)
+	}
+	return r
+}
+
+// Redact returns s with the sensitive data replaced by [REDACTED].
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		r = defaultRedactor
+	}
+	if r.jsonPair != nil {
+		s = r.jsonPair.ReplaceAllString(s, `$1"`+redactedValue+`"`)
+		s = r.pair.ReplaceAllString(s, "${1}"+redactedValue)
+	}
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllString(s, redactedValue)
+	}
+	return s
+}
+
+// redactField returns the value of a log field, redacted.
+func (r *Redactor) redactField(field LogField) LogField {
+	if r == nil {
+		r = defaultRedactor
+	}
+	if r.keys[strings.ToLower(field.Key)] {
+		return LogField{Key: field.Key, Value: redactedValue}
+	}
+	switch value := field.Value.(type) {
+	case string:
+		return LogField{Key: field.Key, Value: r.Redact(value)}
+	case error:
+		return LogField{Key: field.Key, Value: r.Redact(value.Error())}
+	}
+	return field
+}
+
+// body returns a body to be logged, redacted and capped to the maximum body size.
+func (r *Redactor) body(body []byte) string {
+	if r == nil {
+		r = defaultRedactor
+	}
+	s := r.Redact(string(body))
+	if r.maxBodySize > 0 && len(s) > r.maxBodySize {
+		// cut at the start of a rune, not in the middle of a multi-byte character
+		cut := r.maxBodySize
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		return fmt.Sprintf("%s...(%d bytes truncated)", s[:cut], len(s)-cut)
+	}
+	return s
+}
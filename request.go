@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -20,6 +19,7 @@ type HTTPClient interface {
 
 type core struct {
 	*clientOption
+	log *levelLogger
 }
 
 func newCore(opt *clientOption) *core {
@@ -30,6 +30,7 @@ func newCore(opt *clientOption) *core {
 	}
 	return &core{
 		clientOption: opt,
+		log:          newClientLogger(opt.logger, opt.logLevel, opt.redactor),
 	}
 }
 
@@ -56,117 +57,173 @@ func withHTTPQuery(key, value string) RequestOption {
 
 // Request send http request
 func (c *core) Request(ctx context.Context, method, path string, body any, instance any, opts ...RequestOption) error {
-	resp, err := c.RawRequest(ctx, method, path, body, opts...)
-	if err != nil {
-		return err
-	}
-
-	return packInstance(ctx, instance, resp)
+	call := &Call{Kind: CallKindRequest, Method: method, Path: path, Body: body, Instance: instance, Options: opts}
+	return c.invoke(ctx, call, func(ctx context.Context, call *Call) error {
+		var err error
+		if call.HTTPResponse, err = c.rawRequest(ctx, call.Method, call.Path, call.Body, call.Options...); err != nil {
+			return err
+		}
+		return packInstance(ctx, call.Instance, call.HTTPResponse)
+	})
 }
 
 // UploadFile 上传文件
 func (c *core) UploadFile(ctx context.Context, path string, reader io.Reader, fileName string, fields map[string]string, instance any, opts ...RequestOption) error {
+	call := &Call{
+		Kind:     CallKindUploadFile,
+		Method:   http.MethodPost,
+		Path:     path,
+		File:     reader,
+		FileName: fileName,
+		Fields:   fields,
+		Instance: instance,
+		Options:  opts,
+	}
+	return c.invoke(ctx, call, func(ctx context.Context, call *Call) error {
+		var err error
+		if call.HTTPResponse, err = c.uploadFile(ctx, call.Path, call.File, call.FileName, call.Fields, call.Options...); err != nil {
+			return err
+		}
+		return packInstance(ctx, call.Instance, call.HTTPResponse)
+	})
+}
+
+func (c *core) uploadFile(ctx context.Context, path string, reader io.Reader, fileName string, fields map[string]string, opts ...RequestOption) (*http.Response, error) {
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 
 	part, err := writer.CreateFormFile("file", fileName)
 	if err != nil {
-		return fmt.Errorf("create form file: %w", err)
+		return nil, fmt.Errorf("create form file: %w", err)
 	}
 
 	if _, err = io.Copy(part, reader); err != nil {
-		return fmt.Errorf("copy file content: %w", err)
+		return nil, fmt.Errorf("copy file content: %w", err)
 	}
 
 	// 添加其他字段
 	for key, value := range fields {
 		if err := writer.WriteField(key, value); err != nil {
-			return fmt.Errorf("write field %s: %w", key, err)
+			return nil, fmt.Errorf("write field %s: %w", key, err)
 		}
 	}
 
 	if err := writer.Close(); err != nil {
-		return fmt.Errorf("close multipart writer: %w", err)
+		return nil, fmt.Errorf("close multipart writer: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s%s", c.baseURL, path), body)
-	if err != nil {
-		return fmt.Errorf("create request: %w", err)
-	}
+	contentType := writer.FormDataContentType()
+	data := body.Bytes()
+	resp, err := c.doWithRetry(ctx, http.MethodPost, path, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s%s", c.baseURL, path), bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("Content-Type", contentType)
 
-	// 应用请求选项
-	for _, opt := range opts {
-		if err := opt(req); err != nil {
-			return fmt.Errorf("apply option: %w", err)
+		// 应用请求选项
+		for _, opt := range opts {
+			if err := opt(req); err != nil {
+				return nil, fmt.Errorf("apply option: %w", err)
+			}
 		}
-	}
 
-	if err := c.setCommonHeaders(req); err != nil {
-		return err
+		if err := c.setCommonHeaders(req); err != nil {
+			return nil, err
+		}
+		c.traceRequest(req, nil)
+		return req, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
 	}
+	return resp, nil
+}
 
-	resp, err := c.client.Do(req)
+func (c *core) RawRequest(ctx context.Context, method, path string, body any, opts ...RequestOption) (*http.Response, error) {
+	call := &Call{Kind: CallKindRawRequest, Method: method, Path: path, Body: body, Options: opts}
+	err := c.invoke(ctx, call, func(ctx context.Context, call *Call) error {
+		var err error
+		call.HTTPResponse, err = c.rawRequest(ctx, call.Method, call.Path, call.Body, call.Options...)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("do request: %w", err)
+		return nil, err
 	}
-
-	return packInstance(ctx, instance, resp)
+	return call.HTTPResponse, nil
 }
 
-func (c *core) RawRequest(ctx context.Context, method, path string, body any, opts ...RequestOption) (*http.Response, error) {
+// rawRequest sends the request without interceptors. The response is returned along with the
+// error when the server answered with a non 200 status.
+func (c *core) rawRequest(ctx context.Context, method, path string, body any, opts ...RequestOption) (*http.Response, error) {
 	urlInfo := fmt.Sprintf("%s%s", c.baseURL, path)
 
-	var bodyReader io.Reader
+	var data []byte
 	if body != nil {
-		data, err := json.Marshal(body)
+		var err error
+		data, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("marshal request body: %w", err)
 		}
-		bodyReader = bytes.NewReader(data)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, urlInfo, bodyReader)
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
-	}
+	resp, err := c.doWithRetry(ctx, method, path, func() (*http.Request, error) {
+		// the body is re-buffered for every attempt so that it can be resent on retry
+		var bodyReader io.Reader
+		if data != nil {
+			bodyReader = bytes.NewReader(data)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, urlInfo, bodyReader)
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
 
-	// 设置默认请求头
-	req.Header.Set("Content-Type", "application/json")
+		// 设置默认请求头
+		req.Header.Set("Content-Type", "application/json")
 
-	// 应用请求选项
-	for _, opt := range opts {
-		if err := opt(req); err != nil {
-			return nil, fmt.Errorf("apply option: %w", err)
+		// 应用请求选项
+		for _, opt := range opts {
+			if err := opt(req); err != nil {
+				return nil, fmt.Errorf("apply option: %w", err)
+			}
 		}
-	}
 
-	if err := c.setCommonHeaders(req); err != nil {
-		return nil, err
-	}
-
-	resp, err := c.client.Do(req)
+		if err := c.setCommonHeaders(req); err != nil {
+			return nil, err
+		}
+		c.traceRequest(req, data)
+		return req, nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	if err = checkHttpResp(ctx, resp); err != nil {
-		return nil, err
+		return resp, err
 	}
 	return resp, nil
 }
 
+// StreamRequest sends a streaming request. Retries only happen before the response headers are
+// received, an interrupted stream is never resent.
 func (c *core) StreamRequest(ctx context.Context, method, path string, body any, opts ...RequestOption) (*http.Response, error) {
-	resp, err := c.RawRequest(ctx, method, path, body, opts...)
+	call := &Call{Kind: CallKindStreamRequest, Method: method, Path: path, Body: body, Options: opts}
+	err := c.invoke(ctx, call, func(ctx context.Context, call *Call) error {
+		var err error
+		if call.HTTPResponse, err = c.rawRequest(ctx, call.Method, call.Path, call.Body, call.Options...); err != nil {
+			return err
+		}
+		contentType := call.HTTPResponse.Header.Get("Content-Type")
+		if contentType != "" && strings.Contains(contentType, "application/json") {
+			return packInstance(ctx, &baseResponse{}, call.HTTPResponse)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	contentType := resp.Header.Get("Content-Type")
-	if contentType != "" && strings.Contains(contentType, "application/json") {
-		return nil, packInstance(ctx, &baseResponse{}, resp)
-	}
-	return resp, nil
+	return call.HTTPResponse, nil
 }
 
 func packInstance(ctx context.Context, instance any, resp *http.Response) error {
@@ -181,7 +238,8 @@ func packInstance(ctx context.Context, instance any, resp *http.Response) error
 	httpResponse := newHTTPResponse(resp)
 	err = json.Unmarshal(bodyBytes, instance)
 	if err != nil {
-		logger.Errorf(ctx, fmt.Sprintf("unmarshal response body: %s", string(bodyBytes)))
+		log := loggerFromContext(ctx)
+		log.Errorf(ctx, "unmarshal response body: %s", log.redactor.body(bodyBytes))
 		return err
 	}
 	if baseResp, ok := instance.(baseRespInterface); ok {
@@ -193,8 +251,11 @@ func packInstance(ctx context.Context, instance any, resp *http.Response) error
 func isResponseSuccess(ctx context.Context, baseResp baseRespInterface, bodyBytes []byte, httpResponse *httpResponse) error {
 	baseResp.SetHTTPResponse(httpResponse)
 	if baseResp.GetCode() != 0 {
-		logger.Warnf(ctx, "request failed, body=%s, log_id=%s", string(bodyBytes), httpResponse.LogID())
-		return NewError(baseResp.GetCode(), baseResp.GetMsg(), httpResponse.LogID())
+		log := loggerFromContext(ctx)
+		log.Warnf(ctx, "request failed, body=%s, log_id=%s", log.redactor.body(bodyBytes), httpResponse.LogID())
+		err := NewError(baseResp.GetCode(), baseResp.GetMsg(), httpResponse.LogID())
+		err.parent = &HTTPError{StatusCode: httpResponse.Status, Body: string(bodyBytes), LogID: httpResponse.LogID(), redactor: log.redactor}
+		return err
 	}
 	return nil
 }
@@ -207,13 +268,26 @@ func checkHttpResp(ctx context.Context, resp *http.Response) error {
 		if err != nil {
 			return fmt.Errorf("coze read response body failed: %w, log_id: %s", err, logID)
 		}
-		errorInfo := authErrorFormat{}
+		log := loggerFromContext(ctx)
+		httpErr := &HTTPError{StatusCode: resp.StatusCode, Body: string(bodyBytes), LogID: logID, redactor: log.redactor}
+		errorInfo := struct {
+			authErrorFormat
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		}{}
 		err = json.Unmarshal(bodyBytes, &errorInfo)
 		if err != nil {
-			logger.Errorf(ctx, fmt.Sprintf("unmarshal response body: %s", string(bodyBytes)))
-			return errors.New(string(bodyBytes) + " log_id: " + logID)
+			log.Errorf(ctx, "unmarshal response body: %s", log.redactor.body(bodyBytes))
+			return httpErr
+		}
+		if errorInfo.Code != 0 && errorInfo.ErrorCode == "" {
+			cozeErr := NewError(errorInfo.Code, errorInfo.Msg, logID)
+			cozeErr.parent = httpErr
+			return cozeErr
 		}
-		return NewAuthError(&errorInfo, resp.StatusCode, logID)
+		authErr := NewAuthError(&errorInfo.authErrorFormat, resp.StatusCode, logID)
+		authErr.parent = httpErr
+		return authErr
 	}
 	return nil
 }
@@ -0,0 +1,127 @@
+package coze
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// logRequest logs an attempt of a request at DEBUG, and its body at TRACE.
+func (c *core) logRequest(req *http.Request, attempt int) {
+	if !c.log.enabled(LogLevelDebug) {
+		return
+	}
+	ctx := req.Context()
+	body := requestBody(req)
+	fields := []LogField{
+		{Key: "endpoint", Value: req.URL.Path},
+		{Key: "method", Value: req.Method},
+		{Key: "attempt", Value: attempt},
+	}
+	fields = append(fields, resourceFields(req, body)...)
+	c.log.logFields(ctx, LogLevelDebug, "coze request", fields...)
+	if c.log.enabled(LogLevelTrace) && len(body) > 0 {
+		c.log.logFields(ctx, LogLevelTrace, "coze request body",
+			LogField{Key: "endpoint", Value: req.URL.Path}, LogField{Key: "body", Value: c.log.redactor.body(body)})
+	}
+}
+
+// logResponse logs the response or the error of an attempt of a request at DEBUG, and the body of
+// a JSON or text response at TRACE. Only the size of the other bodies is logged, e.g. of audio.
+func (c *core) logResponse(req *http.Request, attempt int, resp *http.Response, err error, duration time.Duration) {
+	if !c.log.enabled(LogLevelDebug) {
+		return
+	}
+	ctx := req.Context()
+	fields := []LogField{
+		{Key: "endpoint", Value: req.URL.Path},
+		{Key: "method", Value: req.Method},
+		{Key: "attempt", Value: attempt},
+		{Key: "duration", Value: duration},
+	}
+	if err != nil {
+		c.log.logFields(ctx, LogLevelDebug, "coze request failed", append(fields, LogField{Key: "error", Value: err})...)
+		return
+	}
+	logID := resp.Header.Get(httpLogIDKey)
+	fields = append(fields, LogField{Key: "status", Value: resp.StatusCode}, LogField{Key: "log_id", Value: logID})
+	c.log.logFields(ctx, LogLevelDebug, "coze response", fields...)
+
+	contentType := resp.Header.Get("Content-Type")
+	if !c.log.enabled(LogLevelTrace) || strings.Contains(contentType, "text/event-stream") {
+		return
+	}
+	if !isTextContentType(contentType) {
+		c.log.logFields(ctx, LogLevelTrace, "coze response body",
+			LogField{Key: "endpoint", Value: req.URL.Path}, LogField{Key: "log_id", Value: logID},
+			LogField{Key: "content_type", Value: contentType}, LogField{Key: "size", Value: resp.ContentLength})
+		return
+	}
+	// the body is buffered to be logged, and replaced so that it can still be read
+	body, readErr := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if readErr != nil {
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{readErr}))
+	}
+	c.log.logFields(ctx, LogLevelTrace, "coze response body",
+		LogField{Key: "endpoint", Value: req.URL.Path}, LogField{Key: "log_id", Value: logID}, LogField{Key: "body", Value: c.log.redactor.body(body)})
+}
+
+// isTextContentType reports whether a body of the content type is JSON or text, and can be logged.
+func isTextContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasPrefix(mediaType, "text/")
+}
+
+// requestBody returns a copy of the body of a JSON request, nil for the other requests.
+func requestBody(req *http.Request) []byte {
+	if req.GetBody == nil || !strings.Contains(req.Header.Get("Content-Type"), "application/json") {
+		return nil
+	}
+	reader, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+	body, _ := io.ReadAll(reader)
+	return body
+}
+
+// resourceFields returns the bot_id and workflow_id of the request, read from its query or body.
+func resourceFields(req *http.Request, body []byte) []LogField {
+	ids := struct {
+		BotID      string `json:"bot_id"`
+		WorkflowID string `json:"workflow_id"`
+	}{
+		BotID:      req.URL.Query().Get("bot_id"),
+		WorkflowID: req.URL.Query().Get("workflow_id"),
+	}
+	if len(body) > 0 {
+		_ = json.Unmarshal(body, &ids)
+	}
+	var fields []LogField
+	if ids.BotID != "" {
+		fields = append(fields, LogField{Key: "bot_id", Value: ids.BotID})
+	}
+	if ids.WorkflowID != "" {
+		fields = append(fields, LogField{Key: "workflow_id", Value: ids.WorkflowID})
+	}
+	return fields
+}
+
+// errReader returns the read error of a buffered body once its content is read.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
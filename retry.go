@@ -0,0 +1,253 @@
+package coze
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy describes how failed requests are retried.
+//
+// Retries are only attempted for the errors reported by IsRetryable, when it is safe to resend
+// the request:
+//   - 429 Too Many Requests is retried for every method, the server did not process the request.
+//   - 5xx responses and transport errors are only retried for idempotent methods
+//     (GET, HEAD, OPTIONS, PUT, DELETE).
+//
+// Multipart uploads made through UploadFile are buffered in memory and follow the same rules as
+// any other POST request. Streaming requests are only retried before the response headers are
+// received; once a Stream has been returned to the caller no retry is attempted.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of retries after the first attempt. Zero disables retries.
+	MaxRetries int
+
+	// InitialBackoff is the wait before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between two attempts, including waits requested by Retry-After.
+	MaxBackoff time.Duration
+
+	// Multiplier is applied to the backoff after each retry.
+	Multiplier float64
+
+	// Jitter is the fraction (0-1) of the backoff that is randomized to spread out retries.
+	Jitter float64
+}
+
+// DefaultRetryPolicy returns the recommended retry policy: 3 retries, starting at 500ms and
+// doubling up to 8s, with 20% jitter.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries:     3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     8 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// backoff returns the wait before the given retry attempt, starting at 1.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = 500 * time.Millisecond
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	wait := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		wait = wait * (1 - jitter + 2*jitter*rand.Float64())
+	}
+	return time.Duration(wait)
+}
+
+// shouldRetry reports whether the attempt can be retried according to the policy, err being the
+// transport error or the Coze error of the response body. Only rate limited requests are retried
+// whatever their method, as the server did not process them.
+func (p *RetryPolicy) shouldRetry(method string, resp *http.Response, err error) bool {
+	if err == nil {
+		if resp.StatusCode < http.StatusBadRequest {
+			return false
+		}
+		err = &HTTPError{StatusCode: resp.StatusCode}
+	}
+	if !IsRetryable(err) {
+		return false
+	}
+	return errors.Is(err, ErrRateLimited) || isIdempotentMethod(method)
+}
+
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter parses the Retry-After header, which is either a number of seconds or an HTTP date.
+func parseRetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// retryWait returns the wait before the next attempt, honouring Retry-After when present.
+func (p *RetryPolicy) retryWait(attempt int, resp *http.Response) time.Duration {
+	wait := p.backoff(attempt)
+	if retryAfter, ok := parseRetryAfter(resp); ok {
+		wait = retryAfter
+		if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+			wait = p.MaxBackoff
+		}
+	}
+	return wait
+}
+
+// sleepContext waits for d, returning early with the context error if ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// peekResponseError returns the Coze error of a 200 JSON response, whose body is buffered so that
+// it can still be read by the caller.
+func (c *core) peekResponseError(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), "application/json") {
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		// the read error is returned again when the caller reads the body
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err}))
+		return nil
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	envelope := &baseResponse{}
+	if json.Unmarshal(body, envelope) != nil || envelope.GetCode() == 0 {
+		return nil
+	}
+	logID := resp.Header.Get(httpLogIDKey)
+	cozeErr := NewError(envelope.GetCode(), envelope.GetMsg(), logID)
+	cozeErr.parent = &HTTPError{StatusCode: resp.StatusCode, Body: string(body), LogID: logID, redactor: c.log.redactor}
+	return cozeErr
+}
+
+// exceedsDeadline reports whether waiting d would run past the context deadline.
+func exceedsDeadline(ctx context.Context, d time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return ok && time.Now().Add(d).After(deadline)
+}
+
+// doWithRetry sends the request built by newRequest, retrying it according to the retry policy.
+// newRequest is called once per attempt so that the request body can be resent.
+func (c *core) doWithRetry(ctx context.Context, method, path string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	policy := c.retryPolicy
+	for attempt := 0; ; attempt++ {
+		if c.rateLimiter != nil {
+			if err := c.rateLimiter.Wait(ctx, path); err != nil {
+				return nil, err
+			}
+		}
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		c.logRequest(req, attempt+1)
+		resp, err := c.client.Do(req)
+		c.logResponse(req, attempt+1, resp, err, time.Since(start))
+		// the error of a 200 response is only known from its body
+		var bodyErr error
+		if err == nil && (policy != nil || c.rateLimiter != nil) {
+			bodyErr = c.peekResponseError(resp)
+		}
+		if c.rateLimiter != nil && err == nil {
+			c.rateLimiter.Observe(path, resp, bodyErr)
+		}
+		if err == nil && resp.StatusCode == http.StatusUnauthorized {
+			c.invalidateAuth()
+		}
+		if err != nil {
+			err = newRequestError(ctx, err, c.log.redactor)
+		}
+		retryErr := err
+		if retryErr == nil {
+			retryErr = bodyErr
+		}
+		if policy == nil || attempt >= policy.MaxRetries || !policy.shouldRetry(method, resp, retryErr) {
+			return resp, err
+		}
+
+		wait := policy.retryWait(attempt+1, resp)
+		if exceedsDeadline(ctx, wait) {
+			return resp, err
+		}
+		if bodyErr != nil {
+			c.log.Infof(ctx, "request %s %s failed: %v, retry %d/%d after %v",
+				method, req.URL.Path, bodyErr, attempt+1, policy.MaxRetries, wait)
+			_ = resp.Body.Close()
+		} else if resp != nil {
+			c.log.Infof(ctx, "request %s %s got status %d, retry %d/%d after %v, log_id=%s",
+				method, req.URL.Path, resp.StatusCode, attempt+1, policy.MaxRetries, wait, resp.Header.Get(httpLogIDKey))
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		} else {
+			c.log.Infof(ctx, "request %s %s failed: %v, retry %d/%d after %v",
+				method, req.URL.Path, err, attempt+1, policy.MaxRetries, wait)
+		}
+		if c.metrics != nil {
+			metric := &RetryMetric{Endpoint: path, Method: method, Attempt: attempt + 1, Wait: wait}
+			if resp != nil {
+				metric.StatusCode = resp.StatusCode
+			}
+			c.metrics.ObserveRetry(ctx, metric)
+		}
+		if span := spanFromContext(ctx); span != nil {
+			span.AddEvent("retry", map[string]any{"attempt": attempt + 1, "wait_ms": wait.Milliseconds()})
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, newRequestError(ctx, err, c.log.redactor)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package coze
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sequenceHTTP returns the mocked results in order, recording every request it receives
+type sequenceHTTP struct {
+	results  []func(req *http.Request) (*http.Response, error)
+	requests []*http.Request
+	bodies   []string
+}
+
+func (m *sequenceHTTP) Do(req *http.Request) (*http.Response, error) {
+	idx := len(m.requests)
+	m.requests = append(m.requests, req)
+	body := ""
+	if req.Body != nil {
+		data, _ := io.ReadAll(req.Body)
+		body = string(data)
+	}
+	m.bodies = append(m.bodies, body)
+	if idx >= len(m.results) {
+		idx = len(m.results) - 1
+	}
+	return m.results[idx](req)
+}
+
+func statusResp(status int, body string, header map[string]string) func(req *http.Request) (*http.Response, error) {
+	return func(req *http.Request) (*http.Response, error) {
+		resp := &http.Response{
+			StatusCode: status,
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Header:     make(http.Header),
+		}
+		resp.Header.Set(httpLogIDKey, "test-log-id")
+		for k, v := range header {
+			resp.Header.Set(k, v)
+		}
+		return resp, nil
+	}
+}
+
+func fastRetryPolicy(maxRetries int) *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries:     maxRetries,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Multiplier:     2,
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("retry rate limited post and resend body", func(t *testing.T) {
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			statusResp(http.StatusTooManyRequests, `{"error_code":"rate_limit"}`, nil),
+			statusResp(http.StatusOK, `{"code":0,"msg":"","data":{"name":"ok"}}`, nil),
+		}}
+		core := newCore(&clientOption{baseURL: "https://api.test.com", client: mock, retryPolicy: fastRetryPolicy(2)})
+
+		var resp TestResponse
+		err := core.Request(ctx, http.MethodPost, "/test", &TestReq{Test: "test"}, &resp)
+		require.NoError(t, err)
+		assert.Equal(t, "ok", resp.Data.Name)
+		require.Len(t, mock.requests, 2)
+		assert.Equal(t, mock.bodies[0], mock.bodies[1])
+		assert.Contains(t, mock.bodies[1], `"test":"test"`)
+	})
+
+	t.Run("retry rate limited code of a 200 response", func(t *testing.T) {
+		jsonHeader := map[string]string{"Content-Type": "application/json; charset=utf-8"}
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			statusResp(http.StatusOK, `{"code":4013,"msg":"rate limited"}`, jsonHeader),
+			statusResp(http.StatusOK, `{"code":0,"msg":"","data":{"name":"ok"}}`, jsonHeader),
+		}}
+		core := newCore(&clientOption{baseURL: "https://api.test.com", client: mock, retryPolicy: fastRetryPolicy(2)})
+
+		var resp TestResponse
+		err := core.Request(ctx, http.MethodPost, "/test", &TestReq{Test: "test"}, &resp)
+		require.NoError(t, err)
+		assert.Equal(t, "ok", resp.Data.Name)
+		assert.Len(t, mock.requests, 2)
+
+		// the body error is returned once the retries are exhausted
+		mock = &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			statusResp(http.StatusOK, `{"code":4013,"msg":"rate limited"}`, jsonHeader),
+		}}
+		core = newCore(&clientOption{baseURL: "https://api.test.com", client: mock, retryPolicy: fastRetryPolicy(1)})
+		err = core.Request(ctx, http.MethodPost, "/test", nil, &resp)
+		assert.ErrorIs(t, err, ErrRateLimited)
+		assert.Len(t, mock.requests, 2)
+	})
+
+	t.Run("server error code of a 200 response is not retried for post", func(t *testing.T) {
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			statusResp(http.StatusOK, `{"code":5000,"msg":"internal error"}`, map[string]string{"Content-Type": "application/json"}),
+		}}
+		core := newCore(&clientOption{baseURL: "https://api.test.com", client: mock, retryPolicy: fastRetryPolicy(2)})
+
+		var resp TestResponse
+		err := core.Request(ctx, http.MethodPost, "/test", nil, &resp)
+		assert.ErrorIs(t, err, ErrServerError)
+		assert.Len(t, mock.requests, 1)
+	})
+
+	t.Run("server error is not retried for post", func(t *testing.T) {
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			statusResp(http.StatusBadGateway, `{"error_code":"bad_gateway"}`, nil),
+		}}
+		core := newCore(&clientOption{baseURL: "https://api.test.com", client: mock, retryPolicy: fastRetryPolicy(2)})
+
+		var resp TestResponse
+		err := core.Request(ctx, http.MethodPost, "/test", nil, &resp)
+		require.Error(t, err)
+		assert.Len(t, mock.requests, 1)
+	})
+
+	t.Run("transport error is retried for get", func(t *testing.T) {
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			func(req *http.Request) (*http.Response, error) { return nil, errors.New("connection reset") },
+			statusResp(http.StatusServiceUnavailable, `{}`, nil),
+			statusResp(http.StatusOK, `{"code":0,"msg":""}`, nil),
+		}}
+		core := newCore(&clientOption{baseURL: "https://api.test.com", client: mock, retryPolicy: fastRetryPolicy(3)})
+
+		var resp TestResponse
+		err := core.Request(ctx, http.MethodGet, "/test", nil, &resp)
+		require.NoError(t, err)
+		assert.Len(t, mock.requests, 3)
+	})
+
+	t.Run("give up after max retries", func(t *testing.T) {
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			statusResp(http.StatusTooManyRequests, `{"error_code":"rate_limit"}`, nil),
+		}}
+		core := newCore(&clientOption{baseURL: "https://api.test.com", client: mock, retryPolicy: fastRetryPolicy(2)})
+
+		var resp TestResponse
+		err := core.Request(ctx, http.MethodGet, "/test", nil, &resp)
+		require.Error(t, err)
+		assert.Len(t, mock.requests, 3)
+	})
+
+	t.Run("no retry without policy", func(t *testing.T) {
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			statusResp(http.StatusTooManyRequests, `{"error_code":"rate_limit"}`, nil),
+		}}
+		core := newCore(&clientOption{baseURL: "https://api.test.com", client: mock})
+
+		var resp TestResponse
+		err := core.Request(ctx, http.MethodGet, "/test", nil, &resp)
+		require.Error(t, err)
+		assert.Len(t, mock.requests, 1)
+	})
+
+	t.Run("retry after beyond deadline returns last response", func(t *testing.T) {
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			statusResp(http.StatusTooManyRequests, `{"error_code":"rate_limit"}`, map[string]string{"Retry-After": "30"}),
+		}}
+		policy := fastRetryPolicy(2)
+		policy.MaxBackoff = time.Minute
+		core := newCore(&clientOption{baseURL: "https://api.test.com", client: mock, retryPolicy: policy})
+
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		var resp TestResponse
+		err := core.Request(ctx, http.MethodGet, "/test", nil, &resp)
+		authErr, ok := AsAuthError(err)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusTooManyRequests, authErr.HttpCode)
+		assert.Len(t, mock.requests, 1)
+	})
+
+	t.Run("upload is resent on rate limit", func(t *testing.T) {
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			statusResp(http.StatusTooManyRequests, `{"error_code":"rate_limit"}`, nil),
+			statusResp(http.StatusOK, `{"code":0,"msg":""}`, nil),
+		}}
+		core := newCore(&clientOption{baseURL: "https://api.test.com", client: mock, retryPolicy: fastRetryPolicy(1)})
+
+		var resp TestResponse
+		err := core.UploadFile(ctx, "/upload", strings.NewReader("file content"), "test.txt", nil, &resp)
+		require.NoError(t, err)
+		require.Len(t, mock.requests, 2)
+		assert.Contains(t, mock.bodies[1], "file content")
+		assert.Equal(t, mock.bodies[0], mock.bodies[1])
+	})
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 400*time.Millisecond, policy.backoff(3))
+	assert.Equal(t, time.Second, policy.backoff(10))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		wait := policy.backoff(1)
+		assert.GreaterOrEqual(t, wait, 50*time.Millisecond)
+		assert.LessOrEqual(t, wait, 150*time.Millisecond)
+	}
+
+	resp := &http.Response{Header: make(http.Header)}
+	resp.Header.Set("Retry-After", "2")
+	policy.Jitter = 0
+	policy.MaxBackoff = 0
+	assert.Equal(t, 2*time.Second, policy.retryWait(1, resp))
+}
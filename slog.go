@@ -0,0 +1,55 @@
+//go:build go1.21
+
+package coze
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// LevelTrace is the slog level of the TRACE lines, below slog.LevelDebug.
+const LevelTrace = slog.LevelDebug - 4
+
+// NewSlogLogger creates a Logger writing to l, with the structured fields of the SDK as attributes.
+// The lines are filtered by the level of the client before reaching the handler of l, set
+// WithLogLevel(LogLevelTrace) to leave the filtering to the handler.
+//
+//	cozeCli := coze.NewCozeAPI(authCli, coze.WithLogger(coze.NewSlogLogger(slog.Default())))
+func NewSlogLogger(l *slog.Logger) FieldLogger {
+	return &slogLogger{logger: l}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l *slogLogger) Log(ctx context.Context, level LogLevel, message string, args ...interface{}) {
+	if len(args) > 0 {
+		message = fmt.Sprintf(message, args...)
+	}
+	l.logger.Log(ctx, slogLevel(level), message)
+}
+
+func (l *slogLogger) LogFields(ctx context.Context, level LogLevel, message string, fields ...LogField) {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, field := range fields {
+		attrs = append(attrs, slog.Any(field.Key, field.Value))
+	}
+	l.logger.LogAttrs(ctx, slogLevel(level), message, attrs...)
+}
+
+func slogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LogLevelTrace:
+		return LevelTrace
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,68 @@
+//go:build go1.21
+
+package coze
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: LevelTrace})
+
+	core := newCore(&clientOption{
+		baseURL:  ComBaseURL,
+		logger:   NewSlogLogger(slog.New(handler)),
+		logLevel: LogLevelTrace,
+		client: &http.Client{Transport: &mockTransport{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				return mockResponse(http.StatusOK, &createChatsResp{Chat: &CreateChatsResp{Chat: Chat{ID: "chat1"}}})
+			},
+		}},
+	})
+	_, err := newChats(core).Create(context.Background(), &CreateChatsReq{BotID: "bot1", UserID: "user1"})
+	require.NoError(t, err)
+
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		record := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		lines = append(lines, record)
+	}
+	require.Len(t, lines, 4)
+
+	assert.Equal(t, "coze request", lines[0]["msg"])
+	assert.Equal(t, "DEBUG", lines[0]["level"])
+	assert.Equal(t, "/v3/chat", lines[0]["endpoint"])
+	assert.Equal(t, "POST", lines[0]["method"])
+	assert.Equal(t, "bot1", lines[0]["bot_id"])
+	assert.Equal(t, float64(1), lines[0]["attempt"])
+
+	assert.Equal(t, "coze request body", lines[1]["msg"])
+	assert.Equal(t, "DEBUG-4", lines[1]["level"])
+	assert.Contains(t, lines[1]["body"], `"bot_id":"bot1"`)
+
+	assert.Equal(t, "coze response", lines[2]["msg"])
+	assert.Equal(t, float64(http.StatusOK), lines[2]["status"])
+	assert.Equal(t, "test_log_id", lines[2]["log_id"])
+	assert.Contains(t, lines[2], "duration")
+
+	assert.Equal(t, "coze response body", lines[3]["msg"])
+	assert.Contains(t, lines[3]["body"], `"chat1"`)
+}
+
+func TestSlogLoggerLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	logger.Log(context.Background(), LogLevelWarn, "retry %d", 2)
+	assert.Contains(t, buf.String(), `level=WARN msg="retry 2"`)
+}
@@ -0,0 +1,203 @@
+package coze
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSEEvent is an event of a server-sent events stream.
+type SSEEvent struct {
+	// ID is the last event ID of the stream, it is kept by the following events which have no id field.
+	ID    string
+	Event string
+	Data  string
+	// Retry is the reconnection time of the stream, zero if the server never set it.
+	Retry time.Duration
+
+	// raw is the frame the event was decoded from, without its line terminators.
+	raw []string
+}
+
+// Raw returns the lines of the frame the event was decoded from.
+func (e *SSEEvent) Raw() string {
+	return strings.Join(e.raw, "\n")
+}
+
+// SSEDecoder decodes a server-sent events stream, following the event stream interpretation of
+// the WHATWG HTML standard. Lines may end with CRLF, LF or CR, and have any length.
+type SSEDecoder struct {
+	reader io.Reader
+	buf    []byte
+	chunk  []byte
+	eof    bool
+
+	lastEventID string
+	retry       time.Duration
+}
+
+// NewSSEDecoder creates a decoder reading the stream from r.
+func NewSSEDecoder(r io.Reader) *SSEDecoder {
+	return &SSEDecoder{reader: r, chunk: make([]byte, 4096)}
+}
+
+// Next returns the next event, or io.EOF at the end of the stream. Unlike the standard, an event
+// which is not followed by a blank line at the end of the stream is still dispatched.
+func (d *SSEDecoder) Next() (*SSEEvent, error) {
+	event := &SSEEvent{}
+	var data strings.Builder
+	hasData := false
+	for {
+		line, err := d.readLine()
+		if err == io.EOF {
+			if hasData {
+				return d.dispatch(event, &data), nil
+			}
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, err
+		}
+
+		if len(line) == 0 {
+			if hasData {
+				return d.dispatch(event, &data), nil
+			}
+			// an event without data is not dispatched
+			event = &SSEEvent{}
+			continue
+		}
+		event.raw = append(event.raw, string(line))
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], line[i+1:]
+			if len(value) > 0 && value[0] == ' ' {
+				value = value[1:]
+			}
+		}
+		switch string(field) {
+		case "event":
+			event.Event = string(value)
+		case "data":
+			data.Write(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				d.lastEventID = string(value)
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(string(value), 10, 63); err == nil {
+				d.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+func (d *SSEDecoder) dispatch(event *SSEEvent, data *strings.Builder) *SSEEvent {
+	event.Data = strings.TrimSuffix(data.String(), "\n")
+	event.ID = d.lastEventID
+	event.Retry = d.retry
+	return event
+}
+
+// LastEventID returns the ID of the last event, to be sent as the Last-Event-ID header when
+// reconnecting.
+func (d *SSEDecoder) LastEventID() string {
+	return d.lastEventID
+}
+
+// Retry returns the reconnection time set by the server, zero if it was never set.
+func (d *SSEDecoder) Retry() time.Duration {
+	return d.retry
+}
+
+// readLine returns the next line without its terminator, the line is only valid until the next
+// call.
+func (d *SSEDecoder) readLine() ([]byte, error) {
+	scanned := 0
+	for {
+		if i := bytes.IndexAny(d.buf[scanned:], "\r\n"); i >= 0 {
+			i += scanned
+			if d.buf[i] == '\n' {
+				line := d.buf[:i]
+				d.buf = d.buf[i+1:]
+				return line, nil
+			}
+			// CR, possibly followed by LF which may not have been read yet
+			if i+1 < len(d.buf) || d.eof {
+				line := d.buf[:i]
+				d.buf = d.buf[i+1:]
+				if len(d.buf) > 0 && d.buf[0] == '\n' {
+					d.buf = d.buf[1:]
+				}
+				return line, nil
+			}
+			scanned = i
+		} else {
+			scanned = len(d.buf)
+		}
+
+		if d.eof {
+			if len(d.buf) == 0 {
+				return nil, io.EOF
+			}
+			line := d.buf
+			d.buf = nil
+			return line, nil
+		}
+		n, err := d.reader.Read(d.chunk)
+		d.buf = append(d.buf, d.chunk[:n]...)
+		if err == io.EOF {
+			d.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// StreamDecodeError is returned by Stream.Recv when an event can not be decoded.
+type StreamDecodeError struct {
+	// Event is the type of the event.
+	Event string
+	// Frame is the raw frame of the event.
+	Frame    string
+	parent   error
+	redactor *Redactor
+}
+
+func newStreamDecodeError(ctx context.Context, event *SSEEvent, err error) *StreamDecodeError {
+	return &StreamDecodeError{Event: event.Event, Frame: event.Raw(), parent: err, redactor: loggerFromContext(ctx).redactor}
+}
+
+// Error implements the error interface, the frame is redacted.
+func (e *StreamDecodeError) Error() string {
+	return fmt.Sprintf("decode stream event %q: %v, frame: %q", e.Event, e.parent, e.redactor.body([]byte(e.Frame)))
+}
+
+// Unwrap returns the parent error
+func (e *StreamDecodeError) Unwrap() error {
+	return e.parent
+}
+
+// Is reports whether the target is ErrStreamProtocol
+func (e *StreamDecodeError) Is(target error) bool {
+	return target == ErrStreamProtocol
+}
+
+// AsStreamDecodeError checks if the error is of type StreamDecodeError
+func AsStreamDecodeError(err error) (*StreamDecodeError, bool) {
+	var decodeErr *StreamDecodeError
+	if errors.As(err, &decodeErr) {
+		return decodeErr, true
+	}
+	return nil, false
+}
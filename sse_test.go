@@ -0,0 +1,119 @@
+package coze
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeAllSSE(t *testing.T, r io.Reader) []*SSEEvent {
+	decoder := NewSSEDecoder(r)
+	var events []*SSEEvent
+	for {
+		event, err := decoder.Next()
+		if err == io.EOF {
+			return events
+		}
+		require.NoError(t, err)
+		events = append(events, event)
+	}
+}
+
+func TestSSEDecoder(t *testing.T) {
+	t.Run("fields and comments", func(t *testing.T) {
+		stream := ": keep alive\n" +
+			"id: 1\n" +
+			"event: conversation.message.delta\n" +
+			"data: {\"a\":1}\n" +
+			"unknown: ignored\n" +
+			"\n" +
+			"retry: 3000\n" +
+			"data:second\n" +
+			"data:  line\n" +
+			"\n"
+		events := decodeAllSSE(t, strings.NewReader(stream))
+		require.Len(t, events, 2)
+
+		assert.Equal(t, "1", events[0].ID)
+		assert.Equal(t, "conversation.message.delta", events[0].Event)
+		assert.Equal(t, `{"a":1}`, events[0].Data)
+		assert.Equal(t, time.Duration(0), events[0].Retry)
+
+		// the id is kept, data lines are joined and only one leading space is removed
+		assert.Equal(t, "1", events[1].ID)
+		assert.Equal(t, "", events[1].Event)
+		assert.Equal(t, "second\n line", events[1].Data)
+		assert.Equal(t, 3*time.Second, events[1].Retry)
+	})
+
+	t.Run("line terminators", func(t *testing.T) {
+		stream := "event: a\r\ndata: 1\r\n\r\nevent: b\rdata: 2\r\revent: c\ndata: 3\n\n"
+		for name, r := range map[string]io.Reader{
+			"whole":    strings.NewReader(stream),
+			"one byte": iotest.OneByteReader(strings.NewReader(stream)),
+		} {
+			events := decodeAllSSE(t, r)
+			require.Len(t, events, 3, name)
+			for i, expected := range []string{"a", "b", "c"} {
+				assert.Equal(t, expected, events[i].Event, name)
+				assert.Equal(t, string(rune('1'+i)), events[i].Data, name)
+			}
+		}
+	})
+
+	t.Run("long lines", func(t *testing.T) {
+		payload := strings.Repeat("x", 1<<20)
+		events := decodeAllSSE(t, strings.NewReader("data: "+payload+"\n\n"))
+		require.Len(t, events, 1)
+		assert.Equal(t, payload, events[0].Data)
+	})
+
+	t.Run("events without data are not dispatched", func(t *testing.T) {
+		events := decodeAllSSE(t, strings.NewReader("event: ping\n\ndata\n\n"))
+		require.Len(t, events, 1)
+		assert.Equal(t, "", events[0].Data)
+	})
+
+	t.Run("invalid id and retry are ignored", func(t *testing.T) {
+		events := decodeAllSSE(t, strings.NewReader("id: 1\n\nid: a\x00b\nretry: 1s\ndata: x\n\n"))
+		require.Len(t, events, 1)
+		assert.Equal(t, "1", events[0].ID)
+		assert.Equal(t, time.Duration(0), events[0].Retry)
+	})
+
+	t.Run("last event without blank line", func(t *testing.T) {
+		events := decodeAllSSE(t, strings.NewReader("event: done\ndata: [DONE]"))
+		require.Len(t, events, 1)
+		assert.Equal(t, "done", events[0].Event)
+		assert.Equal(t, "[DONE]", events[0].Data)
+	})
+
+	t.Run("raw frame", func(t *testing.T) {
+		events := decodeAllSSE(t, strings.NewReader("event: a\r\ndata: 1\r\ndata: 2\r\n\r\n"))
+		require.Len(t, events, 1)
+		assert.Equal(t, "event: a\ndata: 1\ndata: 2", events[0].Raw())
+	})
+}
+
+func TestStreamDecodeError(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("event: conversation.message.delta\ndata: {broken\n\n")),
+	}
+	stream := newStreamReader(context.Background(), resp, parseChatEvent)
+
+	event, err := stream.Recv()
+	assert.Nil(t, event)
+	decodeErr, ok := AsStreamDecodeError(err)
+	require.True(t, ok)
+	assert.Equal(t, "conversation.message.delta", decodeErr.Event)
+	assert.Equal(t, "event: conversation.message.delta\ndata: {broken", decodeErr.Frame)
+}
@@ -1,11 +1,12 @@
 package coze
 
 import (
-	"bufio"
 	"context"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 )
 
 type streamable interface {
@@ -17,41 +18,78 @@ type Stream[T streamable] interface {
 	Close() error
 	Recv() (*T, error)
 }
-type eventProcessor[T streamable] func(line []byte, reader *bufio.Reader) (*T, bool, error)
+
+// StreamMetadata is implemented by the streams returned by the client, it exposes the
+// server-sent events metadata of the stream.
+type StreamMetadata interface {
+	// LastEventID returns the ID of the last received event.
+	LastEventID() string
+	// Retry returns the reconnection time set by the server, zero if it was never set.
+	Retry() time.Duration
+}
+
+// eventProcessor converts a server-sent event into a stream event, it returns a nil event for the
+// events to skip.
+type eventProcessor[T streamable] func(ctx context.Context, event *SSEEvent) (*T, bool, error)
 
 type streamReader[T streamable] struct {
 	isFinished bool
 	ctx        context.Context
+	// returnErrors returns the error events as errors instead of delivering them as events.
+	returnErrors bool
 
-	reader       *bufio.Reader
+	decoder      *SSEDecoder
 	response     *http.Response
 	processor    eventProcessor[T]
 	httpResponse *httpResponse
 }
 
+func newStreamReader[T streamable](ctx context.Context, resp *http.Response, processor eventProcessor[T]) *streamReader[T] {
+	return &streamReader[T]{
+		ctx:          ctx,
+		response:     resp,
+		decoder:      NewSSEDecoder(resp.Body),
+		processor:    processor,
+		httpResponse: newHTTPResponse(resp),
+	}
+}
+
+// newClientStreamReader creates the stream reader of a response of the client.
+func newClientStreamReader[T streamable](ctx context.Context, c *core, resp *http.Response, processor eventProcessor[T]) *streamReader[T] {
+	s := newStreamReader(withLogger(ctx, c.log), resp, processor)
+	s.returnErrors = c.streamErrorsReturned
+	return s
+}
+
 func (s *streamReader[T]) Recv() (response *T, err error) {
-	return s.processLines()
+	event, err := s.processLines()
+	if observer, ok := s.response.Body.(streamObserver); ok {
+		if err != nil {
+			observer.onStreamEnd(s.ctx, err)
+		} else {
+			observer.onStreamEvent(s.ctx, event)
+		}
+	}
+	return event, err
 }
 
 func (s *streamReader[T]) processLines() (*T, error) {
+	if s.isFinished {
+		return nil, io.EOF
+	}
 	err := s.checkRespErr()
 	if err != nil {
 		return nil, err
 	}
 	for {
-		line, _, readErr := s.reader.ReadLine()
-		if readErr != nil {
-			return nil, readErr
-		}
-
-		if line == nil {
+		sseEvent, err := s.decoder.Next()
+		if err == io.EOF {
 			s.isFinished = true
-			break
-		}
-		if len(line) == 0 {
-			continue
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, &streamReadError{parent: err}
 		}
-		event, isDone, err := s.processor(line, s.reader)
+		event, isDone, err := s.processor(s.ctx, sseEvent)
 		if err != nil {
 			return nil, err
 		}
@@ -59,9 +97,15 @@ func (s *streamReader[T]) processLines() (*T, error) {
 		if event == nil {
 			continue
 		}
+		if streamErr := streamEventError(event); streamErr != nil {
+			streamErr.LogID = s.httpResponse.LogID()
+			if s.returnErrors {
+				s.isFinished = true
+				return nil, streamErr
+			}
+		}
 		return event, nil
 	}
-	return nil, io.EOF
 }
 
 func (s *streamReader[T]) checkRespErr() error {
@@ -69,7 +113,7 @@ func (s *streamReader[T]) checkRespErr() error {
 	if contentType != "" && strings.Contains(contentType, "application/json") {
 		respStr, err := io.ReadAll(s.response.Body)
 		if err != nil {
-			logger.Warnf(s.ctx, "Error reading response body: ", err)
+			loggerFromContext(s.ctx).Warnf(s.ctx, "Error reading response body: %v", err)
 			return err
 		}
 		return isResponseSuccess(s.ctx, &baseResponse{}, respStr, s.httpResponse)
@@ -78,9 +122,104 @@ func (s *streamReader[T]) checkRespErr() error {
 }
 
 func (s *streamReader[T]) Close() error {
+	if observer, ok := s.response.Body.(streamObserver); ok {
+		observer.onStreamEnd(s.ctx, nil)
+	}
 	return s.response.Body.Close()
 }
 
 func (s *streamReader[T]) Response() HTTPResponse {
 	return s.httpResponse
 }
+
+func (s *streamReader[T]) LastEventID() string {
+	return s.decoder.LastEventID()
+}
+
+func (s *streamReader[T]) Retry() time.Duration {
+	return s.decoder.Retry()
+}
+
+// streamReadError is a failure to read the stream body, e.g. a dropped connection.
+type streamReadError struct {
+	parent error
+}
+
+func (e *streamReadError) Error() string {
+	return e.parent.Error()
+}
+
+func (e *streamReadError) Unwrap() error {
+	return e.parent
+}
+
+// streamObserver is notified about the events of a stream, it is attached to the response body
+// by addStreamObserver.
+type streamObserver interface {
+	onStreamEvent(ctx context.Context, event any)
+	// onStreamEnd is called once, with io.EOF when the stream is finished or nil when it is closed.
+	onStreamEnd(ctx context.Context, err error)
+}
+
+// observedBody is a response body which notifies its observers about the stream events. The
+// notifications are serialized, since the stream may be closed by another goroutine than the one
+// calling Recv, e.g. by StreamChan when its context is done.
+type observedBody struct {
+	io.ReadCloser
+	observers []streamObserver
+
+	mu    sync.Mutex
+	ended bool
+}
+
+func (b *observedBody) onStreamEvent(ctx context.Context, event any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ended {
+		return
+	}
+	for _, observer := range b.observers {
+		observer.onStreamEvent(ctx, event)
+	}
+}
+
+func (b *observedBody) onStreamEnd(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ended {
+		return
+	}
+	b.ended = true
+	for _, observer := range b.observers {
+		observer.onStreamEnd(ctx, err)
+	}
+}
+
+func (b *observedBody) Close() error {
+	b.onStreamEnd(context.Background(), nil)
+	return b.ReadCloser.Close()
+}
+
+// addStreamObserver attaches the observer to the stream response.
+func addStreamObserver(resp *http.Response, observer streamObserver) {
+	if body, ok := resp.Body.(*observedBody); ok {
+		body.observers = append(body.observers, observer)
+		return
+	}
+	resp.Body = &observedBody{ReadCloser: resp.Body, observers: []streamObserver{observer}}
+}
+
+// streamEventError returns the error of an error event, or nil for the other events.
+func streamEventError[T streamable](event *T) *Error {
+	switch e := any(event).(type) {
+	case *ChatEvent:
+		if e.Event == ChatEventError {
+			return e.Error
+		}
+	case *WorkflowEvent:
+		if e.Event == WorkflowEventTypeError && e.Error != nil {
+			return &Error{Code: e.Error.ErrorCode, Message: e.Error.ErrorMessage}
+		}
+	}
+	return nil
+}
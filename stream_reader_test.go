@@ -1,7 +1,6 @@
 package coze
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"io"
@@ -22,7 +21,8 @@ func mockHTTPResponse() *httpResponse {
 }
 
 // Mock event processor for testing
-func mockEventProcessor(line []byte, reader *bufio.Reader) (*WorkflowEvent, bool, error) {
+func mockEventProcessor(ctx context.Context, sseEvent *SSEEvent) (*WorkflowEvent, bool, error) {
+	line := sseEvent.Data
 	if len(line) == 0 {
 		return nil, false, nil
 	}
@@ -32,12 +32,12 @@ func mockEventProcessor(line []byte, reader *bufio.Reader) (*WorkflowEvent, bool
 		ID:    0,
 		Event: WorkflowEventTypeMessage,
 		Message: &WorkflowEventMessage{
-			Content: string(line),
+			Content: line,
 		},
 	}
 
 	// Check if this is the last event
-	isDone := string(line) == "done"
+	isDone := line == "done"
 	if isDone {
 		event.Event = WorkflowEventTypeDone
 	}
@@ -58,7 +58,7 @@ func TestStreamReader(t *testing.T) {
 		// Create stream reader
 		reader := &streamReader[WorkflowEvent]{
 			ctx:          ctx,
-			reader:       bufio.NewReader(resp.Body),
+			decoder:      NewSSEDecoder(resp.Body),
 			response:     resp,
 			processor:    mockEventProcessor,
 			httpResponse: mockHTTPResponse(),
@@ -91,6 +91,26 @@ func TestStreamReader(t *testing.T) {
 		assert.Nil(t, event)
 	})
 
+	t.Run("events after the done event are not read", func(t *testing.T) {
+		resp := createMockResponse([]string{"done", "late"})
+		reader := &streamReader[WorkflowEvent]{
+			ctx:          ctx,
+			decoder:      NewSSEDecoder(resp.Body),
+			response:     resp,
+			processor:    mockEventProcessor,
+			httpResponse: mockHTTPResponse(),
+		}
+		defer reader.Close()
+
+		event, err := reader.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, WorkflowEventTypeDone, event.Event)
+
+		event, err = reader.Recv()
+		assert.Equal(t, io.EOF, err)
+		assert.Nil(t, event)
+	})
+
 	t.Run("empty lines are skipped", func(t *testing.T) {
 		events := []string{
 			"",
@@ -102,7 +122,7 @@ func TestStreamReader(t *testing.T) {
 
 		reader := &streamReader[WorkflowEvent]{
 			ctx:          ctx,
-			reader:       bufio.NewReader(resp.Body),
+			decoder:      NewSSEDecoder(resp.Body),
 			response:     resp,
 			processor:    mockEventProcessor,
 			httpResponse: mockHTTPResponse(),
@@ -139,7 +159,7 @@ func TestStreamReader(t *testing.T) {
 
 		reader := &streamReader[WorkflowEvent]{
 			ctx:          ctx,
-			reader:       bufio.NewReader(errorResp.Body),
+			decoder:      NewSSEDecoder(errorResp.Body),
 			response:     errorResp,
 			processor:    mockEventProcessor,
 			httpResponse: mockHTTPResponse(),
@@ -163,8 +183,15 @@ func TestStreamReader(t *testing.T) {
 
 // Helper function to create mock response with events
 func createMockResponse(events []string) *http.Response {
-	// Join events with newlines
-	body := strings.Join(events, "\n")
+	// Send every event as a data frame, empty events as blank lines
+	body := ""
+	for _, event := range events {
+		if event == "" {
+			body += "\n"
+			continue
+		}
+		body += "data: " + event + "\n\n"
+	}
 
 	return &http.Response{
 		StatusCode: http.StatusOK,
@@ -0,0 +1,347 @@
+package coze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resumePollInterval is the interval of the polling which reconstructs a dropped stream.
+var resumePollInterval = time.Second
+
+// defaultResumeTimeout bounds the polling of a dropped stream when WithStreamResumeTimeout is
+// not set.
+const defaultResumeTimeout = 10 * time.Minute
+
+// resumableStream wraps a stream, and when the connection drops it reconstructs the rest of the
+// stream from the API instead of returning the read error.
+type resumableStream[T streamable] struct {
+	// ctx is canceled by Close, which stops a resume in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+	stream Stream[T]
+
+	// observe is called with every event received by the consumer.
+	observe func(event *T)
+	// resume returns the events the consumer has not received yet, once the call has finished.
+	resume func(ctx context.Context) ([]*T, error)
+
+	// returnErrors returns the error events as errors instead of delivering them as events.
+	returnErrors bool
+	// timeout bounds the wait for the end of the call, defaultResumeTimeout if zero.
+	timeout time.Duration
+
+	// mu guards resumed and closed, as Close may be called while Recv is blocked, e.g. by
+	// StreamChan when its context is done.
+	mu      sync.Mutex
+	resumed bool
+	closed  bool
+	pending []*T
+}
+
+func newResumableStream[T streamable](ctx context.Context, c *core, stream Stream[T],
+	observe func(event *T), resume func(ctx context.Context) ([]*T, error),
+) *resumableStream[T] {
+	ctx, cancel := context.WithCancel(withLogger(ctx, c.log))
+	return &resumableStream[T]{
+		ctx:          ctx,
+		cancel:       cancel,
+		stream:       stream,
+		observe:      observe,
+		resume:       resume,
+		returnErrors: c.streamErrorsReturned,
+		timeout:      c.streamResumeTimeout,
+	}
+}
+
+func (s *resumableStream[T]) Recv() (*T, error) {
+	if s.resumed {
+		if len(s.pending) == 0 {
+			return nil, io.EOF
+		}
+		event := s.pending[0]
+		s.pending = s.pending[1:]
+		if streamErr := streamEventError(event); streamErr != nil {
+			streamErr.LogID = s.stream.Response().LogID()
+			if s.returnErrors {
+				s.pending = nil
+				return nil, streamErr
+			}
+		}
+		return event, nil
+	}
+
+	event, err := s.stream.Recv()
+	if err == nil {
+		s.observe(event)
+		return event, nil
+	}
+	var readErr *streamReadError
+	if !errors.As(err, &readErr) || s.ctx.Err() != nil {
+		return nil, err
+	}
+
+	loggerFromContext(s.ctx).Infof(s.ctx, "stream dropped, resume it: %v", err)
+	timeout := s.timeout
+	if timeout <= 0 {
+		timeout = defaultResumeTimeout
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, timeout)
+	events, resumeErr := s.resume(ctx)
+	cancel()
+	if resumeErr != nil {
+		loggerFromContext(s.ctx).Warnf(s.ctx, "resume stream failed: %v", resumeErr)
+		return nil, err
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.resumed = true
+	s.pending = events
+	s.mu.Unlock()
+	_ = s.stream.Close()
+	return s.Recv()
+}
+
+func (s *resumableStream[T]) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	resumed := s.resumed
+	s.mu.Unlock()
+	s.cancel()
+	if resumed {
+		return nil
+	}
+	return s.stream.Close()
+}
+
+func (s *resumableStream[T]) Response() HTTPResponse {
+	return s.stream.Response()
+}
+
+// LastEventID returns the ID of the last event received from the original stream.
+func (s *resumableStream[T]) LastEventID() string {
+	if metadata, ok := s.stream.(StreamMetadata); ok {
+		return metadata.LastEventID()
+	}
+	return ""
+}
+
+func (s *resumableStream[T]) Retry() time.Duration {
+	if metadata, ok := s.stream.(StreamMetadata); ok {
+		return metadata.Retry()
+	}
+	return 0
+}
+
+// StreamResumable is like Stream, but when the connection drops it waits for the chat to finish
+// and emits the events the consumer has not received yet, rebuilt from Retrieve and Messages.List.
+// The deltas of a message which was being streamed are merged into a single delta, and the wait is
+// bounded by WithStreamResumeTimeout.
+func (r *chat) StreamResumable(ctx context.Context, req *CreateChatsReq) (Stream[ChatEvent], error) {
+	stream, err := r.Stream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	state := &chatStreamState{
+		chats:     r,
+		deltas:    map[string]string{},
+		completed: map[string]bool{},
+	}
+	return newResumableStream[ChatEvent](ctx, r.client, stream, state.observe, state.resume), nil
+}
+
+// chatStreamState records the chat events received by the consumer.
+type chatStreamState struct {
+	chats          *chat
+	chatID         string
+	conversationID string
+	chatFinished   bool
+	deltas         map[string]string
+	completed      map[string]bool
+}
+
+func (s *chatStreamState) observe(event *ChatEvent) {
+	if event.Chat != nil {
+		s.chatID = event.Chat.ID
+		s.conversationID = event.Chat.ConversationID
+	}
+	switch event.Event {
+	case ChatEventConversationChatCompleted, ChatEventConversationChatFailed, ChatEventConversationChatRequiresAction:
+		s.chatFinished = true
+	case ChatEventConversationMessageDelta:
+		if event.Message != nil {
+			s.deltas[event.Message.ID] += event.Message.Content
+		}
+	case ChatEventConversationMessageCompleted:
+		if event.Message != nil {
+			s.completed[event.Message.ID] = true
+		}
+	}
+}
+
+func (s *chatStreamState) resume(ctx context.Context) ([]*ChatEvent, error) {
+	if s.chatID == "" {
+		return nil, errors.New("the chat id was not received")
+	}
+	// the chat is not polled once the consumer received its end, requires_action included, as
+	// the chat does not change until the tool outputs are submitted
+	var chat *Chat
+	for !s.chatFinished {
+		resp, err := s.chats.Retrieve(ctx, &RetrieveChatsReq{ConversationID: s.conversationID, ChatID: s.chatID})
+		if err != nil {
+			return nil, err
+		}
+		if resp.Chat.Status != ChatStatusCreated && resp.Chat.Status != ChatStatusInProgress {
+			chat = &resp.Chat
+			break
+		}
+		if err := sleepContext(ctx, resumePollInterval); err != nil {
+			return nil, err
+		}
+	}
+	messages, err := s.chats.Messages.List(ctx, &ListChatsMessagesReq{ConversationID: s.conversationID, ChatID: s.chatID})
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*ChatEvent
+	for _, message := range messages.Messages {
+		if s.completed[message.ID] || message.Role == MessageRoleUser {
+			continue
+		}
+		if message.Type == MessageTypeAnswer {
+			sent := s.deltas[message.ID]
+			if strings.HasPrefix(message.Content, sent) && len(message.Content) > len(sent) {
+				delta := *message
+				delta.Content = message.Content[len(sent):]
+				events = append(events, &ChatEvent{Event: ChatEventConversationMessageDelta, Message: &delta})
+			}
+		}
+		events = append(events, &ChatEvent{Event: ChatEventConversationMessageCompleted, Message: message})
+	}
+	if !s.chatFinished {
+		switch chat.Status {
+		case ChatStatusCompleted:
+			events = append(events, &ChatEvent{Event: ChatEventConversationChatCompleted, Chat: chat})
+		case ChatStatusFailed:
+			events = append(events, &ChatEvent{Event: ChatEventConversationChatFailed, Chat: chat})
+		case ChatStatusRequiresAction:
+			events = append(events, &ChatEvent{Event: ChatEventConversationChatRequiresAction, Chat: chat})
+		default:
+			// no chat event tells a canceled chat or an unknown status, the stream ends with an error
+			events = append(events, &ChatEvent{Event: ChatEventError, Chat: chat, Error: &Error{
+				Message: fmt.Sprintf("chat %s ended with status %s", chat.ID, chat.Status),
+			}})
+		}
+	}
+	return append(events, &ChatEvent{Event: ChatEventDone}), nil
+}
+
+// StreamResumable is like Stream, but when the connection drops it waits for the run to finish
+// and emits its output or error from Histories.Retrieve. The execute ID is read from the events,
+// a run whose events did not expose it can not be resumed and returns the read error. A run which
+// was interrupted waits for Resume, its stream ends after the interrupt event. The wait is bounded
+// by WithStreamResumeTimeout.
+func (r *workflowRuns) StreamResumable(ctx context.Context, req *RunWorkflowsReq) (Stream[WorkflowEvent], error) {
+	stream, err := r.Stream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	state := &workflowStreamState{runs: r, workflowID: req.WorkflowID, lastID: -1}
+	return newResumableStream[WorkflowEvent](ctx, r.client, stream, state.observe, state.resume), nil
+}
+
+// workflowStreamState records the workflow events received by the consumer.
+type workflowStreamState struct {
+	runs       *workflowRuns
+	workflowID string
+	executeID  string
+	lastID     int
+	endSent    bool
+	// interrupt is the interrupt event received by the consumer, the run then waits for
+	// Resume and its history stays running.
+	interrupt *WorkflowEvent
+}
+
+func (s *workflowStreamState) observe(event *WorkflowEvent) {
+	s.lastID = event.ID
+	if event.Event == WorkflowEventTypeInterrupt {
+		s.interrupt = event
+	}
+	if event.Message != nil {
+		if executeID, ok := event.Message.Ext["execute_id"].(string); ok && executeID != "" {
+			s.executeID = executeID
+		}
+		if event.Message.NodeTitle == "End" && event.Message.NodeIsFinish {
+			s.endSent = true
+		}
+	}
+	if event.DebugURL != nil {
+		if u, err := url.Parse(event.DebugURL.URL); err == nil && u.Query().Get("execute_id") != "" {
+			s.executeID = u.Query().Get("execute_id")
+		}
+	}
+}
+
+func (s *workflowStreamState) resume(ctx context.Context) ([]*WorkflowEvent, error) {
+	nextID := func() int {
+		s.lastID++
+		return s.lastID
+	}
+	if s.interrupt != nil {
+		return []*WorkflowEvent{{ID: nextID(), Event: WorkflowEventTypeDone}}, nil
+	}
+	if s.executeID == "" {
+		return nil, errors.New("the execute id was not received")
+	}
+	var history *WorkflowRunHistory
+	for {
+		resp, err := s.runs.Histories.Retrieve(ctx, &RetrieveWorkflowsRunsHistoriesReq{
+			WorkflowID: s.workflowID,
+			ExecuteID:  s.executeID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Histories) > 0 && resp.Histories[0].ExecuteStatus != WorkflowExecuteStatusRunning {
+			history = resp.Histories[0]
+			break
+		}
+		if err := sleepContext(ctx, resumePollInterval); err != nil {
+			return nil, err
+		}
+	}
+
+	var events []*WorkflowEvent
+	switch history.ExecuteStatus {
+	case WorkflowExecuteStatusSuccess:
+		if !s.endSent {
+			events = append(events, &WorkflowEvent{
+				ID:    nextID(),
+				Event: WorkflowEventTypeMessage,
+				Message: &WorkflowEventMessage{
+					Content:      history.Output,
+					NodeTitle:    "End",
+					NodeIsFinish: true,
+				},
+			})
+		}
+	case WorkflowExecuteStatusFail:
+		code, _ := strconv.Atoi(history.ErrorCode)
+		events = append(events, &WorkflowEvent{
+			ID:    nextID(),
+			Event: WorkflowEventTypeError,
+			Error: &WorkflowEventError{ErrorCode: code, ErrorMessage: history.ErrorMessage},
+		})
+	}
+	return append(events, &WorkflowEvent{ID: nextID(), Event: WorkflowEventTypeDone}), nil
+}
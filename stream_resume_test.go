@@ -0,0 +1,297 @@
+package coze
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockDroppedStreamResponse returns a stream whose connection drops after data.
+func mockDroppedStreamResponse(data string) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(io.MultiReader(strings.NewReader(data), iotest.ErrReader(errors.New("connection reset by peer")))),
+		Header:     http.Header{httpLogIDKey: []string{"test_log_id"}},
+	}, nil
+}
+
+func recvAll[T streamable](t *testing.T, stream Stream[T]) []*T {
+	var events []*T
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return events
+		}
+		require.NoError(t, err)
+		events = append(events, event)
+	}
+}
+
+func TestChatStreamResumable(t *testing.T) {
+	defer func(interval time.Duration) { resumePollInterval = interval }(resumePollInterval)
+	resumePollInterval = time.Millisecond
+
+	t.Run("resume after the connection drops", func(t *testing.T) {
+		retrieves := 0
+		core := newCore(&clientOption{baseURL: ComBaseURL, client: &http.Client{Transport: &mockTransport{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				switch req.URL.Path {
+				case "/v3/chat":
+					return mockDroppedStreamResponse(`event: conversation.chat.created
+data: {"id":"chat1","conversation_id":"conv1","bot_id":"bot1","status":"created"}
+
+event: conversation.message.delta
+data: {"id":"msg1","conversation_id":"conv1","role":"assistant","type":"answer","content":"Hel"}
+
+`)
+				case "/v3/chat/retrieve":
+					retrieves++
+					status := ChatStatusInProgress
+					if retrieves > 1 {
+						status = ChatStatusCompleted
+					}
+					return mockResponse(http.StatusOK, map[string]any{
+						"data": &Chat{ID: "chat1", ConversationID: "conv1", Status: status},
+					})
+				case "/v3/chat/message/list":
+					return mockResponse(http.StatusOK, map[string]any{
+						"data": []*Message{
+							{ID: "msg1", Role: MessageRoleAssistant, Type: MessageTypeAnswer, Content: "Hello"},
+							{ID: "msg2", Role: MessageRoleAssistant, Type: MessageTypeFollowUp, Content: "More?"},
+						},
+					})
+				}
+				t.Fatalf("unexpected request %s", req.URL.Path)
+				return nil, nil
+			},
+		}}})
+
+		stream, err := newChats(core).StreamResumable(context.Background(), &CreateChatsReq{ConversationID: "conv1", BotID: "bot1"})
+		require.NoError(t, err)
+		defer stream.Close()
+
+		events := recvAll(t, stream)
+		require.Len(t, events, 7)
+		assert.Equal(t, ChatEventConversationChatCreated, events[0].Event)
+		assert.Equal(t, "Hel", events[1].Message.Content)
+		assert.Equal(t, ChatEventConversationMessageDelta, events[2].Event)
+		assert.Equal(t, "lo", events[2].Message.Content)
+		assert.Equal(t, ChatEventConversationMessageCompleted, events[3].Event)
+		assert.Equal(t, "Hello", events[3].Message.Content)
+		assert.Equal(t, ChatEventConversationMessageCompleted, events[4].Event)
+		assert.Equal(t, "More?", events[4].Message.Content)
+		assert.Equal(t, ChatEventConversationChatCompleted, events[5].Event)
+		assert.Equal(t, ChatEventDone, events[6].Event)
+		assert.Equal(t, 2, retrieves)
+	})
+
+	t.Run("requires action is not polled", func(t *testing.T) {
+		core := newCore(&clientOption{baseURL: ComBaseURL, client: &http.Client{Transport: &mockTransport{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				switch req.URL.Path {
+				case "/v3/chat":
+					return mockDroppedStreamResponse(`event: conversation.chat.created
+data: {"id":"chat1","conversation_id":"conv1","bot_id":"bot1","status":"created"}
+
+event: conversation.chat.requires_action
+data: {"id":"chat1","conversation_id":"conv1","bot_id":"bot1","status":"requires_action"}
+
+`)
+				case "/v3/chat/message/list":
+					return mockResponse(http.StatusOK, map[string]any{"data": []*Message{}})
+				}
+				t.Fatalf("unexpected request %s", req.URL.Path)
+				return nil, nil
+			},
+		}}})
+
+		stream, err := newChats(core).StreamResumable(context.Background(), &CreateChatsReq{ConversationID: "conv1", BotID: "bot1"})
+		require.NoError(t, err)
+		defer stream.Close()
+
+		events := recvAll(t, stream)
+		require.Len(t, events, 3)
+		assert.Equal(t, ChatEventConversationChatRequiresAction, events[1].Event)
+		assert.Equal(t, ChatEventDone, events[2].Event)
+	})
+
+	t.Run("canceled chat ends with an error", func(t *testing.T) {
+		newTestChats := func(errorsReturned bool) *chat {
+			return newChats(newCore(&clientOption{baseURL: ComBaseURL, streamErrorsReturned: errorsReturned, client: &http.Client{Transport: &mockTransport{
+				roundTripFunc: func(req *http.Request) (*http.Response, error) {
+					switch req.URL.Path {
+					case "/v3/chat":
+						return mockDroppedStreamResponse(`event: conversation.chat.created
+data: {"id":"chat1","conversation_id":"conv1","bot_id":"bot1","status":"created"}
+
+`)
+					case "/v3/chat/retrieve":
+						return mockResponse(http.StatusOK, map[string]any{
+							"data": &Chat{ID: "chat1", ConversationID: "conv1", Status: ChatStatusCancelled},
+						})
+					case "/v3/chat/message/list":
+						return mockResponse(http.StatusOK, map[string]any{"data": []*Message{}})
+					}
+					t.Fatalf("unexpected request %s", req.URL.Path)
+					return nil, nil
+				},
+			}}}))
+		}
+
+		stream, err := newTestChats(false).StreamResumable(context.Background(), &CreateChatsReq{ConversationID: "conv1", BotID: "bot1"})
+		require.NoError(t, err)
+		defer stream.Close()
+		events := recvAll(t, stream)
+		require.Len(t, events, 3)
+		assert.Equal(t, ChatEventError, events[1].Event)
+		assert.Equal(t, ChatStatusCancelled, events[1].Chat.Status)
+		assert.Equal(t, "chat chat1 ended with status canceled", events[1].Error.Message)
+		assert.Equal(t, ChatEventDone, events[2].Event)
+
+		stream, err = newTestChats(true).StreamResumable(context.Background(), &CreateChatsReq{ConversationID: "conv1", BotID: "bot1"})
+		require.NoError(t, err)
+		defer stream.Close()
+		_, err = stream.Recv()
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		cozeErr, ok := AsCozeError(err)
+		require.True(t, ok)
+		assert.Equal(t, "chat chat1 ended with status canceled", cozeErr.Message)
+		assert.Equal(t, "test_log_id", cozeErr.LogID)
+		_, err = stream.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("read error before the chat is created", func(t *testing.T) {
+		core := newCore(&clientOption{baseURL: ComBaseURL, client: &http.Client{Transport: &mockTransport{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				return mockDroppedStreamResponse("")
+			},
+		}}})
+
+		stream, err := newChats(core).StreamResumable(context.Background(), &CreateChatsReq{ConversationID: "conv1", BotID: "bot1"})
+		require.NoError(t, err)
+		defer stream.Close()
+
+		_, err = stream.Recv()
+		assert.EqualError(t, err, "connection reset by peer")
+	})
+}
+
+func TestWorkflowStreamResumable(t *testing.T) {
+	defer func(interval time.Duration) { resumePollInterval = interval }(resumePollInterval)
+	resumePollInterval = time.Millisecond
+
+	t.Run("resume after the connection drops", func(t *testing.T) {
+		testWorkflowStreamResumed(t)
+	})
+
+	t.Run("interrupted run is not polled", func(t *testing.T) {
+		core := newCore(&clientOption{baseURL: ComBaseURL, client: &http.Client{Transport: &mockTransport{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				if req.URL.Path == "/v1/workflow/stream_run" {
+					return mockDroppedStreamResponse(`id:0
+event:Message
+data:{"content":"Hello","node_title":"Start","node_seq_id":"0","node_is_finish":true,"ext":{"execute_id":"exec1"}}
+
+id:1
+event:Interrupt
+data:{"interrupt_data":{"data":"Which city?","event_id":"event1","type":2},"node_title":"Question"}
+
+`)
+				}
+				t.Fatalf("unexpected request %s", req.URL.Path)
+				return nil, nil
+			},
+		}}})
+
+		stream, err := newWorkflowRun(core).StreamResumable(context.Background(), &RunWorkflowsReq{WorkflowID: "workflow1"})
+		require.NoError(t, err)
+		defer stream.Close()
+
+		events := recvAll(t, stream)
+		require.Len(t, events, 3)
+		assert.Equal(t, WorkflowEventTypeInterrupt, events[1].Event)
+		assert.Equal(t, "event1", events[1].Interrupt.InterruptData.EventID)
+		assert.Equal(t, WorkflowEventTypeDone, events[2].Event)
+		assert.Equal(t, 2, events[2].ID)
+	})
+
+	t.Run("resume timeout", func(t *testing.T) {
+		core := newCore(&clientOption{baseURL: ComBaseURL, streamResumeTimeout: 20 * time.Millisecond, client: &http.Client{Transport: &mockTransport{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				switch req.URL.Path {
+				case "/v1/workflow/stream_run":
+					return mockDroppedStreamResponse(`id:0
+event:Message
+data:{"content":"Hello","node_title":"Start","node_seq_id":"0","node_is_finish":true,"ext":{"execute_id":"exec1"}}
+
+`)
+				case "/v1/workflows/workflow1/run_histories/exec1":
+					return mockResponse(http.StatusOK, map[string]any{
+						"data": []*WorkflowRunHistory{{ExecuteID: "exec1", ExecuteStatus: WorkflowExecuteStatusRunning}},
+					})
+				}
+				t.Fatalf("unexpected request %s", req.URL.Path)
+				return nil, nil
+			},
+		}}})
+
+		stream, err := newWorkflowRun(core).StreamResumable(context.Background(), &RunWorkflowsReq{WorkflowID: "workflow1"})
+		require.NoError(t, err)
+		defer stream.Close()
+
+		_, err = stream.Recv()
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.EqualError(t, err, "connection reset by peer")
+	})
+}
+
+func testWorkflowStreamResumed(t *testing.T) {
+	core := newCore(&clientOption{baseURL: ComBaseURL, client: &http.Client{Transport: &mockTransport{
+		roundTripFunc: func(req *http.Request) (*http.Response, error) {
+			switch req.URL.Path {
+			case "/v1/workflow/stream_run":
+				return mockDroppedStreamResponse(`id:0
+event:Message
+data:{"content":"Hello","node_title":"Start","node_seq_id":"0","node_is_finish":true,"ext":{"execute_id":"exec1"}}
+
+`)
+			case "/v1/workflows/workflow1/run_histories/exec1":
+				return mockResponse(http.StatusOK, map[string]any{
+					"data": []*WorkflowRunHistory{{ExecuteID: "exec1", ExecuteStatus: WorkflowExecuteStatusSuccess, Output: `{"output":"World"}`}},
+				})
+			}
+			t.Fatalf("unexpected request %s", req.URL.Path)
+			return nil, nil
+		},
+	}}})
+
+	stream, err := newWorkflowRun(core).StreamResumable(context.Background(), &RunWorkflowsReq{WorkflowID: "workflow1"})
+	require.NoError(t, err)
+	defer stream.Close()
+
+	events := recvAll(t, stream)
+	require.Len(t, events, 3)
+	assert.Equal(t, "Hello", events[0].Message.Content)
+	assert.Equal(t, 1, events[1].ID)
+	assert.Equal(t, `{"output":"World"}`, events[1].Message.Content)
+	assert.True(t, events[1].Message.NodeIsFinish)
+	assert.Equal(t, WorkflowEventTypeDone, events[2].Event)
+	assert.Equal(t, 2, events[2].ID)
+
+	// the metadata and the log id are the ones of the original response
+	metadata, ok := stream.(StreamMetadata)
+	require.True(t, ok)
+	assert.Equal(t, "0", metadata.LastEventID())
+	assert.Equal(t, "test_log_id", stream.Response().LogID())
+}
@@ -0,0 +1,130 @@
+package coze
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TokenStore persists OAuth tokens, so that they can be reused across restarts and shared between
+// processes until they expire. Implementations must be safe for concurrent use.
+type TokenStore interface {
+	// Get returns the token stored under key, or nil if there is none.
+	Get(ctx context.Context, key string) (*OAuthToken, error)
+	Set(ctx context.Context, key string, token *OAuthToken) error
+	Delete(ctx context.Context, key string) error
+}
+
+// TokenStoreKey builds the store key of the token of a client, for a user or session.
+func TokenStoreKey(clientID, subject string) string {
+	return clientID + ":" + subject
+}
+
+var (
+	_ TokenStore = &memoryTokenStore{}
+	_ TokenStore = &fileTokenStore{}
+)
+
+// NewMemoryTokenStore creates a TokenStore keeping the tokens in memory, shared by the Auth
+// instances of a process.
+func NewMemoryTokenStore() TokenStore {
+	return &memoryTokenStore{tokens: map[string]OAuthToken{}}
+}
+
+type memoryTokenStore struct {
+	mu     sync.RWMutex
+	tokens map[string]OAuthToken
+}
+
+func (s *memoryTokenStore) Get(ctx context.Context, key string) (*OAuthToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	token, ok := s.tokens[key]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+func (s *memoryTokenStore) Set(ctx context.Context, key string, token *OAuthToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[key] = *token
+	return nil
+}
+
+func (s *memoryTokenStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, key)
+	return nil
+}
+
+// NewFileTokenStore creates a TokenStore keeping every token in its own file in dir. The files are
+// only readable by the current user, and are replaced atomically so that concurrent processes
+// never read a partial token.
+func NewFileTokenStore(dir string) TokenStore {
+	return &fileTokenStore{dir: dir}
+}
+
+type fileTokenStore struct {
+	dir string
+}
+
+func (s *fileTokenStore) path(key string) string {
+	return filepath.Join(s.dir, base64.RawURLEncoding.EncodeToString([]byte(key))+".json")
+}
+
+func (s *fileTokenStore) Get(ctx context.Context, key string) (*OAuthToken, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	token := &OAuthToken{}
+	if err := json.Unmarshal(data, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (s *fileTokenStore) Set(ctx context.Context, key string, token *OAuthToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	// CreateTemp creates the file with 0600 permissions
+	f, err := os.CreateTemp(s.dir, ".token-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path(key))
+}
+
+func (s *fileTokenStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package coze
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTokenStore(t *testing.T, store TokenStore) {
+	ctx := context.Background()
+	key := TokenStoreKey("client_id", "user_1")
+
+	token, err := store.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Nil(t, token)
+
+	expected := &OAuthToken{AccessToken: "access", RefreshToken: "refresh", ExpiresIn: 1700000000}
+	require.NoError(t, store.Set(ctx, key, expected))
+	token, err = store.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, expected, token)
+
+	other, err := store.Get(ctx, TokenStoreKey("client_id", "user_2"))
+	require.NoError(t, err)
+	assert.Nil(t, other)
+
+	require.NoError(t, store.Delete(ctx, key))
+	token, err = store.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Nil(t, token)
+	require.NoError(t, store.Delete(ctx, key))
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	testTokenStore(t, NewMemoryTokenStore())
+}
+
+func TestFileTokenStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tokens")
+	testTokenStore(t, NewFileTokenStore(dir))
+
+	t.Run("files are private", func(t *testing.T) {
+		store := NewFileTokenStore(dir)
+		require.NoError(t, store.Set(context.Background(), "key", &OAuthToken{AccessToken: "access"}))
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		info, err := entries[0].Info()
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	})
+}
+
+func TestAuthWithTokenStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reuses a stored token", func(t *testing.T) {
+		store := NewMemoryTokenStore()
+		require.NoError(t, store.Set(ctx, "key", &OAuthToken{AccessToken: "stored", ExpiresIn: time.Now().Unix() + 900}))
+		cache := newTokenCache(30, func(ctx context.Context, current *OAuthToken) (*OAuthToken, error) {
+			t.Fatal("unexpected fetch")
+			return nil, nil
+		}, newAuthOption([]AuthOption{WithTokenStore(store, "key")}))
+
+		token, err := cache.token(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "stored", token)
+	})
+
+	t.Run("stores the fetched token", func(t *testing.T) {
+		store := NewMemoryTokenStore()
+		cache := newTokenCache(30, func(ctx context.Context, current *OAuthToken) (*OAuthToken, error) {
+			return &OAuthToken{AccessToken: "fetched", ExpiresIn: time.Now().Unix() + 900}, nil
+		}, newAuthOption([]AuthOption{WithTokenStore(store, "key")}))
+
+		token, err := cache.token(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "fetched", token)
+
+		stored, err := store.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "fetched", stored.AccessToken)
+	})
+
+	t.Run("refreshes with the stored refresh token", func(t *testing.T) {
+		store := NewMemoryTokenStore()
+		require.NoError(t, store.Set(ctx, "key", &OAuthToken{
+			AccessToken:  "expired",
+			RefreshToken: "stored_refresh",
+			ExpiresIn:    time.Now().Unix() - 1,
+		}))
+		refresher := &mockTokenRefresher{refreshFunc: func(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+			assert.Equal(t, "stored_refresh", refreshToken)
+			return &OAuthToken{AccessToken: "access", RefreshToken: "rotated", ExpiresIn: time.Now().Unix() + 900}, nil
+		}}
+		auth := NewRefreshTokenAuth(nil, refresher, WithTokenStore(store, "key"))
+
+		token, err := auth.Token(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "access", token)
+
+		stored, err := store.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "rotated", stored.RefreshToken)
+	})
+
+	t.Run("does not reuse a stored token after invalidation", func(t *testing.T) {
+		store := NewMemoryTokenStore()
+		calls := 0
+		cache := newTokenCache(30, func(ctx context.Context, current *OAuthToken) (*OAuthToken, error) {
+			calls++
+			return &OAuthToken{AccessToken: "fetched", ExpiresIn: time.Now().Unix() + 900}, nil
+		}, newAuthOption([]AuthOption{WithTokenStore(store, "key")}))
+
+		_, err := cache.token(ctx)
+		require.NoError(t, err)
+		cache.invalidate()
+		_, err = cache.token(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls)
+	})
+}
@@ -0,0 +1,158 @@
+package coze
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Tracer creates a span for every API call made by the client. For streaming calls the span is
+// kept open until the stream is closed or fully consumed.
+//
+// Tracer is a small abstraction so that any tracing library can be plugged in, see the
+// w3ctrace package for a dependency free implementation.
+type Tracer interface {
+	// Start starts a span, the returned context carries the span and is used to send the request.
+	Start(ctx context.Context, name string) (context.Context, Span)
+
+	// Inject writes the trace context carried by ctx into the request headers, so the server side
+	// log ID can be correlated with the trace.
+	Inject(ctx context.Context, header http.Header)
+}
+
+// Span is a single traced operation.
+type Span interface {
+	SetAttribute(key string, value any)
+	AddEvent(name string, attributes map[string]any)
+	RecordError(err error)
+	End()
+}
+
+// Span attributes set by the client.
+const (
+	TraceAttrEndpoint               = "coze.endpoint"
+	TraceAttrMethod                 = "http.method"
+	TraceAttrStatusCode             = "http.status_code"
+	TraceAttrBotID                  = "coze.bot_id"
+	TraceAttrWorkflowID             = "coze.workflow_id"
+	TraceAttrConversationID         = "coze.conversation_id"
+	TraceAttrErrorCode              = "coze.error_code"
+	TraceAttrLogID                  = "coze.log_id"
+	TraceAttrStreamEventCount       = "coze.stream.event_count"
+	TraceAttrStreamTimeToFirstEvent = "coze.stream.time_to_first_event_ms"
+)
+
+const spanContextKey = contextKey("coze_span")
+
+func spanFromContext(ctx context.Context) Span {
+	span, _ := ctx.Value(spanContextKey).(Span)
+	return span
+}
+
+// traceCall opens a span around the call, it is the outermost interceptor when a tracer is set.
+func (c *core) traceCall(ctx context.Context, call *Call, next CallHandler) error {
+	ctx, span := c.tracer.Start(ctx, "coze "+call.Method+" "+call.Path)
+	ctx = context.WithValue(ctx, spanContextKey, span)
+	span.SetAttribute(TraceAttrEndpoint, call.Path)
+	span.SetAttribute(TraceAttrMethod, call.Method)
+
+	start := time.Now()
+	err := next(ctx, call)
+	if call.HTTPResponse != nil {
+		span.SetAttribute(TraceAttrStatusCode, call.HTTPResponse.StatusCode)
+		span.SetAttribute(TraceAttrLogID, call.HTTPResponse.Header.Get(httpLogIDKey))
+	}
+	if err != nil {
+		recordSpanError(span, err)
+	} else if call.Kind == CallKindStreamRequest {
+		addStreamObserver(call.HTTPResponse, &streamSpan{span: span, start: start})
+		return nil
+	}
+	span.End()
+	return err
+}
+
+// traceRequest propagates the trace context and tags the span with the IDs found in the request.
+func (c *core) traceRequest(req *http.Request, body []byte) {
+	if c.tracer == nil {
+		return
+	}
+	c.tracer.Inject(req.Context(), req.Header)
+	span := spanFromContext(req.Context())
+	if span == nil {
+		return
+	}
+
+	ids := struct {
+		BotID          string `json:"bot_id"`
+		WorkflowID     string `json:"workflow_id"`
+		ConversationID string `json:"conversation_id"`
+	}{}
+	if len(body) > 0 && body[0] == '{' {
+		_ = json.Unmarshal(body, &ids)
+	}
+	query := req.URL.Query()
+	for key, value := range map[string]*string{
+		"bot_id":          &ids.BotID,
+		"workflow_id":     &ids.WorkflowID,
+		"conversation_id": &ids.ConversationID,
+	} {
+		if v := query.Get(key); v != "" {
+			*value = v
+		}
+	}
+	for key, value := range map[string]string{
+		TraceAttrBotID:          ids.BotID,
+		TraceAttrWorkflowID:     ids.WorkflowID,
+		TraceAttrConversationID: ids.ConversationID,
+	} {
+		if value != "" {
+			span.SetAttribute(key, value)
+		}
+	}
+}
+
+func recordSpanError(span Span, err error) {
+	span.RecordError(err)
+	if cozeErr, ok := AsCozeError(err); ok {
+		span.SetAttribute(TraceAttrErrorCode, cozeErr.Code)
+		if cozeErr.LogID != "" {
+			span.SetAttribute(TraceAttrLogID, cozeErr.LogID)
+		}
+	} else if authErr, ok := AsAuthError(err); ok {
+		span.SetAttribute(TraceAttrErrorCode, authErr.Code.String())
+		span.SetAttribute(TraceAttrStatusCode, authErr.HttpCode)
+		if authErr.LogID != "" {
+			span.SetAttribute(TraceAttrLogID, authErr.LogID)
+		}
+	}
+}
+
+// streamSpan keeps the span of a streaming call open for the lifetime of the stream.
+type streamSpan struct {
+	span       Span
+	start      time.Time
+	firstEvent time.Duration
+	events     int
+}
+
+func (s *streamSpan) onStreamEvent(ctx context.Context, event any) {
+	s.events++
+	if s.events == 1 {
+		s.firstEvent = time.Since(s.start)
+		s.span.AddEvent("first_event", nil)
+	}
+}
+
+func (s *streamSpan) onStreamEnd(ctx context.Context, err error) {
+	s.span.SetAttribute(TraceAttrStreamEventCount, s.events)
+	if s.events > 0 {
+		s.span.SetAttribute(TraceAttrStreamTimeToFirstEvent, s.firstEvent.Milliseconds())
+	}
+	if err != nil && err != io.EOF {
+		recordSpanError(s.span, err)
+	}
+	s.span.End()
+}
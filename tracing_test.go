@@ -0,0 +1,113 @@
+package coze
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSpan struct {
+	name   string
+	attrs  map[string]any
+	events []string
+	errs   []error
+	ended  int
+}
+
+func (s *mockSpan) SetAttribute(key string, value any) { s.attrs[key] = value }
+func (s *mockSpan) AddEvent(name string, attributes map[string]any) {
+	s.events = append(s.events, name)
+}
+func (s *mockSpan) RecordError(err error) { s.errs = append(s.errs, err) }
+func (s *mockSpan) End()                  { s.ended++ }
+
+type mockTracer struct {
+	mu    sync.Mutex
+	spans []*mockSpan
+}
+
+func (t *mockTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := &mockSpan{name: name, attrs: map[string]any{}}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, contextKey("test_span"), name), span
+}
+
+func (t *mockTracer) Inject(ctx context.Context, header http.Header) {
+	if name, ok := ctx.Value(contextKey("test_span")).(string); ok {
+		header.Set("X-Test-Trace", name)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("span per request", func(t *testing.T) {
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			statusResp(http.StatusOK, `{"code":4100,"msg":"bot not found"}`, nil),
+		}}
+		tracer := &mockTracer{}
+		core := newCore(&clientOption{baseURL: "https://api.test.com", client: mock, tracer: tracer})
+
+		var resp TestResponse
+		err := core.Request(ctx, http.MethodPost, "/v3/chat", map[string]string{"bot_id": "bot1"}, &resp,
+			withHTTPQuery("conversation_id", "conv1"))
+		require.Error(t, err)
+
+		require.Len(t, tracer.spans, 1)
+		span := tracer.spans[0]
+		assert.Equal(t, "coze POST /v3/chat", span.name)
+		assert.Equal(t, "/v3/chat", span.attrs[TraceAttrEndpoint])
+		assert.Equal(t, "bot1", span.attrs[TraceAttrBotID])
+		assert.Equal(t, "conv1", span.attrs[TraceAttrConversationID])
+		assert.Equal(t, http.StatusOK, span.attrs[TraceAttrStatusCode])
+		assert.Equal(t, 4100, span.attrs[TraceAttrErrorCode])
+		assert.Equal(t, "test-log-id", span.attrs[TraceAttrLogID])
+		assert.Len(t, span.errs, 1)
+		assert.Equal(t, 1, span.ended)
+		assert.Equal(t, "coze POST /v3/chat", mock.requests[0].Header.Get("X-Test-Trace"))
+	})
+
+	t.Run("span kept open for stream", func(t *testing.T) {
+		body := "event:conversation.chat.created\ndata:{\"id\":\"chat1\"}\n\nevent:done\ndata:\"[DONE]\"\n\n"
+		mock := &sequenceHTTP{results: []func(req *http.Request) (*http.Response, error){
+			func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+					Body:       io.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}}
+		tracer := &mockTracer{}
+		chats := newChats(newCore(&clientOption{baseURL: "https://api.test.com", client: mock, tracer: tracer}))
+
+		stream, err := chats.Stream(ctx, &CreateChatsReq{BotID: "bot1", ConversationID: "conv1"})
+		require.NoError(t, err)
+		span := tracer.spans[0]
+		assert.Equal(t, 0, span.ended)
+		assert.Equal(t, "bot1", span.attrs[TraceAttrBotID])
+		assert.Equal(t, "conv1", span.attrs[TraceAttrConversationID])
+
+		for {
+			_, err := stream.Recv()
+			if err != nil {
+				assert.Equal(t, io.EOF, err)
+				break
+			}
+		}
+		assert.Equal(t, 1, span.ended)
+		assert.Equal(t, 2, span.attrs[TraceAttrStreamEventCount])
+		assert.Contains(t, span.attrs, TraceAttrStreamTimeToFirstEvent)
+
+		require.NoError(t, stream.Close())
+		assert.Equal(t, 1, span.ended)
+	})
+}
@@ -0,0 +1,187 @@
+// Package w3ctrace is a dependency free coze.Tracer which propagates the W3C Trace Context
+// (traceparent header) and hands every finished span to an exporter.
+//
+// It can be used as is to correlate Coze log IDs with the traces of a service, or as a reference
+// for writing an adapter to a full tracing library such as OpenTelemetry.
+package w3ctrace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// TraceParentHeader is the W3C Trace Context header.
+const TraceParentHeader = "traceparent"
+
+// SpanContext identifies a span within a trace.
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+// TraceParent formats the span context as a traceparent header value.
+func (c SpanContext) TraceParent() string {
+	flags := "00"
+	if c.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", c.TraceID, c.SpanID, flags)
+}
+
+// ParseTraceParent parses a traceparent header value.
+func ParseTraceParent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent: %q", value)
+	}
+	if parts[0] == "ff" {
+		return SpanContext{}, fmt.Errorf("invalid traceparent version: %q", value)
+	}
+	for _, part := range parts {
+		if _, err := hex.DecodeString(part); err != nil {
+			return SpanContext{}, fmt.Errorf("invalid traceparent: %q", value)
+		}
+	}
+	if parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return SpanContext{}, errors.New("invalid traceparent: zero trace or span id")
+	}
+	flags, _ := hex.DecodeString(parts[3])
+	return SpanContext{
+		TraceID: parts[1],
+		SpanID:  parts[2],
+		Sampled: flags[0]&0x01 == 0x01,
+	}, nil
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a context whose next span is a child of sc, typically the
+// span context parsed from an incoming request.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by ctx.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// Event is an event recorded on a span.
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]any
+}
+
+// SpanData is a finished span.
+type SpanData struct {
+	SpanContext
+	ParentSpanID string
+	Name         string
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]any
+	Events       []Event
+	Errors       []error
+}
+
+// Exporter receives every finished span.
+type Exporter func(span *SpanData)
+
+// Tracer implements coze.Tracer.
+type Tracer struct {
+	exporter Exporter
+}
+
+var _ coze.Tracer = &Tracer{}
+
+// NewTracer creates a tracer which exports the finished spans to exporter.
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Start starts a span, as a child of the span context carried by ctx if any.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, coze.Span) {
+	data := &SpanData{
+		Name:       name,
+		Start:      time.Now(),
+		Attributes: map[string]any{},
+	}
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		data.TraceID = parent.TraceID
+		data.ParentSpanID = parent.SpanID
+		data.Sampled = parent.Sampled
+	} else {
+		data.TraceID = randomHex(16)
+		data.Sampled = true
+	}
+	data.SpanID = randomHex(8)
+
+	return ContextWithSpanContext(ctx, data.SpanContext), &span{data: data, exporter: t.exporter}
+}
+
+// Inject sets the traceparent header from the span context carried by ctx.
+func (t *Tracer) Inject(ctx context.Context, header http.Header) {
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		header.Set(TraceParentHeader, sc.TraceParent())
+	}
+}
+
+type span struct {
+	mu       sync.Mutex
+	data     *SpanData
+	exporter Exporter
+	ended    bool
+}
+
+func (s *span) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes[key] = value
+}
+
+func (s *span) AddEvent(name string, attributes map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Events = append(s.data.Events, Event{Name: name, Time: time.Now(), Attributes: attributes})
+}
+
+func (s *span) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Errors = append(s.data.Errors, err)
+}
+
+func (s *span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	s.mu.Unlock()
+	if s.exporter != nil {
+		s.exporter(s.data)
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand never fails on supported platforms, fall back to the time to stay unique
+		return fmt.Sprintf("%0*x", n*2, time.Now().UnixNano())[:n*2]
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,66 @@
+package w3ctrace
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	sc, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID)
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.TraceParent())
+
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	} {
+		_, err := ParseTraceParent(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	var exported []*SpanData
+	tracer := NewTracer(func(span *SpanData) { exported = append(exported, span) })
+
+	parent, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	ctx, span := tracer.Start(ContextWithSpanContext(context.Background(), parent), "coze POST /v3/chat")
+	span.SetAttribute("coze.bot_id", "bot1")
+	span.AddEvent("first_event", nil)
+	span.RecordError(errors.New("failed"))
+
+	header := http.Header{}
+	tracer.Inject(ctx, header)
+	injected, err := ParseTraceParent(header.Get(TraceParentHeader))
+	require.NoError(t, err)
+	assert.Equal(t, parent.TraceID, injected.TraceID)
+	assert.NotEqual(t, parent.SpanID, injected.SpanID)
+
+	span.End()
+	span.End()
+	require.Len(t, exported, 1)
+	data := exported[0]
+	assert.Equal(t, parent.SpanID, data.ParentSpanID)
+	assert.Equal(t, injected.SpanID, data.SpanID)
+	assert.Equal(t, "bot1", data.Attributes["coze.bot_id"])
+	assert.Len(t, data.Events, 1)
+	assert.Len(t, data.Errors, 1)
+	assert.False(t, data.End.Before(data.Start))
+
+	_, root := tracer.Start(context.Background(), "root")
+	root.End()
+	assert.Len(t, exported[1].TraceID, 32)
+	assert.Empty(t, exported[1].ParentSpanID)
+}
@@ -1,7 +1,6 @@
 package coze
 
 import (
-	"bufio"
 	"context"
 	"net/http"
 )
@@ -18,13 +17,7 @@ func (r *workflowsChat) Stream(ctx context.Context, req *WorkflowsChatStreamReq)
 		return nil, err
 	}
 
-	return &streamReader[ChatEvent]{
-		ctx:          ctx,
-		response:     resp,
-		reader:       bufio.NewReader(resp.Body),
-		processor:    parseChatEvent,
-		httpResponse: newHTTPResponse(resp),
-	}, nil
+	return newClientStreamReader(ctx, r.client, resp, parseChatEvent), nil
 }
 
 func newWorkflowsChat(core *core) *workflowsChat {
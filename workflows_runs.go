@@ -1,12 +1,10 @@
 package coze
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
-	"strings"
 )
 
 func (r *workflowRuns) Create(ctx context.Context, req *RunWorkflowsReq) (*RunWorkflowsResp, error) {
@@ -29,13 +27,7 @@ func (r *workflowRuns) Resume(ctx context.Context, req *ResumeRunWorkflowsReq) (
 		return nil, err
 	}
 
-	return &streamReader[WorkflowEvent]{
-		ctx:          ctx,
-		response:     resp,
-		reader:       bufio.NewReader(resp.Body),
-		processor:    parseWorkflowEvent,
-		httpResponse: newHTTPResponse(resp),
-	}, nil
+	return newClientStreamReader(ctx, r.client, resp, parseWorkflowEvent), nil
 }
 
 func (r *workflowRuns) Stream(ctx context.Context, req *RunWorkflowsReq) (Stream[WorkflowEvent], error) {
@@ -46,13 +38,7 @@ func (r *workflowRuns) Stream(ctx context.Context, req *RunWorkflowsReq) (Stream
 		return nil, err
 	}
 
-	return &streamReader[WorkflowEvent]{
-		ctx:          ctx,
-		response:     resp,
-		reader:       bufio.NewReader(resp.Body),
-		processor:    parseWorkflowEvent,
-		httpResponse: newHTTPResponse(resp),
-	}, nil
+	return newClientStreamReader(ctx, r.client, resp, parseWorkflowEvent), nil
 }
 
 type workflowRuns struct {
@@ -67,35 +53,17 @@ func newWorkflowRun(core *core) *workflowRuns {
 	}
 }
 
-func parseWorkflowEvent(lineBytes []byte, reader *bufio.Reader) (*WorkflowEvent, bool, error) {
-	line := string(lineBytes)
-	if strings.HasPrefix(line, "id:") {
-		id := strings.TrimSpace(line[3:])
-		data, err := reader.ReadString('\n')
-		if err != nil {
-			return nil, false, err
-		}
-		event := strings.TrimSpace(data[6:])
-		data, err = reader.ReadString('\n')
-		if err != nil {
-			return nil, false, err
-		}
-		data = strings.TrimSpace(data[5:])
-
-		eventLine := map[string]string{
-			"id":    id,
-			"event": event,
-			"data":  data,
-		}
-
-		eventData, err := doParseWorkflowEvent(eventLine)
-		if err != nil {
-			return nil, false, err
-		}
-
-		return eventData, eventData.IsDone(), nil
+func parseWorkflowEvent(ctx context.Context, event *SSEEvent) (*WorkflowEvent, bool, error) {
+	eventLine := map[string]string{
+		"id":    event.ID,
+		"event": event.Event,
+		"data":  event.Data,
+	}
+	eventData, err := doParseWorkflowEvent(eventLine)
+	if err != nil {
+		return nil, false, newStreamDecodeError(ctx, event, err)
 	}
-	return nil, false, nil
+	return eventData, eventData.IsDone(), nil
 }
 
 // WorkflowRunResult represents the result of a workflow runs
@@ -2,6 +2,7 @@ package coze
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"testing"
 
@@ -197,6 +198,22 @@ data:{"error_code":400,"error_message":"Bad Request"}
 		assert.Equal(t, WorkflowEventTypeError, event.Event)
 		assert.Equal(t, 400, event.Error.ErrorCode)
 		assert.Equal(t, "Bad Request", event.Error.ErrorMessage)
+
+		// returned from Recv with WithStreamErrorsReturned
+		core = newCore(&clientOption{baseURL: ComBaseURL, client: &http.Client{Transport: mockTransport}, streamErrorsReturned: true})
+		reader, err = newWorkflowRun(core).Stream(context.Background(), &RunWorkflowsReq{
+			WorkflowID: "workflow1",
+		})
+		require.NoError(t, err)
+		defer reader.Close()
+
+		_, err = reader.Recv()
+		cozeErr, ok := AsCozeError(err)
+		require.True(t, ok)
+		assert.Equal(t, 400, cozeErr.Code)
+		assert.Equal(t, "Bad Request", cozeErr.Message)
+		_, err = reader.Recv()
+		assert.Equal(t, io.EOF, err)
 	})
 
 	// Test interrupt event parsing